@@ -5,4 +5,20 @@ const (
 	// If the Condition is False, the resource SHOULD be considered to be in the process of reconciling and not a
 	// representation of actual state.
 	ReadyCondition string = "Ready"
+
+	// KeyRotationCondition indicates whether the last rotation or retirement of the
+	// service account signing key has succeeded.
+	KeyRotationCondition string = "KeyRotation"
+
+	// SigningKeySyncedCondition indicates whether the key IDs of the published JWKS
+	// match the signing keys the kube-apiserver is configured with.
+	SigningKeySyncedCondition string = "SigningKeySynced"
+
+	// TokenIssuanceVerifiedCondition indicates whether a service account token issued by the kube-apiserver
+	// can be validated against the published discovery documents.
+	TokenIssuanceVerifiedCondition string = "TokenIssuanceVerified"
+
+	// IssuerCertificateVerifiedCondition indicates whether the certificate chain served by the issuer
+	// has been verified before its thumbprint was registered in the IAM OIDC provider.
+	IssuerCertificateVerifiedCondition string = "IssuerCertificateVerified"
 )
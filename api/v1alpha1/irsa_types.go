@@ -44,12 +44,36 @@ type IRSASpec struct {
 	// +required
 	IamRole IamRole `json:"iamRole,omitempty"`
 
+	// SetupRef references the IRSASetup whose issuer the IAM role trusts.
+	// An IRSASetup in another namespace must list the namespace of the IRSA in its AllowedNamespaces.
+	// When it is not set, there must be exactly one IRSASetup in the cluster.
+	// +optional
+	SetupRef *IRSASetupReference `json:"setupRef,omitempty"`
+
+	// Audience is the audience of the service account tokens. It is required by the trust policy of the IAM role,
+	// set in the "eks.amazonaws.com/audience" annotation of the service accounts, and registered as a client ID
+	// of the IAM OIDC provider.
+	// Default: the DefaultAudience of the IRSASetup
+	// +optional
+	Audience string `json:"audience,omitempty"`
+
 	// IamPolicies represents the list of IAM policies to be attached to the IAM role.
 	// You can set both the policy name (only AWS default policies) or the full ARN.
 	// +required
 	IamPolicies []string `json:"iamPolicies,omitempty"`
 }
 
+// IRSASetupReference references an IRSASetup.
+type IRSASetupReference struct {
+	// Name is the name of the IRSASetup.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the IRSASetup.
+	// Default: the namespace of the IRSA
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
 // IRSAServiceAccount represents the details of the Kubernetes service account
 type IRSAServiceAccount struct {
 	// Name represents the name of the Kubernetes service account
@@ -70,10 +94,34 @@ func (sa *IRSAServiceAccount) NamespacedNameList() []types.NamespacedName {
 	return namespacedName
 }
 
+// DefaultTokenAudience is the audience of the service account tokens when neither the IRSA nor the IRSASetup sets one.
+const DefaultTokenAudience = "sts.amazonaws.com"
+
+// TokenAudience returns the audience of the service account tokens of the IRSA,
+// which defaults to the DefaultAudience of the IRSASetup.
+func (in *IRSA) TokenAudience(setup *IRSASetup) string {
+	if in.Spec.Audience != "" {
+		return in.Spec.Audience
+	}
+	return setup.DefaultAudience()
+}
+
 // IamRole represents the IAM role configuration
 type IamRole struct {
 	// Name represents the name of the IAM role.
 	Name string `json:"name,omitempty"`
+
+	// DeployerRoleArn is the ARN of a role in another AWS account, which is assumed with the credentials of the IRSASetup
+	// to create the IAM role in that account. The IAM OIDC provider of the issuer is created in that account as well
+	// if it does not exist, and is deleted by the IRSASetup once no IRSA uses that account, when its Cleanup is enabled.
+	// When it is not set, the IAM role is created in the account of the credentials of the IRSASetup.
+	// +kubebuilder:validation:Pattern=`^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$`
+	// +optional
+	DeployerRoleArn string `json:"deployerRoleArn,omitempty"`
+
+	// ExternalID is the external ID passed when assuming DeployerRoleArn.
+	// +optional
+	ExternalID string `json:"externalId,omitempty"`
 }
 
 // IRSAStatus defines the observed state of IRSA.
@@ -81,6 +129,9 @@ type IRSAStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 	// Inventory of applied service resources
 	ServiceAccounts StatusServiceAccountList `json:"serviceAccounts,omitempty"`
+	// AccountID is the AWS account the IAM role has been created in.
+	// +optional
+	AccountID string `json:"accountId,omitempty"`
 }
 
 type StatusServiceAccountList []IRSANamespacedNameWithTags
@@ -191,10 +242,14 @@ func removeStatusServiceAccounts(s *StatusServiceAccountList, namespacedName typ
 type IRSAReason string
 
 const (
-	IRSAReasonFailedRoleUpdate IRSAReason = "IRSAFailedRoleUpdate"
-	IRSAReasonFailedK8sApply   IRSAReason = "IRSAFailedApplyingResources"
-	IRSAReasonFailedK8sCleanUp IRSAReason = "IRSAFailedDeletingResources"
-	IRSAReasonReady            IRSAReason = "IRSAReady"
+	IRSAReasonSetupNotFound     IRSAReason = "IRSASetupNotFound"
+	IRSAReasonSetupAmbiguous    IRSAReason = "IRSASetupAmbiguous"
+	IRSAReasonSetupNotAllowed   IRSAReason = "IRSASetupNotAllowed"
+	IRSAReasonFailedCredentials IRSAReason = "IRSAFailedCredentials"
+	IRSAReasonFailedRoleUpdate  IRSAReason = "IRSAFailedRoleUpdate"
+	IRSAReasonFailedK8sApply    IRSAReason = "IRSAFailedApplyingResources"
+	IRSAReasonFailedK8sCleanUp  IRSAReason = "IRSAFailedDeletingResources"
+	IRSAReasonReady             IRSAReason = "IRSAReady"
 )
 
 //+kubebuilder:object:root=true
@@ -125,3 +125,34 @@ func TestStatusServiceAccountList_IsExist(t *testing.T) {
 		})
 	}
 }
+
+func TestIRSA_TokenAudience(t *testing.T) {
+	tests := []struct {
+		name     string
+		irsa     IRSASpec
+		setup    IRSASetupSpec
+		expected string
+	}{
+		{
+			name:     "default",
+			expected: "sts.amazonaws.com",
+		},
+		{
+			name:     "default audience of the IRSASetup",
+			setup:    IRSASetupSpec{DefaultAudience: "cluster.example.com"},
+			expected: "cluster.example.com",
+		},
+		{
+			name:     "audience of the IRSA",
+			irsa:     IRSASpec{Audience: "app.example.com"},
+			setup:    IRSASetupSpec{DefaultAudience: "cluster.example.com"},
+			expected: "app.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			irsa := &IRSA{Spec: tt.irsa}
+			assert.Equal(t, tt.expected, irsa.TokenAudience(&IRSASetup{Spec: tt.setup}))
+		})
+	}
+}
@@ -17,6 +17,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -27,6 +31,7 @@ const (
 )
 
 // IRSASetupSpec defines the desired state of IRSASetup
+// +kubebuilder:validation:XValidation:rule="!has(self.keyRotation) || !has(self.signingKey) || !has(self.signingKey.source) || self.signingKey.source == 'Generated'",message="keyRotation requires a signing key generated by irsa-manager"
 type IRSASetupSpec struct {
 	// Cleanup, when enabled, allows the IRSASetup to perform garbage collection
 	// of resources that are no longer needed or managed.
@@ -45,9 +50,86 @@ type IRSASetupSpec struct {
 	// Only applicable when Mode is "selfhosted".
 	Discovery Discovery `json:"discovery,omitempty"`
 
+	// Issuer overrides the issuer URL derived from Discovery.
+	// It is used in the discovery document, for the IAM OIDC provider and in the trust policies of the IAM roles,
+	// while the discovery documents are still stored as configured by Discovery.
+	// Only applicable when Mode is "selfhosted".
+	// +optional
+	Issuer *Issuer `json:"issuer,omitempty"`
+
+	// DefaultAudience is the audience of the service account tokens of the IRSAs that do not set one.
+	// It is registered as a client ID of the IAM OIDC provider and required by the trust policies of the IAM roles.
+	// Default: "sts.amazonaws.com"
+	// +optional
+	DefaultAudience string `json:"defaultAudience,omitempty"`
+
+	// Audiences are the client IDs registered in the IAM OIDC provider in addition to "sts.amazonaws.com" and DefaultAudience,
+	// e.g. for tokens that are also exchanged with other services.
+	// They are added to an existing provider, and the client IDs registered by irsa-manager that are no longer listed are removed.
+	// Only applicable when Mode is "selfhosted".
+	// +optional
+	Audiences []string `json:"audiences,omitempty"`
+
+	// Thumbprints are the hex encoded SHA-1 thumbprints of the top intermediate CA certificate of the issuer,
+	// registered in the IAM OIDC provider.
+	// When they are not set, the thumbprint is computed from the certificate served by the issuer,
+	// unless the issuer is served by AWS (S3 or CloudFront) with a certificate trusted by AWS IAM.
+	// Only applicable when Mode is "selfhosted".
+	// +kubebuilder:validation:MaxItems=5
+	// +kubebuilder:validation:items:Pattern=`^[0-9a-fA-F]{40}$`
+	// +optional
+	Thumbprints []string `json:"thumbprints,omitempty"`
+
+	// IssuerCertificate configures how the certificate chain served by the issuer is verified
+	// before its thumbprint is registered in the IAM OIDC provider, when Thumbprints are not set.
+	// Only applicable when Mode is "selfhosted".
+	// +optional
+	IssuerCertificate *IssuerCertificate `json:"issuerCertificate,omitempty"`
+
+	// Credentials configures the AWS credentials used to manage the AWS resources of the IRSASetup
+	// and of the IRSAs bound to it, e.g. to manage IAM in another AWS account.
+	// When it is not set, the credentials of the controller are used.
+	// +optional
+	Credentials *AwsCredentials `json:"credentials,omitempty"`
+
+	// AllowedNamespaces are the namespaces whose IRSAs may reference the IRSASetup with setupRef,
+	// in addition to the namespace of the IRSASetup, as the IRSAs bound to it use its credentials.
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
 	// IamOIDCProvider configures IAM OIDC IamOIDCProvider Name
 	// Only applicable when Mode is "eks".
 	IamOIDCProvider string `json:"iamOIDCProvider,omitempty"`
+
+	// SigningKey configures the key used by the kube-apiserver to sign service account tokens.
+	// Only applicable when Mode is "selfhosted".
+	// +optional
+	SigningKey SigningKey `json:"signingKey,omitempty"`
+
+	// KeyRotation configures the rotation of the service account signing key.
+	// Only applicable when Mode is "selfhosted".
+	// It cannot be set when the signing key is managed outside of irsa-manager, as such a key is never rotated.
+	// +optional
+	KeyRotation *KeyRotation `json:"keyRotation,omitempty"`
+
+	// DriftDetection configures the periodic verification and repair of the self-hosted resources.
+	// When it is not set, the resources are only verified while they are being set up.
+	// Only applicable when Mode is "selfhosted".
+	// +optional
+	DriftDetection *DriftDetection `json:"driftDetection,omitempty"`
+
+	// NodeAgent deploys a privileged DaemonSet on the control plane nodes that saves the key files
+	// and sets the flags of the kube-apiserver in its static Pod manifest.
+	// When it is not set, the kube-apiserver has to be configured as described in the status.
+	// Only one IRSASetup of a cluster can set it.
+	// Only applicable when Mode is "selfhosted".
+	// +optional
+	NodeAgent *NodeAgent `json:"nodeAgent,omitempty"`
+
+	// Webhook configures the pod-identity-webhook.
+	// Only applicable when Mode is "selfhosted".
+	// +optional
+	Webhook *Webhook `json:"webhook,omitempty"`
 }
 
 // +kubebuilder:default=selfhosted
@@ -65,6 +147,67 @@ const (
 type Discovery struct {
 	// S3 specifies the AWS S3 bucket details where the OIDC provider's discovery information is hosted.
 	S3 S3Discovery `json:"s3,omitempty"`
+
+	// InCluster serves the OIDC provider's discovery information from a server in the cluster
+	// exposed through an Ingress, instead of from S3. When it is set, S3 is ignored.
+	// +optional
+	InCluster *InClusterDiscovery `json:"inCluster,omitempty"`
+}
+
+// InClusterDiscovery contains the specifics of the in-cluster server used for hosting OIDC provider discovery information.
+// The discovery document and the JWKS are stored in a ConfigMap and served by a Deployment in the kube-system namespace.
+type InClusterDiscovery struct {
+	// Host is the host of the Ingress, e.g. "oidc.example.com". The issuer is "https://<Host>",
+	// so the Ingress must serve it via https with a certificate trusted by AWS IAM.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	Host string `json:"host"`
+
+	// IngressClassName is the class of the Ingress. The default class of the cluster is used when it is not set.
+	// +optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+
+	// TLSSecretName is the name of the Secret in the kube-system namespace holding the certificate of the Host.
+	// It can be omitted when the certificate is managed by the ingress controller.
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+
+	// Annotations are set on the Ingress, e.g. to request a certificate from cert-manager.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Image is the image of the server. It must run nginx as a non-root user listening on port 8080.
+	// Default: "nginxinc/nginx-unprivileged:1.27.3-alpine"
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ImageDigest pins the image to a digest, e.g. "sha256:<hex>".
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	// +optional
+	ImageDigest string `json:"imageDigest,omitempty"`
+}
+
+// DefaultInClusterImage is the image of the in-cluster discovery server when none is configured.
+const DefaultInClusterImage = "nginxinc/nginx-unprivileged:1.27.3-alpine"
+
+// GetImage returns the configured image, pinned to the digest if any, falling back to the default one.
+func (d *InClusterDiscovery) GetImage() string {
+	image := DefaultInClusterImage
+	if d.Image != "" {
+		image = d.Image
+	}
+	if d.ImageDigest != "" {
+		image = image + "@" + d.ImageDigest
+	}
+	return image
+}
+
+// Issuer specifies a custom issuer, e.g. a vanity domain that stays the same when the storage changes.
+type Issuer struct {
+	// URL is the issuer URL, e.g. "https://oidc.example.com/cluster-a".
+	// It must use https and must not have a trailing slash, a query or a fragment.
+	// The discovery documents must be served at "<URL>/.well-known/openid-configuration" and "<URL>/keys.json".
+	// +kubebuilder:validation:Pattern=`^https://[^?#]*[^/?#]$`
+	URL string `json:"url"`
 }
 
 // S3Discovery contains the specifics of the S3 bucket used for hosting OIDC provider discovery information.
@@ -74,11 +217,978 @@ type S3Discovery struct {
 
 	// BucketName is the name of the S3 bucket that hosts the OIDC discovery information.
 	BucketName string `json:"bucketName"`
+
+	// Prefix is the path in the bucket under which the OIDC discovery information is published, e.g. "cluster-a".
+	// It is appended to the issuer, so that several clusters can share one bucket with a prefix each.
+	// The bucket is only deleted once no objects are left in it.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9._-]+(/[A-Za-z0-9._-]+)*$`
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// SharedJWKS merges the public keys of this cluster into a JWKS shared with the other clusters
+	// publishing to the same bucket and prefix, so that they sign tokens under the same issuer,
+	// e.g. during a blue/green cluster migration.
+	// Each cluster stores its keys in its own object, from which the JWKS is rebuilt with conditional writes.
+	// The IAM OIDC provider and the discovery document are only deleted together with the last cluster.
+	// +optional
+	SharedJWKS bool `json:"sharedJWKS,omitempty"`
+
+	// Endpoint is the URL of an S3-compatible storage such as MinIO, Ceph or Wasabi,
+	// e.g. "https://minio.example.com". When it is set, the issuer is built from it instead of
+	// the AWS S3 endpoint, so it must be reachable via https by AWS IAM.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// UsePathStyle addresses the bucket as "<Endpoint>/<BucketName>" instead of "<BucketName>.<Endpoint host>".
+	// Only applicable when Endpoint is set.
+	// +optional
+	UsePathStyle bool `json:"usePathStyle,omitempty"`
+
+	// CredentialsSecretRef references a Secret holding the access key of the storage
+	// in its "accessKeyId" and "secretAccessKey" keys.
+	// When it is not set, the AWS credentials of the controller are used.
+	// +optional
+	CredentialsSecretRef *S3CredentialsSecretReference `json:"credentialsSecretRef,omitempty"`
+
+	// Access specifies how the OIDC discovery information is made publicly readable.
+	// Possible values:
+	//   - "PublicACL": the bucket's public access block is removed and the objects are uploaded with the public-read ACL.
+	//   - "BucketPolicy": ACLs are disabled and public ACLs are blocked. A bucket policy grants read-only access
+	//     to the discovery document and the JWKS only. Default encryption (SSE-S3) and versioning are turned on.
+	// Default: "PublicACL"
+	// +optional
+	Access S3Access `json:"access,omitempty"`
+
+	// Tags are set on the S3 bucket, replacing its existing tags.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Replicas are buckets in other regions to which the OIDC discovery information is replicated and kept in sync,
+	// so that the issuer can be failed over to one of them when the region of the bucket is unavailable.
+	// They are set up like the bucket with the same Access, Tags, Prefix and credentials, but are never served through CloudFront.
+	// +optional
+	Replicas []S3Replica `json:"replicas,omitempty"`
+
+	// CloudFront serves the OIDC discovery information through a CloudFront distribution
+	// in front of a fully private bucket, and uses its domain name as the issuer.
+	// When it is set, Access is ignored and the bucket policy only allows the distribution to read
+	// the discovery document and the JWKS.
+	// +optional
+	CloudFront *CloudFrontDiscovery `json:"cloudFront,omitempty"`
+}
+
+// CloudFrontDiscovery configures the CloudFront distribution serving the OIDC discovery information.
+type CloudFrontDiscovery struct {
+	// DistributionID is the ID of an existing distribution whose origin is the S3 bucket
+	// with an Origin Access Control. irsa-manager does not modify nor delete it.
+	// When it is not set, irsa-manager creates a distribution with an Origin Access Control.
+	// +optional
+	DistributionID string `json:"distributionId,omitempty"`
+
+	// Alias is a custom domain name used as the issuer instead of the distribution's domain name,
+	// e.g. "oidc.example.com". Its DNS record must point to the distribution.
+	// +optional
+	Alias string `json:"alias,omitempty"`
+
+	// CertificateArn is the ARN of an ACM certificate in us-east-1 that covers Alias.
+	// Required when Alias is set and the distribution is created by irsa-manager.
+	// +optional
+	CertificateArn string `json:"certificateArn,omitempty"`
+}
+
+// ForReplica returns the settings of the replica bucket, which are the ones of the bucket
+// except for the region and the bucket name, and without CloudFront.
+func (s S3Discovery) ForReplica(replica S3Replica) S3Discovery {
+	s.Region = replica.Region
+	s.BucketName = replica.BucketName
+	s.CloudFront = nil
+	s.Replicas = nil
+	return s
+}
+
+// S3Replica is a bucket in another region to which the OIDC discovery information is replicated.
+type S3Replica struct {
+	// Region denotes the AWS region where the S3 bucket is located.
+	Region string `json:"region"`
+
+	// BucketName is the name of the S3 bucket.
+	BucketName string `json:"bucketName"`
+}
+
+// S3CredentialsSecretReference references a Secret holding the access key of an S3-compatible storage.
+type S3CredentialsSecretReference struct {
+	// Name is the name of the Secret.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the Secret.
+	Namespace string `json:"namespace"`
+}
+
+const (
+	S3AccessKeyIDKey     = "accessKeyId"
+	S3SecretAccessKeyKey = "secretAccessKey"
+)
+
+// AwsCredentials configures the AWS credentials of an IRSASetup.
+type AwsCredentials struct {
+	// SecretRef references a Secret holding the access key in its "accessKeyId" and "secretAccessKey" keys,
+	// and optionally a session token in its "sessionToken" key.
+	// The Secret is read on every reconciliation, so that a rotated access key is used without restarting the controller.
+	// When it is not set, the credentials of the controller are used.
+	// +optional
+	SecretRef *AwsCredentialsSecretReference `json:"secretRef,omitempty"`
+
+	// RoleArn is the ARN of an IAM role assumed with the credentials, e.g. a role in another AWS account.
+	// +optional
+	RoleArn string `json:"roleArn,omitempty"`
+
+	// ExternalID is the external ID passed when assuming RoleArn.
+	// +optional
+	ExternalID string `json:"externalId,omitempty"`
+
+	// SessionName is the session name used when assuming RoleArn.
+	// +optional
+	SessionName string `json:"sessionName,omitempty"`
+
+	// Region is the AWS region of the API calls, which overrides the one of the controller.
+	// +optional
+	Region string `json:"region,omitempty"`
+}
+
+// IssuerCertificate configures the verification of the certificate chain served by the issuer.
+type IssuerCertificate struct {
+	// CABundle is a PEM encoded bundle of CA certificates trusted in addition to the system roots
+	// to verify the certificate chain served by the issuer, e.g. the CA of a private issuer.
+	// +optional
+	CABundle string `json:"caBundle,omitempty"`
+
+	// TrustOnFirstUse registers the thumbprint of a certificate chain that cannot be verified.
+	// The thumbprint is then trusted as computed on the first connection to the issuer,
+	// and a different one is refused afterwards.
+	// The IssuerCertificateVerified condition reports that the chain has not been verified.
+	// +optional
+	TrustOnFirstUse bool `json:"trustOnFirstUse,omitempty"`
+}
+
+// AwsCredentialsSecretReference references a Secret holding an AWS access key.
+type AwsCredentialsSecretReference struct {
+	// Name is the name of the Secret.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the Secret.
+	Namespace string `json:"namespace"`
+}
+
+const (
+	AwsAccessKeyIDKey     = "accessKeyId"
+	AwsSecretAccessKeyKey = "secretAccessKey"
+	AwsSessionTokenKey    = "sessionToken"
+)
+
+// +kubebuilder:default=PublicACL
+// +kubebuilder:validation:Enum=PublicACL;BucketPolicy
+type S3Access string
+
+const (
+	S3AccessPublicACL    = S3Access("PublicACL")
+	S3AccessBucketPolicy = S3Access("BucketPolicy")
+)
+
+// SigningKey holds the configuration of the service account signing key.
+type SigningKey struct {
+	// Source specifies where the signing key comes from.
+	// Possible values:
+	//   - "Generated": irsa-manager generates the key pair and stores it in the kube-system/irsa-manager-key Secret.
+	//   - "Secret": the key is read from the Secret referenced by SecretRef.
+	//   - "File": the key is read from the PEM file at File.Path on the controller's filesystem.
+	//   - "APIServer": the JWKS served by the kube-apiserver at /openid/v1/jwks is mirrored unchanged.
+	//     This requires ServiceAccountIssuerDiscovery and the kube-apiserver's issuer to match the S3 issuer.
+	// With "Secret" and "File", the key can be either the private key or the public keys
+	// given to the kube-apiserver.
+	// Except with "Generated", the key is neither written nor rotated by irsa-manager.
+	// Default: "Generated"
+	// +optional
+	Source SigningKeySource `json:"source,omitempty"`
+
+	// SecretRef references the Secret holding the PEM encoded signing key.
+	// Required when Source is "Secret".
+	// +optional
+	SecretRef *SigningKeySecretReference `json:"secretRef,omitempty"`
+
+	// File specifies the PEM encoded signing key on the controller's filesystem,
+	// e.g. a node-local key mounted into the controller Pod.
+	// Required when Source is "File".
+	// +optional
+	File *SigningKeyFile `json:"file,omitempty"`
+
+	// Algorithm is the key algorithm used when a new signing key is generated.
+	// Possible values:
+	//   - "RSA2048", "RSA3072", "RSA4096": RSA keys, published as RS256.
+	//   - "ECDSAP256": ECDSA P-256 keys, published as ES256.
+	//   - "ECDSAP384": ECDSA P-384 keys, published as ES384.
+	//   - "ECDSAP521": ECDSA P-521 keys, published as ES512.
+	// When KeyRotation is configured, changing the algorithm rotates the active key.
+	// Otherwise it only applies to keys generated afterwards.
+	// Default: "RSA2048"
+	// +optional
+	Algorithm SigningKeyAlgorithm `json:"algorithm,omitempty"`
+}
+
+// +kubebuilder:default=RSA2048
+// +kubebuilder:validation:Enum=RSA2048;RSA3072;RSA4096;ECDSAP256;ECDSAP384;ECDSAP521
+type SigningKeyAlgorithm string
+
+const (
+	SigningKeyRSA2048   = SigningKeyAlgorithm("RSA2048")
+	SigningKeyRSA3072   = SigningKeyAlgorithm("RSA3072")
+	SigningKeyRSA4096   = SigningKeyAlgorithm("RSA4096")
+	SigningKeyECDSAP256 = SigningKeyAlgorithm("ECDSAP256")
+	SigningKeyECDSAP384 = SigningKeyAlgorithm("ECDSAP384")
+	SigningKeyECDSAP521 = SigningKeyAlgorithm("ECDSAP521")
+)
+
+// +kubebuilder:default=Generated
+// +kubebuilder:validation:Enum=Generated;Secret;File;APIServer
+type SigningKeySource string
+
+const (
+	SigningKeySourceGenerated = SigningKeySource("Generated")
+	SigningKeySourceSecret    = SigningKeySource("Secret")
+	SigningKeySourceFile      = SigningKeySource("File")
+	SigningKeySourceAPIServer = SigningKeySource("APIServer")
+)
+
+// SigningKeySecretReference references a key of a Secret holding a PEM encoded signing key.
+type SigningKeySecretReference struct {
+	// Name is the name of the Secret.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the Secret.
+	Namespace string `json:"namespace"`
+
+	// Key is the key of the Secret data holding the PEM encoded key.
+	// Default: "sa.key"
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// SigningKeyFile specifies a PEM encoded signing key on the controller's filesystem.
+type SigningKeyFile struct {
+	// Path is the absolute path of the PEM file.
+	Path string `json:"path"`
+}
+
+// KeySource returns the configured key source, falling back to the default one.
+func (k *SigningKey) KeySource() SigningKeySource {
+	if k.Source == "" {
+		return SigningKeySourceGenerated
+	}
+	return k.Source
+}
+
+// IsExternal reports whether the signing key is managed outside of irsa-manager.
+func (k *SigningKey) IsExternal() bool {
+	return k.KeySource() != SigningKeySourceGenerated
+}
+
+// SecretKey returns the key of the Secret data holding the signing key, falling back to the default one.
+func (r *SigningKeySecretReference) SecretKey() string {
+	if r.Key == "" {
+		return "sa.key"
+	}
+	return r.Key
+}
+
+// KeyAlgorithm returns the configured key algorithm, falling back to the default one.
+func (k *SigningKey) KeyAlgorithm() SigningKeyAlgorithm {
+	if k.Algorithm == "" {
+		return SigningKeyRSA2048
+	}
+	return k.Algorithm
+}
+
+// KeyRotation configures how the service account signing key is rotated.
+// A new key is published in the JWKS together with the previous ones, and only used for signing once the propagation delay
+// has passed. A previous key is only removed once the overlap window has passed.
+type KeyRotation struct {
+	// Interval is the period after which a new signing key is generated.
+	// When it is not set, the key is only rotated on demand via Trigger.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// OverlapWindow is the period during which a replaced key is still published in the JWKS,
+	// so that tokens signed with it can still be validated.
+	// Default: "24h"
+	// +kubebuilder:default="24h"
+	// +optional
+	OverlapWindow metav1.Duration `json:"overlapWindow,omitempty"`
+
+	// PropagationDelay is the period during which a new key is published in the JWKS before it is used for signing,
+	// so that STS and the other relying parties have fetched it before they receive tokens signed with it.
+	// Default: "1h"
+	// +kubebuilder:default="1h"
+	// +optional
+	PropagationDelay metav1.Duration `json:"propagationDelay,omitempty"`
+
+	// Trigger requests an immediate rotation whenever its value is changed.
+	// Any value can be used, e.g. the current timestamp.
+	// +optional
+	Trigger string `json:"trigger,omitempty"`
+}
+
+// IsDue reports whether a new signing key should replace the active one at the given time.
+// It is never due while a pending key is waiting to replace the active one.
+func (k *KeyRotation) IsDue(status IRSASetupStatus, now time.Time) bool {
+	if k == nil || status.PendingSigningKey() != nil {
+		return false
+	}
+	if k.Trigger != "" && k.Trigger != status.LastRotationTrigger {
+		return true
+	}
+	active := status.ActiveSigningKey()
+	if k.Interval == nil || k.Interval.Duration <= 0 || active == nil {
+		return false
+	}
+	return !now.Before(active.CreatedAt.Add(k.Interval.Duration))
+}
+
+// IsPropagated reports whether a pending key has been published longer than the propagation delay.
+func (k *KeyRotation) IsPropagated(key SigningKeyStatus, now time.Time) bool {
+	if key.State != SigningKeyPending {
+		return false
+	}
+	return !now.Before(k.ActivationTime(key))
+}
+
+// ActivationTime returns the time a pending key replaces the active one.
+func (k *KeyRotation) ActivationTime(key SigningKeyStatus) time.Time {
+	return key.CreatedAt.Add(k.propagationDelay())
+}
+
+// IsExpired reports whether a retiring key has been published longer than the overlap window.
+func (k *KeyRotation) IsExpired(key SigningKeyStatus, now time.Time) bool {
+	if key.State != SigningKeyRetiring || key.RetiredAt == nil {
+		return false
+	}
+	return !now.Before(key.RetiredAt.Add(k.overlapWindow()))
+}
+
+// NextEvent returns the time until the next scheduled rotation, activation or retirement.
+// It returns zero if nothing is scheduled.
+func (k *KeyRotation) NextEvent(status IRSASetupStatus, now time.Time) time.Duration {
+	if k == nil {
+		return 0
+	}
+	var next time.Time
+	setEarlier := func(t time.Time) {
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	pending := status.PendingSigningKey()
+	if active := status.ActiveSigningKey(); pending == nil && active != nil && k.Interval != nil && k.Interval.Duration > 0 {
+		setEarlier(active.CreatedAt.Add(k.Interval.Duration))
+	}
+	if pending != nil {
+		setEarlier(k.ActivationTime(*pending))
+	}
+	for _, key := range status.SigningKeys {
+		if key.State == SigningKeyRetiring && key.RetiredAt != nil {
+			setEarlier(key.RetiredAt.Add(k.overlapWindow()))
+		}
+	}
+	if next.IsZero() {
+		return 0
+	}
+	if d := next.Sub(now); d > 0 {
+		return d
+	}
+	return time.Second
+}
+
+func (k *KeyRotation) overlapWindow() time.Duration {
+	if k == nil || k.OverlapWindow.Duration <= 0 {
+		return 24 * time.Hour
+	}
+	return k.OverlapWindow.Duration
+}
+
+func (k *KeyRotation) propagationDelay() time.Duration {
+	if k == nil || k.PropagationDelay.Duration <= 0 {
+		return time.Hour
+	}
+	return k.PropagationDelay.Duration
+}
+
+// DriftDetection configures how often the self-hosted resources are verified.
+// The discovery documents in the S3 bucket, the IAM OIDC provider and the webhook resources
+// are compared with the expected state, and repaired when they have drifted.
+type DriftDetection struct {
+	// Interval is the period between two verifications.
+	// Default: "10m"
+	// +kubebuilder:default="10m"
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+// RequeueAfter returns the period until the next verification, or zero when drift detection is disabled.
+func (d *DriftDetection) RequeueAfter() time.Duration {
+	if d == nil {
+		return 0
+	}
+	if d.Interval.Duration <= 0 {
+		return 10 * time.Minute
+	}
+	return d.Interval.Duration
+}
+
+// NodeAgent configures the DaemonSet that configures the kube-apiserver on the control plane nodes.
+// The static Pod manifest of the kube-apiserver is patched in place, so it is meant for kubeadm clusters.
+type NodeAgent struct {
+	// Image is the image of the node agent.
+	// Default: the image given to the controller by its --node-agent-image flag
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// KeyDir is the directory of the control plane nodes where the key files of the
+	// kube-system/irsa-manager-key Secret are saved.
+	// The key files are not saved when the signing key is managed outside of irsa-manager.
+	// Default: "/etc/kubernetes/pki"
+	// +optional
+	KeyDir string `json:"keyDir,omitempty"`
+
+	// ManifestPath is the path of the static Pod manifest of the kube-apiserver on the control plane nodes.
+	// Default: "/etc/kubernetes/manifests/kube-apiserver.yaml"
+	// +optional
+	ManifestPath string `json:"manifestPath,omitempty"`
+
+	// BackupDir is the directory of the control plane nodes where the manifest is copied before it is patched.
+	// It must not be the directory of the manifest, as the kubelet would run the copies as static Pods.
+	// Default: "/etc/kubernetes/irsa-manager-backup"
+	// +optional
+	BackupDir string `json:"backupDir,omitempty"`
+
+	// NodeSelector selects the control plane nodes.
+	// Default: {"node-role.kubernetes.io/control-plane": ""}
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+}
+
+// GetKeyDir returns the configured key directory, falling back to the default one.
+func (n *NodeAgent) GetKeyDir() string {
+	if n.KeyDir == "" {
+		return "/etc/kubernetes/pki"
+	}
+	return n.KeyDir
+}
+
+// GetManifestPath returns the configured manifest path, falling back to the default one.
+func (n *NodeAgent) GetManifestPath() string {
+	if n.ManifestPath == "" {
+		return "/etc/kubernetes/manifests/kube-apiserver.yaml"
+	}
+	return n.ManifestPath
+}
+
+// GetBackupDir returns the configured backup directory, falling back to the default one.
+func (n *NodeAgent) GetBackupDir() string {
+	if n.BackupDir == "" {
+		return "/etc/kubernetes/irsa-manager-backup"
+	}
+	return n.BackupDir
+}
+
+// GetNodeSelector returns the configured node selector, falling back to the default one.
+func (n *NodeAgent) GetNodeSelector() map[string]string {
+	if len(n.NodeSelector) == 0 {
+		return map[string]string{"node-role.kubernetes.io/control-plane": ""}
+	}
+	return n.NodeSelector
+}
+
+const (
+	// DefaultWebhookNamespace is the namespace of the webhook when none is configured.
+	DefaultWebhookNamespace = "kube-system"
+	// DefaultWebhookImage is the image of the webhook when none is configured.
+	DefaultWebhookImage = "amazon/amazon-eks-pod-identity-webhook:v0.5.5"
+	// DefaultWebhookImagePullPolicy is the pull policy of the webhook image when none is configured.
+	DefaultWebhookImagePullPolicy = corev1.PullIfNotPresent
+)
+
+// Webhook configures the pod-identity-webhook.
+type Webhook struct {
+	// Enabled deploys the webhook. Set it to false when the cluster already runs a pod-identity-webhook.
+	// A webhook deployed before is then removed.
+	// Default: true
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Namespace is the namespace of the webhook.
+	// Changing it moves the webhook, and issues a new serving certificate for the Service in the new namespace.
+	// Default: "kube-system"
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Image is the image of the webhook.
+	// Default: "amazon/amazon-eks-pod-identity-webhook:v0.5.5"
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ImageDigest pins the image to a digest, e.g. "sha256:<hex>".
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	// +optional
+	ImageDigest string `json:"imageDigest,omitempty"`
+
+	// ImagePullPolicy is the pull policy of the image.
+	// Default: "IfNotPresent"
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ImagePullSecrets are the Secrets in the namespace of the webhook used to pull the image.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Replicas is the number of webhook Pods.
+	// A PodDisruptionBudget allowing one unavailable Pod is created when it is greater than one.
+	// Default: 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Resources are the compute resources of the webhook container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// NodeSelector selects the nodes of the webhook Pods.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are the tolerations of the webhook Pods.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity is the affinity of the webhook Pods.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// PriorityClassName is the priority class of the webhook Pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Certificate configures how the serving certificate of the webhook is obtained.
+	// +optional
+	Certificate WebhookCertificate `json:"certificate,omitempty"`
+}
+
+// IsEnabled reports whether the webhook is deployed.
+func (w *Webhook) IsEnabled() bool {
+	return w == nil || w.Enabled == nil || *w.Enabled
+}
+
+// GetNamespace returns the configured namespace, falling back to the default one.
+func (w *Webhook) GetNamespace() string {
+	if w == nil || w.Namespace == "" {
+		return DefaultWebhookNamespace
+	}
+	return w.Namespace
+}
+
+// GetImage returns the configured image, pinned to the digest if any, falling back to the default one.
+func (w *Webhook) GetImage() string {
+	image := DefaultWebhookImage
+	if w == nil {
+		return image
+	}
+	if w.Image != "" {
+		image = w.Image
+	}
+	if w.ImageDigest != "" {
+		image = image + "@" + w.ImageDigest
+	}
+	return image
+}
+
+// GetImagePullPolicy returns the configured pull policy, falling back to the default one.
+func (w *Webhook) GetImagePullPolicy() corev1.PullPolicy {
+	if w == nil || w.ImagePullPolicy == "" {
+		return DefaultWebhookImagePullPolicy
+	}
+	return w.ImagePullPolicy
+}
+
+// GetReplicas returns the configured number of replicas, falling back to the default one.
+func (w *Webhook) GetReplicas() int32 {
+	if w == nil || w.Replicas == nil {
+		return 1
+	}
+	return *w.Replicas
+}
+
+// GetCertificate returns the configured serving certificate, falling back to a self-signed one.
+func (w *Webhook) GetCertificate() WebhookCertificate {
+	if w == nil {
+		return WebhookCertificate{}
+	}
+	return w.Certificate
+}
+
+// WebhookCertificate configures how the serving certificate of the webhook is obtained.
+type WebhookCertificate struct {
+	// Source specifies how the serving certificate is obtained.
+	// Possible values:
+	//   - "SelfSigned": irsa-manager generates a self-signed certificate and renews it 30 days before it expires.
+	//   - "CertManager": irsa-manager creates a cert-manager Certificate, and cert-manager issues it
+	//     and injects its CA into the MutatingWebhookConfiguration. This requires cert-manager.
+	//   - "CertificateSigningRequest": irsa-manager requests the certificate from a signer through the certificates.k8s.io API.
+	// Changing the source issues a new certificate.
+	// Default: "SelfSigned"
+	// +optional
+	Source WebhookCertificateSource `json:"source,omitempty"`
+
+	// CertManager configures the cert-manager Certificate.
+	// Only applicable when Source is "CertManager".
+	// +optional
+	CertManager *CertManagerCertificate `json:"certManager,omitempty"`
+
+	// CertificateSigningRequest configures the CertificateSigningRequest.
+	// Required when Source is "CertificateSigningRequest".
+	// +optional
+	CertificateSigningRequest *WebhookCertificateSigningRequest `json:"certificateSigningRequest,omitempty"`
+}
+
+// GetSource returns the configured source, falling back to the default one.
+func (c WebhookCertificate) GetSource() WebhookCertificateSource {
+	if c.Source == "" {
+		return WebhookCertificateSelfSigned
+	}
+	return c.Source
+}
+
+// +kubebuilder:default=SelfSigned
+// +kubebuilder:validation:Enum=SelfSigned;CertManager;CertificateSigningRequest
+type WebhookCertificateSource string
+
+const (
+	WebhookCertificateSelfSigned                = WebhookCertificateSource("SelfSigned")
+	WebhookCertificateCertManager               = WebhookCertificateSource("CertManager")
+	WebhookCertificateCertificateSigningRequest = WebhookCertificateSource("CertificateSigningRequest")
+)
+
+// CertManagerCertificate configures the cert-manager Certificate of the webhook.
+type CertManagerCertificate struct {
+	// IssuerRef references the cert-manager issuer of the certificate.
+	// When it is not set, irsa-manager creates a self-signed Issuer next to the webhook.
+	// +optional
+	IssuerRef *CertManagerIssuerReference `json:"issuerRef,omitempty"`
+}
+
+// CertManagerIssuerReference references a cert-manager issuer.
+type CertManagerIssuerReference struct {
+	// Name is the name of the issuer.
+	Name string `json:"name"`
+
+	// Kind is the kind of the issuer, e.g. "Issuer" or "ClusterIssuer".
+	// An Issuer has to be in the namespace of the webhook.
+	// Default: "Issuer"
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Group is the API group of the issuer.
+	// Default: "cert-manager.io"
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
+// WebhookCertificateSigningRequest configures the CertificateSigningRequest of the webhook.
+type WebhookCertificateSigningRequest struct {
+	// SignerName is the signer requested to sign the certificate, e.g. "example.com/webhook-serving".
+	// The CertificateSigningRequest has to be approved, e.g. by an approver of the signer, before it is signed.
+	SignerName string `json:"signerName"`
+
+	// CABundle is the PEM encoded CA certificate of the signer, set in the MutatingWebhookConfiguration.
+	// When it is not set, the certificate chain issued by the signer is trusted, along with the replaced one after a renewal.
+	// +optional
+	CABundle string `json:"caBundle,omitempty"`
+
+	// ExpirationSeconds is the requested lifetime of the certificate.
+	// The signer may issue a certificate with another lifetime.
+	// +kubebuilder:validation:Minimum=600
+	// +optional
+	ExpirationSeconds *int32 `json:"expirationSeconds,omitempty"`
 }
 
 // IRSASetupStatus defines the observed state of IRSASetup
 type IRSASetupStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// SigningKeys lists the service account signing keys currently published in the JWKS.
+	SigningKeys []SigningKeyStatus `json:"signingKeys,omitempty"`
+
+	// LastRotationTrigger is the value of KeyRotation.Trigger that has been handled last.
+	LastRotationTrigger string `json:"lastRotationTrigger,omitempty"`
+
+	// CloudFront is the CloudFront distribution serving the OIDC discovery information.
+	// +optional
+	CloudFront *CloudFrontStatus `json:"cloudFront,omitempty"`
+
+	// OIDCProvider is the state of the IAM OIDC provider registered by irsa-manager.
+	// +optional
+	OIDCProvider *OIDCProviderStatus `json:"oidcProvider,omitempty"`
+
+	// RoleAccountOIDCProviders are the IAM OIDC providers of the issuer in the AWS accounts of the deployer roles of the IRSAs.
+	// A provider is deleted once no IRSA bound to the IRSASetup uses its account, and with the IRSASetup, when Cleanup is enabled.
+	// +optional
+	RoleAccountOIDCProviders []RoleAccountOIDCProviderStatus `json:"roleAccountOIDCProviders,omitempty"`
+
+	// Replicas is the publish state of the OIDC discovery information in each replica bucket.
+	// +optional
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+
+	// APIServer is the configuration of the kube-apiserver required to issue service account tokens for the issuer.
+	// +optional
+	APIServer *APIServerStatus `json:"apiServer,omitempty"`
+
+	// NodeAgents is the progress of the node agent on each control plane node.
+	// +optional
+	NodeAgents []NodeAgentStatus `json:"nodeAgents,omitempty"`
+
+	// Webhook is the deployed pod-identity-webhook and its serving certificate.
+	// +optional
+	Webhook *WebhookStatus `json:"webhook,omitempty"`
+}
+
+// WebhookStatus describes the deployed pod-identity-webhook and its serving certificate.
+type WebhookStatus struct {
+	// Namespace is the namespace the webhook is deployed in.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// ObservedGeneration is the generation of the IRSASetup the webhook resources have last been applied for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// CertificateSource is the source the serving certificate has been obtained from.
+	// +optional
+	CertificateSource WebhookCertificateSource `json:"certificateSource,omitempty"`
+
+	// CertificateNotAfter is the expiry of the serving certificate.
+	// +optional
+	CertificateNotAfter *metav1.Time `json:"certificateNotAfter,omitempty"`
+
+	// CertificateRenewalTime is the time from which the serving certificate is renewed.
+	// +optional
+	CertificateRenewalTime *metav1.Time `json:"certificateRenewalTime,omitempty"`
+
+	// Message describes why the serving certificate has not been issued yet.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// NodeAgentStatus describes the progress of the node agent on a control plane node.
+type NodeAgentStatus struct {
+	// NodeName is the name of the node.
+	NodeName string `json:"nodeName"`
+
+	// Configured reports whether the key files and the kube-apiserver manifest of the node are up to date.
+	Configured bool `json:"configured"`
+
+	// LastUpdateTime is the last time the node agent has reported its progress.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+
+	// Message describes the progress or the failure of the node agent.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// APIServerStatus describes the configuration of the kube-apiserver required to issue service account tokens for the issuer.
+type APIServerStatus struct {
+	// Flags are the flags of the kube-apiserver, with the key files of the kube-system/irsa-manager-key Secret
+	// saved in /etc/kubernetes/pki as recommended for kubeadm.
+	// The key file flags are omitted when the signing key is managed outside of irsa-manager.
+	Flags []string `json:"flags,omitempty"`
+
+	// ConfigMapName is the name of the ConfigMap in the namespace of the IRSASetup holding the flags
+	// and the configuration snippets for kubeadm, k3s, RKE2 and Talos.
+	ConfigMapName string `json:"configMapName,omitempty"`
+}
+
+// ReplicaStatus describes the publish state of the OIDC discovery information in a replica bucket.
+type ReplicaStatus struct {
+	// Region is the AWS region where the S3 bucket is located.
+	Region string `json:"region"`
+
+	// BucketName is the name of the S3 bucket.
+	BucketName string `json:"bucketName"`
+
+	// Synced reports whether the OIDC discovery information in the bucket is up to date.
+	Synced bool `json:"synced"`
+
+	// LastSyncedTime is the last time the OIDC discovery information in the bucket was found up to date.
+	// +optional
+	LastSyncedTime *metav1.Time `json:"lastSyncedTime,omitempty"`
+
+	// Message describes why the OIDC discovery information in the bucket is not up to date.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// CloudFrontStatus describes the CloudFront distribution serving the OIDC discovery information.
+type CloudFrontStatus struct {
+	// DistributionID is the ID of the distribution.
+	DistributionID string `json:"distributionId"`
+
+	// DistributionArn is the ARN of the distribution, which is allowed to read the S3 bucket.
+	DistributionArn string `json:"distributionArn"`
+
+	// DomainName is the domain name of the distribution, e.g. "d111111abcdef8.cloudfront.net".
+	DomainName string `json:"domainName"`
+
+	// OriginAccessControlID is the ID of the Origin Access Control created by irsa-manager.
+	// It is only set when the distribution is created by irsa-manager.
+	// +optional
+	OriginAccessControlID string `json:"originAccessControlId,omitempty"`
+}
+
+// OIDCProviderStatus describes the IAM OIDC provider registered by irsa-manager.
+type OIDCProviderStatus struct {
+	// ClientIDs are the client IDs registered by irsa-manager.
+	// The ones that are no longer wanted are removed from the provider, while the others are left as they are.
+	// +optional
+	ClientIDs []string `json:"clientIDs,omitempty"`
+
+	// IssuerURL is the issuer URL the Thumbprint has been computed for.
+	// +optional
+	IssuerURL string `json:"issuerURL,omitempty"`
+
+	// Thumbprint is the thumbprint computed from the certificate served by the issuer.
+	// It is computed again when the issuer URL changes and once an hour, so that a renewed certificate chain is registered.
+	// +optional
+	Thumbprint string `json:"thumbprint,omitempty"`
+
+	// ThumbprintCheckedAt is the time the Thumbprint has last been computed.
+	// +optional
+	ThumbprintCheckedAt *metav1.Time `json:"thumbprintCheckedAt,omitempty"`
+}
+
+// RoleAccountOIDCProviderStatus is the IAM OIDC provider of the issuer in the AWS account of a deployer role.
+type RoleAccountOIDCProviderStatus struct {
+	// AccountID is the AWS account the provider is in.
+	AccountID string `json:"accountId"`
+
+	// IssuerHostPath is the issuer URL of the provider without the scheme.
+	IssuerHostPath string `json:"issuerHostPath"`
+
+	// DeployerRoleArn is the role assumed to delete the provider.
+	DeployerRoleArn string `json:"deployerRoleArn"`
+
+	// ExternalID is the external ID passed when assuming DeployerRoleArn.
+	// +optional
+	ExternalID string `json:"externalId,omitempty"`
+}
+
+// SameProvider reports whether both are the provider of the same issuer in the same account.
+func (s RoleAccountOIDCProviderStatus) SameProvider(other RoleAccountOIDCProviderStatus) bool {
+	return s.AccountID == other.AccountID && s.IssuerHostPath == other.IssuerHostPath
+}
+
+// IsManaged reports whether the distribution was created by irsa-manager.
+func (s *CloudFrontStatus) IsManaged() bool {
+	return s != nil && s.OriginAccessControlID != ""
+}
+
+// ActiveSigningKey returns the signing key that is currently used for signing tokens.
+func (s *IRSASetupStatus) ActiveSigningKey() *SigningKeyStatus {
+	for i := range s.SigningKeys {
+		if s.SigningKeys[i].State == SigningKeyActive {
+			return &s.SigningKeys[i]
+		}
+	}
+	return nil
+}
+
+// PendingSigningKey returns the signing key that is published but not used for signing yet.
+func (s *IRSASetupStatus) PendingSigningKey() *SigningKeyStatus {
+	for i := range s.SigningKeys {
+		if s.SigningKeys[i].State == SigningKeyPending {
+			return &s.SigningKeys[i]
+		}
+	}
+	return nil
+}
+
+// LatestSigningKeyGeneration returns the highest generation of the published signing keys.
+func (s *IRSASetupStatus) LatestSigningKeyGeneration() int64 {
+	var generation int64
+	for _, key := range s.SigningKeys {
+		if key.Generation > generation {
+			generation = key.Generation
+		}
+	}
+	return generation
+}
+
+// SigningKeyStatus describes a generation of the service account signing key.
+type SigningKeyStatus struct {
+	// KeyID is the key ID ("kid") of the key published in the JWKS.
+	KeyID string `json:"keyID"`
+
+	// Generation is incremented each time a new signing key is generated.
+	Generation int64 `json:"generation"`
+
+	// Algorithm is the algorithm of the key.
+	// +optional
+	Algorithm SigningKeyAlgorithm `json:"algorithm,omitempty"`
+
+	// State is "Active" for the key used for signing, "Pending" for a new key that is published
+	// but not used for signing until the propagation delay has passed, or "Retiring" for a replaced key
+	// that is still published until the overlap window has passed.
+	State SigningKeyState `json:"state"`
+
+	// CreatedAt is the time the key was generated and published.
+	CreatedAt metav1.Time `json:"createdAt"`
+
+	// RetiredAt is the time the key was replaced by a newer one.
+	// +optional
+	RetiredAt *metav1.Time `json:"retiredAt,omitempty"`
+}
+
+type SigningKeyState string
+
+const (
+	SigningKeyActive   = SigningKeyState("Active")
+	SigningKeyPending  = SigningKeyState("Pending")
+	SigningKeyRetiring = SigningKeyState("Retiring")
+)
+
+// DefaultAudience returns the audience of the service account tokens of the IRSAs that do not set one.
+func (in *IRSASetup) DefaultAudience() string {
+	if in.Spec.DefaultAudience != "" {
+		return in.Spec.DefaultAudience
+	}
+	return DefaultTokenAudience
+}
+
+// ClientIDs returns the client IDs that are registered in the IAM OIDC provider by the IRSASetup,
+// which are "sts.amazonaws.com", the default audience and the additional audiences, without duplicates.
+// In the "eks" mode, the provider is managed by EKS and only has "sts.amazonaws.com".
+func (in *IRSASetup) ClientIDs() []string {
+	clientIDs := []string{DefaultTokenAudience}
+	if in.Spec.Mode == ModeEks {
+		return clientIDs
+	}
+	for _, audience := range append([]string{in.DefaultAudience()}, in.Spec.Audiences...) {
+		if !slices.Contains(clientIDs, audience) {
+			clientIDs = append(clientIDs, audience)
+		}
+	}
+	return clientIDs
+}
+
+// AllowsNamespace reports whether the IRSAs in the namespace may reference the IRSASetup with setupRef.
+func (in *IRSASetup) AllowsNamespace(namespace string) bool {
+	return namespace == in.Namespace || slices.Contains(in.Spec.AllowedNamespaces, namespace)
 }
 
 // GetStatusConditions returns a pointer to the Status.Conditions slice
@@ -129,6 +1239,51 @@ func HasConditionReason(cond *metav1.Condition, reasons ...string) bool {
 	return false
 }
 
+// SetupStatusKeyRotation sets the KeyRotation condition.
+func SetupStatusKeyRotation(irsa IRSASetup, status metav1.ConditionStatus, reason, message string) IRSASetup {
+	newCondition := metav1.Condition{
+		Type:    KeyRotationCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+	apimeta.SetStatusCondition(irsa.GetStatusConditions(), newCondition)
+	return irsa
+}
+
+func SetupStatusSigningKeySynced(irsa IRSASetup, status metav1.ConditionStatus, reason, message string) IRSASetup {
+	newCondition := metav1.Condition{
+		Type:    SigningKeySyncedCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+	apimeta.SetStatusCondition(irsa.GetStatusConditions(), newCondition)
+	return irsa
+}
+
+func SetupStatusTokenIssuanceVerified(irsa IRSASetup, status metav1.ConditionStatus, reason, message string) IRSASetup {
+	newCondition := metav1.Condition{
+		Type:    TokenIssuanceVerifiedCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+	apimeta.SetStatusCondition(irsa.GetStatusConditions(), newCondition)
+	return irsa
+}
+
+func SetupStatusIssuerCertificateVerified(irsa IRSASetup, status metav1.ConditionStatus, reason, message string) IRSASetup {
+	newCondition := metav1.Condition{
+		Type:    IssuerCertificateVerifiedCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+	apimeta.SetStatusCondition(irsa.GetStatusConditions(), newCondition)
+	return irsa
+}
+
 func IsReadyConditionTrue(irsa IRSASetup) bool {
 	return apimeta.IsStatusConditionTrue(irsa.Status.Conditions, ReadyCondition)
 }
@@ -136,11 +1291,38 @@ func IsReadyConditionTrue(irsa IRSASetup) bool {
 type SelfhostedConditionReason string
 
 const (
-	SelfHostedReasonFailedWebhook SelfhostedConditionReason = "SelfHostedSetupFailedWebhookCreation"
-	SelfHostedReasonFailedOidc    SelfhostedConditionReason = "SelfHostedSetupFailedOidcCreation"
-	SelfHostedReasonFailedIssuer  SelfhostedConditionReason = "SelfHostedSetupFailedIssuer"
-	SelfHostedReasonFailedKeys    SelfhostedConditionReason = "SelfHostedSetupFailedKeysCreation"
-	SelfHostedReasonReady         SelfhostedConditionReason = "SelfHostedSetupReady"
+	SelfHostedReasonFailedWebhook     SelfhostedConditionReason = "SelfHostedSetupFailedWebhookCreation"
+	SelfHostedReasonFailedOidc        SelfhostedConditionReason = "SelfHostedSetupFailedOidcCreation"
+	SelfHostedReasonFailedIssuer      SelfhostedConditionReason = "SelfHostedSetupFailedIssuer"
+	SelfHostedReasonFailedCloudFront  SelfhostedConditionReason = "SelfHostedSetupFailedCloudFront"
+	SelfHostedReasonFailedKeys        SelfhostedConditionReason = "SelfHostedSetupFailedKeysCreation"
+	SelfHostedReasonFailedSigningKey  SelfhostedConditionReason = "SelfHostedSetupFailedSigningKey"
+	SelfHostedReasonFailedCredentials SelfhostedConditionReason = "SelfHostedSetupFailedCredentials"
+	SelfHostedReasonReady             SelfhostedConditionReason = "SelfHostedSetupReady"
+
+	SelfHostedReasonKeyRotated          SelfhostedConditionReason = "SelfHostedKeyRotated"
+	SelfHostedReasonFailedKeyRotation   SelfhostedConditionReason = "SelfHostedFailedKeyRotation"
+	SelfHostedReasonFailedKeyRetirement SelfhostedConditionReason = "SelfHostedFailedKeyRetirement"
+
+	SelfHostedReasonDriftFailedSigningKey SelfhostedConditionReason = "SelfHostedDriftFailedSigningKey"
+	SelfHostedReasonDriftFailedDiscovery  SelfhostedConditionReason = "SelfHostedDriftFailedDiscovery"
+	SelfHostedReasonDriftFailedOidc       SelfhostedConditionReason = "SelfHostedDriftFailedOidc"
+	SelfHostedReasonDriftFailedWebhook    SelfhostedConditionReason = "SelfHostedDriftFailedWebhook"
+
+	SelfHostedReasonSigningKeySynced      SelfhostedConditionReason = "SelfHostedSigningKeySynced"
+	SelfHostedReasonSigningKeyMismatch    SelfhostedConditionReason = "SelfHostedSigningKeyMismatch"
+	SelfHostedReasonFailedSigningKeyCheck SelfhostedConditionReason = "SelfHostedFailedSigningKeyCheck"
+
+	SelfHostedReasonTokenIssuanceVerified    SelfhostedConditionReason = "SelfHostedTokenIssuanceVerified"
+	SelfHostedReasonTokenMalformed           SelfhostedConditionReason = "SelfHostedTokenMalformed"
+	SelfHostedReasonTokenIssuerMismatch      SelfhostedConditionReason = "SelfHostedTokenIssuerMismatch"
+	SelfHostedReasonTokenUnknownKeyID        SelfhostedConditionReason = "SelfHostedTokenUnknownKeyID"
+	SelfHostedReasonTokenBadSignature        SelfhostedConditionReason = "SelfHostedTokenBadSignature"
+	SelfHostedReasonFailedTokenIssuanceCheck SelfhostedConditionReason = "SelfHostedFailedTokenIssuanceCheck"
+
+	SelfHostedReasonIssuerCertificateVerified          SelfhostedConditionReason = "SelfHostedIssuerCertificateVerified"
+	SelfHostedReasonIssuerCertificateTrustedOnFirstUse SelfhostedConditionReason = "SelfHostedIssuerCertificateTrustedOnFirstUse"
+	SelfHostedReasonIssuerCertificateUnverified        SelfhostedConditionReason = "SelfHostedIssuerCertificateUnverified"
 )
 
 type EksConditionReason string
@@ -0,0 +1,364 @@
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestKeyRotation_IsDue(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	activeSince := func(d time.Duration) IRSASetupStatus {
+		return IRSASetupStatus{
+			SigningKeys: []SigningKeyStatus{
+				{KeyID: "active", State: SigningKeyActive, CreatedAt: metav1.NewTime(now.Add(-d))},
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		rotation *KeyRotation
+		status   IRSASetupStatus
+		expected bool
+	}{
+		{
+			name:     "no rotation policy",
+			rotation: nil,
+			status:   activeSince(365 * 24 * time.Hour),
+			expected: false,
+		},
+		{
+			name:     "interval elapsed",
+			rotation: &KeyRotation{Interval: &metav1.Duration{Duration: 24 * time.Hour}},
+			status:   activeSince(25 * time.Hour),
+			expected: true,
+		},
+		{
+			name:     "interval not elapsed",
+			rotation: &KeyRotation{Interval: &metav1.Duration{Duration: 24 * time.Hour}},
+			status:   activeSince(23 * time.Hour),
+			expected: false,
+		},
+		{
+			name:     "trigger changed",
+			rotation: &KeyRotation{Trigger: "2"},
+			status: func() IRSASetupStatus {
+				s := activeSince(time.Hour)
+				s.LastRotationTrigger = "1"
+				return s
+			}(),
+			expected: true,
+		},
+		{
+			name:     "pending key waiting for its propagation",
+			rotation: &KeyRotation{Interval: &metav1.Duration{Duration: 24 * time.Hour}, Trigger: "2"},
+			status: func() IRSASetupStatus {
+				s := activeSince(25 * time.Hour)
+				s.LastRotationTrigger = "1"
+				s.SigningKeys = append(s.SigningKeys, SigningKeyStatus{KeyID: "pending", State: SigningKeyPending, CreatedAt: metav1.NewTime(now)})
+				return s
+			}(),
+			expected: false,
+		},
+		{
+			name:     "trigger already handled",
+			rotation: &KeyRotation{Trigger: "1"},
+			status: func() IRSASetupStatus {
+				s := activeSince(time.Hour)
+				s.LastRotationTrigger = "1"
+				return s
+			}(),
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.rotation.IsDue(tt.status, now))
+		})
+	}
+}
+
+func TestKeyRotation_IsExpired(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	retiredAt := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(-d))
+		return &t
+	}
+	tests := []struct {
+		name     string
+		rotation *KeyRotation
+		key      SigningKeyStatus
+		expected bool
+	}{
+		{
+			name:     "active key never expires",
+			rotation: &KeyRotation{OverlapWindow: metav1.Duration{Duration: time.Hour}},
+			key:      SigningKeyStatus{State: SigningKeyActive},
+			expected: false,
+		},
+		{
+			name:     "overlap window passed",
+			rotation: &KeyRotation{OverlapWindow: metav1.Duration{Duration: time.Hour}},
+			key:      SigningKeyStatus{State: SigningKeyRetiring, RetiredAt: retiredAt(2 * time.Hour)},
+			expected: true,
+		},
+		{
+			name:     "within overlap window",
+			rotation: &KeyRotation{OverlapWindow: metav1.Duration{Duration: time.Hour}},
+			key:      SigningKeyStatus{State: SigningKeyRetiring, RetiredAt: retiredAt(30 * time.Minute)},
+			expected: false,
+		},
+		{
+			name:     "default overlap window",
+			rotation: &KeyRotation{},
+			key:      SigningKeyStatus{State: SigningKeyRetiring, RetiredAt: retiredAt(23 * time.Hour)},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.rotation.IsExpired(tt.key, now))
+		})
+	}
+}
+
+func TestKeyRotation_IsPropagated(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	publishedAt := func(d time.Duration) metav1.Time {
+		return metav1.NewTime(now.Add(-d))
+	}
+	tests := []struct {
+		name     string
+		rotation *KeyRotation
+		key      SigningKeyStatus
+		expected bool
+	}{
+		{
+			name:     "active key",
+			rotation: &KeyRotation{PropagationDelay: metav1.Duration{Duration: time.Hour}},
+			key:      SigningKeyStatus{State: SigningKeyActive, CreatedAt: publishedAt(2 * time.Hour)},
+			expected: false,
+		},
+		{
+			name:     "propagation delay passed",
+			rotation: &KeyRotation{PropagationDelay: metav1.Duration{Duration: time.Hour}},
+			key:      SigningKeyStatus{State: SigningKeyPending, CreatedAt: publishedAt(time.Hour)},
+			expected: true,
+		},
+		{
+			name:     "within propagation delay",
+			rotation: &KeyRotation{PropagationDelay: metav1.Duration{Duration: time.Hour}},
+			key:      SigningKeyStatus{State: SigningKeyPending, CreatedAt: publishedAt(59 * time.Minute)},
+			expected: false,
+		},
+		{
+			name:     "default propagation delay",
+			rotation: &KeyRotation{},
+			key:      SigningKeyStatus{State: SigningKeyPending, CreatedAt: publishedAt(30 * time.Minute)},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.rotation.IsPropagated(tt.key, now))
+		})
+	}
+}
+
+func TestKeyRotation_NextEvent(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	retiredAt := metav1.NewTime(now.Add(-time.Hour))
+	status := IRSASetupStatus{
+		SigningKeys: []SigningKeyStatus{
+			{KeyID: "active", State: SigningKeyActive, CreatedAt: metav1.NewTime(now.Add(-time.Hour))},
+			{KeyID: "retiring", State: SigningKeyRetiring, RetiredAt: &retiredAt},
+		},
+	}
+	tests := []struct {
+		name     string
+		rotation *KeyRotation
+		expected time.Duration
+	}{
+		{
+			name:     "no rotation policy",
+			rotation: nil,
+			expected: 0,
+		},
+		{
+			name: "retirement comes first",
+			rotation: &KeyRotation{
+				Interval:      &metav1.Duration{Duration: 24 * time.Hour},
+				OverlapWindow: metav1.Duration{Duration: 2 * time.Hour},
+			},
+			expected: time.Hour,
+		},
+		{
+			name: "rotation comes first",
+			rotation: &KeyRotation{
+				Interval:      &metav1.Duration{Duration: 3 * time.Hour},
+				OverlapWindow: metav1.Duration{Duration: 48 * time.Hour},
+			},
+			expected: 2 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.rotation.NextEvent(status, now))
+		})
+	}
+}
+
+func TestKeyRotation_NextEventPending(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	status := IRSASetupStatus{
+		SigningKeys: []SigningKeyStatus{
+			{KeyID: "active", State: SigningKeyActive, CreatedAt: metav1.NewTime(now.Add(-48 * time.Hour))},
+			{KeyID: "pending", State: SigningKeyPending, CreatedAt: metav1.NewTime(now.Add(-20 * time.Minute))},
+		},
+	}
+	rotation := &KeyRotation{
+		Interval:         &metav1.Duration{Duration: 24 * time.Hour},
+		PropagationDelay: metav1.Duration{Duration: 30 * time.Minute},
+	}
+	// the overdue interval is ignored while the pending key waits for its activation
+	assert.Equal(t, 10*time.Minute, rotation.NextEvent(status, now))
+}
+
+func TestDriftDetection_RequeueAfter(t *testing.T) {
+	tests := []struct {
+		name      string
+		detection *DriftDetection
+		expected  time.Duration
+	}{
+		{
+			name:      "disabled",
+			detection: nil,
+			expected:  0,
+		},
+		{
+			name:      "default interval",
+			detection: &DriftDetection{},
+			expected:  10 * time.Minute,
+		},
+		{
+			name:      "custom interval",
+			detection: &DriftDetection{Interval: metav1.Duration{Duration: time.Hour}},
+			expected:  time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.detection.RequeueAfter())
+		})
+	}
+}
+
+func TestWebhook_Image(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		name               string
+		webhook            *Webhook
+		expectedImage      string
+		expectedPullPolicy corev1.PullPolicy
+	}{
+		{
+			name:               "default",
+			webhook:            nil,
+			expectedImage:      "amazon/amazon-eks-pod-identity-webhook:v0.5.5",
+			expectedPullPolicy: corev1.PullIfNotPresent,
+		},
+		{
+			name:               "custom image",
+			webhook:            &Webhook{Image: "registry.example.com/pod-identity-webhook:v0.5.5"},
+			expectedImage:      "registry.example.com/pod-identity-webhook:v0.5.5",
+			expectedPullPolicy: corev1.PullIfNotPresent,
+		},
+		{
+			name:               "default image pinned to a digest",
+			webhook:            &Webhook{ImageDigest: digest},
+			expectedImage:      "amazon/amazon-eks-pod-identity-webhook:v0.5.5@" + digest,
+			expectedPullPolicy: corev1.PullIfNotPresent,
+		},
+		{
+			name:               "custom pull policy",
+			webhook:            &Webhook{Image: "registry.example.com/pod-identity-webhook:v0.5.5", ImagePullPolicy: corev1.PullNever},
+			expectedImage:      "registry.example.com/pod-identity-webhook:v0.5.5",
+			expectedPullPolicy: corev1.PullNever,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedImage, tt.webhook.GetImage())
+			assert.Equal(t, tt.expectedPullPolicy, tt.webhook.GetImagePullPolicy())
+		})
+	}
+}
+
+func TestIRSASetup_ClientIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     IRSASetupSpec
+		expected []string
+	}{
+		{
+			name:     "default",
+			expected: []string{"sts.amazonaws.com"},
+		},
+		{
+			name: "default audience and audiences",
+			spec: IRSASetupSpec{
+				DefaultAudience: "cluster.example.com",
+				Audiences:       []string{"vault.example.com", "sts.amazonaws.com", "cluster.example.com"},
+			},
+			expected: []string{"sts.amazonaws.com", "cluster.example.com", "vault.example.com"},
+		},
+		{
+			name: "eks",
+			spec: IRSASetupSpec{
+				Mode:            ModeEks,
+				DefaultAudience: "cluster.example.com",
+			},
+			expected: []string{"sts.amazonaws.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup := &IRSASetup{Spec: tt.spec}
+			assert.Equal(t, tt.expected, setup.ClientIDs())
+		})
+	}
+}
+
+func TestInClusterDiscovery_GetImage(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		name      string
+		inCluster InClusterDiscovery
+		expected  string
+	}{
+		{
+			name:      "default",
+			inCluster: InClusterDiscovery{},
+			expected:  "nginxinc/nginx-unprivileged:1.27.3-alpine",
+		},
+		{
+			name:      "custom image",
+			inCluster: InClusterDiscovery{Image: "registry.example.com/nginx-unprivileged:1.27.3-alpine"},
+			expected:  "registry.example.com/nginx-unprivileged:1.27.3-alpine",
+		},
+		{
+			name:      "default image pinned to a digest",
+			inCluster: InClusterDiscovery{ImageDigest: digest},
+			expected:  "nginxinc/nginx-unprivileged:1.27.3-alpine@" + digest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.inCluster.GetImage())
+		})
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -35,6 +36,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
 	"github.com/kkb0318/irsa-manager/internal/controller"
 	//+kubebuilder:scaffold:imports
 )
@@ -56,6 +58,7 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var secureMetrics bool
+	var nodeAgentImage string
 	var enableHTTP2 bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -66,6 +69,8 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&nodeAgentImage, "node-agent-image", os.Getenv("NODE_AGENT_IMAGE"),
+		"The image of the node agents of the IRSASetups that do not set one.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -122,16 +127,27 @@ func main() {
 		os.Exit(1)
 	}
 
+	apiServerClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create kube-apiserver client")
+		os.Exit(1)
+	}
+	// the clients of the IRSASetups with credentials are shared with the IRSAs bound to them
+	awsClientCache := awsclient.NewAwsClientCache(awsclient.NewAwsClient)
 	if err = (&controller.IRSASetupReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		AwsClientCache:  awsClientCache,
+		APIServerClient: apiServerClient.RESTClient(),
+		NodeAgentImage:  nodeAgentImage,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "IRSASetup")
 		os.Exit(1)
 	}
 	if err = (&controller.IRSAReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		AwsClientCache: awsClientCache,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "IRSA")
 		os.Exit(1)
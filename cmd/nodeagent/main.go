@@ -0,0 +1,116 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The node agent saves the key files and sets the flags of the kube-apiserver on a control plane node.
+// It is deployed as a DaemonSet by the IRSASetup controller.
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/kkb0318/irsa-manager/internal/selfhosted/nodeagent"
+)
+
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func main() {
+	agent := &nodeagent.Agent{Now: time.Now}
+	var apiServerFlags stringsFlag
+	var interval time.Duration
+	var leaseDuration time.Duration
+	flag.StringVar(&agent.KeyDir, "key-dir", "", "The directory where the key files are saved.")
+	flag.StringVar(&agent.SecretDir, "secret-dir", "", "The directory where the irsa-manager-key Secret is mounted. "+
+		"The key files are not saved when it is empty.")
+	flag.StringVar(&agent.ManifestPath, "manifest-path", "/etc/kubernetes/manifests/kube-apiserver.yaml",
+		"The static Pod manifest of the kube-apiserver.")
+	flag.StringVar(&agent.BackupDir, "backup-dir", "/etc/kubernetes/irsa-manager-backup",
+		"The directory where the manifest is copied before it is patched.")
+	flag.Var(&apiServerFlags, "apiserver-flag", "A flag set in the manifest, e.g. --apiserver-flag=--api-audiences=sts.amazonaws.com. "+
+		"It can be repeated.")
+	flag.DurationVar(&interval, "interval", time.Minute, "The period between two verifications.")
+	flag.DurationVar(&agent.RestartTimeout, "restart-timeout", 5*time.Minute,
+		"How long the kube-apiserver may take to be restarted and ready again after its manifest has been patched.")
+	flag.DurationVar(&agent.PollInterval, "poll-interval", time.Second,
+		"The period between two readiness checks of the kube-apiserver while waiting for its restart.")
+	flag.DurationVar(&leaseDuration, "lease-duration", 10*time.Minute,
+		"How long the Lease serializing the restarts of the kube-apiserver is kept by a node agent that stopped renewing it.")
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+	agent.APIServerFlags = apiServerFlags
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	log := ctrl.Log.WithName("nodeagent")
+
+	kubeClient, err := client.New(ctrl.GetConfigOrDie(), client.Options{})
+	if err != nil {
+		log.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+	nodeName := os.Getenv("NODE_NAME")
+	// the kube-apiservers of the control plane nodes are restarted one at a time
+	agent.Lock = &nodeagent.LeaseLock{
+		Client:   kubeClient,
+		Lease:    nodeagent.LeaseName,
+		Identity: nodeName,
+		Duration: leaseDuration,
+		Now:      time.Now,
+	}
+	agent.Ready = nodeagent.ReadyzProbe
+	ctx := ctrl.SetupSignalHandler()
+	for {
+		report := nodeagent.Report{Configured: true, LastUpdateTime: metav1.Now()}
+		patched, err := agent.Sync(ctx)
+		switch {
+		case errors.Is(err, nodeagent.ErrRolloutLocked):
+			log.Info("waiting for another node to restart its kube-apiserver", "node", nodeName)
+			report.Configured = false
+			report.Message = err.Error()
+		case err != nil:
+			log.Error(err, "failed to configure the kube-apiserver", "node", nodeName)
+			report.Configured = false
+			report.Message = err.Error()
+		case patched:
+			log.Info("patched the kube-apiserver manifest", "node", nodeName)
+		}
+		if err := nodeagent.ReportStatus(ctx, kubeClient, nodeName, report); err != nil {
+			log.Error(err, "failed to report the status")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
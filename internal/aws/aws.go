@@ -3,9 +3,12 @@ package aws
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"path"
 	"slices"
 	"strings"
 
@@ -16,6 +19,7 @@ import (
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 )
 
 type AwsClientFactory struct {
@@ -25,6 +29,10 @@ type AwsClientFactory struct {
 type AwsIamAPI interface {
 	CreateOpenIDConnectProvider(ctx context.Context, params *iam.CreateOpenIDConnectProviderInput, optFns ...func(*iam.Options)) (*iam.CreateOpenIDConnectProviderOutput, error)
 	DeleteOpenIDConnectProvider(ctx context.Context, params *iam.DeleteOpenIDConnectProviderInput, optFns ...func(*iam.Options)) (*iam.DeleteOpenIDConnectProviderOutput, error)
+	GetOpenIDConnectProvider(ctx context.Context, params *iam.GetOpenIDConnectProviderInput, optFns ...func(*iam.Options)) (*iam.GetOpenIDConnectProviderOutput, error)
+	AddClientIDToOpenIDConnectProvider(ctx context.Context, params *iam.AddClientIDToOpenIDConnectProviderInput, optFns ...func(*iam.Options)) (*iam.AddClientIDToOpenIDConnectProviderOutput, error)
+	RemoveClientIDFromOpenIDConnectProvider(ctx context.Context, params *iam.RemoveClientIDFromOpenIDConnectProviderInput, optFns ...func(*iam.Options)) (*iam.RemoveClientIDFromOpenIDConnectProviderOutput, error)
+	UpdateOpenIDConnectProviderThumbprint(ctx context.Context, params *iam.UpdateOpenIDConnectProviderThumbprintInput, optFns ...func(*iam.Options)) (*iam.UpdateOpenIDConnectProviderThumbprintOutput, error)
 	CreateRole(ctx context.Context, params *iam.CreateRoleInput, optFns ...func(*iam.Options)) (*iam.CreateRoleOutput, error)
 	UpdateAssumeRolePolicy(ctx context.Context, params *iam.UpdateAssumeRolePolicyInput, optFns ...func(*iam.Options)) (*iam.UpdateAssumeRolePolicyOutput, error)
 	ListAttachedRolePolicies(ctx context.Context, params *iam.ListAttachedRolePoliciesInput, optFns ...func(*iam.Options)) (*iam.ListAttachedRolePoliciesOutput, error)
@@ -42,9 +50,19 @@ type AwsS3API interface {
 	DeletePublicAccessBlock(ctx context.Context, params *s3.DeletePublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.DeletePublicAccessBlockOutput, error)
 	DeleteBucket(ctx context.Context, params *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error)
 	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
 	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	PutBucketOwnershipControls(ctx context.Context, params *s3.PutBucketOwnershipControlsInput, optFns ...func(*s3.Options)) (*s3.PutBucketOwnershipControlsOutput, error)
+	PutPublicAccessBlock(ctx context.Context, params *s3.PutPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error)
+	PutBucketPolicy(ctx context.Context, params *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error)
+	GetBucketPolicy(ctx context.Context, params *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error)
+	DeleteBucketPolicy(ctx context.Context, params *s3.DeleteBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketPolicyOutput, error)
+	PutBucketEncryption(ctx context.Context, params *s3.PutBucketEncryptionInput, optFns ...func(*s3.Options)) (*s3.PutBucketEncryptionOutput, error)
+	PutBucketVersioning(ctx context.Context, params *s3.PutBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error)
+	PutBucketTagging(ctx context.Context, params *s3.PutBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error)
 }
 
 // CheckObjectExists checks if a specific object exists in the given bucket.
@@ -63,6 +81,73 @@ func (a *AwsS3Client) CheckObjectExists(ctx context.Context, key string) (bool,
 	return true, nil
 }
 
+// GetObject returns the content of a specific object in the given bucket.
+// It returns nil without an error if the object does not exist.
+func (a *AwsS3Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	body, _, err := a.GetObjectWithETag(ctx, key)
+	return body, err
+}
+
+// GetObjectWithETag returns the object and its ETag, which can be passed to IfMatch to update it only if it has not changed since.
+// The body is nil and the ETag is empty if the object does not exist.
+func (a *AwsS3Client) GetObjectWithETag(ctx context.Context, key string) ([]byte, string, error) {
+	output, err := a.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(a.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nsk *s3types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	defer output.Body.Close()
+	body, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, aws.ToString(output.ETag), nil
+}
+
+// ListObjectKeys returns the keys of all objects whose key starts with the prefix.
+func (a *AwsS3Client) ListObjectKeys(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	paginator := s3.NewListObjectsV2Paginator(a.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(a.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
+
+// IfMatch makes a PutObject request succeed only if the object still has the given ETag,
+// or only if the object does not exist yet when etag is empty.
+// Otherwise, the request fails with an error for which IsPreconditionFailed returns true.
+func IfMatch(etag string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		if etag == "" {
+			o.APIOptions = append(o.APIOptions, smithyhttp.AddHeaderValue("If-None-Match", "*"))
+			return
+		}
+		o.APIOptions = append(o.APIOptions, smithyhttp.AddHeaderValue("If-Match", etag))
+	}
+}
+
+// IsPreconditionFailed reports whether a conditional request failed because the object has been changed concurrently.
+func IsPreconditionFailed(err error) bool {
+	var ae smithy.APIError
+	return errors.As(err, &ae) && slices.Contains([]string{"PreconditionFailed", "ConditionalRequestConflict"}, ae.ErrorCode())
+}
+
 type ObjectInput struct {
 	Key  string
 	Body []byte
@@ -80,6 +165,20 @@ func (a *AwsS3Client) CreateObjectsPublic(ctx context.Context, inputs []ObjectIn
 // CreateObjectPublic creates a file to an S3 bucket and sets its access level to public read.
 // This means the file can be read by anyone on the internet.
 func (a *AwsS3Client) CreateObjectPublic(ctx context.Context, input ObjectInput) error {
+	return a.createObject(ctx, input, a.PutObjectPublic)
+}
+
+// CreateObjects creates files to an S3 bucket without ACLs.
+func (a *AwsS3Client) CreateObjects(ctx context.Context, inputs []ObjectInput) error {
+	for _, input := range inputs {
+		if err := a.createObject(ctx, input, a.PutObject); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *AwsS3Client) createObject(ctx context.Context, input ObjectInput, put func(context.Context, ObjectInput, ...func(*s3.Options)) error) error {
 	exists, err := a.CheckObjectExists(ctx, input.Key)
 	if err != nil {
 		return err
@@ -87,7 +186,7 @@ func (a *AwsS3Client) CreateObjectPublic(ctx context.Context, input ObjectInput)
 	if exists {
 		log.Printf("skipped to create bucket object %s \n", input.Key)
 	} else {
-		err := a.PutObjectPublic(ctx, input)
+		err := put(ctx, input)
 		if err != nil {
 			return err
 		}
@@ -106,20 +205,225 @@ func (a *AwsS3Client) PutObjectsPublic(ctx context.Context, inputs []ObjectInput
 
 // PutObjectPublic uploads a file to an S3 bucket and sets its access level to public read.
 // This means the file can be read by anyone on the internet.
-func (a *AwsS3Client) PutObjectPublic(ctx context.Context, input ObjectInput) error {
+func (a *AwsS3Client) PutObjectPublic(ctx context.Context, input ObjectInput, optFns ...func(*s3.Options)) error {
 	_, err := a.Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(a.bucketName),
 		Key:         aws.String(input.Key),
 		ACL:         s3types.ObjectCannedACLPublicRead,
 		Body:        bytes.NewReader(input.Body),
 		ContentType: aws.String("application/json"),
-	})
+	}, optFns...)
+	return err
+}
+
+func (a *AwsS3Client) PutObjects(ctx context.Context, inputs []ObjectInput) error {
+	for _, input := range inputs {
+		if err := a.PutObject(ctx, input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutObject uploads a file to an S3 bucket without an ACL.
+// Its access level is determined by the bucket policy.
+func (a *AwsS3Client) PutObject(ctx context.Context, input ObjectInput, optFns ...func(*s3.Options)) error {
+	_, err := a.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(a.bucketName),
+		Key:         aws.String(input.Key),
+		Body:        bytes.NewReader(input.Body),
+		ContentType: aws.String("application/json"),
+	}, optFns...)
 	return err
 }
 
 // CreateBucketPublic creates a new S3 bucket with public access settings in the specified region.
 // The function configures the bucket to have its ownership controlled by the bucket creator.
-func (a *AwsS3Client) CreateBucketPublic(ctx context.Context) error {
+func (a *AwsS3Client) CreateBucketPublic(ctx context.Context, tags map[string]string) error {
+	if err := a.createBucket(ctx); err != nil {
+		return err
+	}
+	bucket := aws.String(a.bucketName)
+	_, err := a.Client.DeletePublicAccessBlock(ctx, &s3.DeletePublicAccessBlockInput{Bucket: bucket})
+	if err := skipNotImplemented(err); err != nil {
+		return err
+	}
+	_, err = a.Client.PutBucketOwnershipControls(ctx, &s3.PutBucketOwnershipControlsInput{
+		Bucket: bucket,
+		OwnershipControls: &s3types.OwnershipControls{
+			Rules: []s3types.OwnershipControlsRule{
+				{
+					ObjectOwnership: s3types.ObjectOwnershipBucketOwnerPreferred,
+				},
+			},
+		},
+	})
+	if err := skipNotImplemented(err); err != nil {
+		return err
+	}
+	return a.putBucketTagging(ctx, tags)
+}
+
+// CreateBucketWithPolicy creates a new S3 bucket in the specified region whose ACLs are disabled.
+// Only the given objects are made publicly readable, by a bucket policy.
+// Default encryption and versioning are turned on.
+func (a *AwsS3Client) CreateBucketWithPolicy(ctx context.Context, publicObjectKeys []string, tags map[string]string) error {
+	policy, err := publicReadPolicy(a.bucketName, publicObjectKeys)
+	if err != nil {
+		return err
+	}
+	return a.createPrivateBucket(ctx, false, policy, tags)
+}
+
+// CreateBucketForCloudFront creates a new S3 bucket in the specified region that blocks all public access.
+// Only the given CloudFront distribution is allowed to read the given objects, by a bucket policy.
+// When shared is true, the statements of the other distributions in the existing bucket policy are kept,
+// e.g. the ones of the other clusters sharing the bucket under another prefix.
+// Default encryption and versioning are turned on.
+func (a *AwsS3Client) CreateBucketForCloudFront(ctx context.Context, objectKeys []string, distributionArn string, shared bool, tags map[string]string) error {
+	var others []map[string]interface{}
+	if shared {
+		statements, err := a.bucketPolicyStatements(ctx)
+		if err != nil {
+			return err
+		}
+		others = withoutStatement(statements, cloudFrontReadStatementID(distributionArn))
+	}
+	policy, err := cloudFrontReadPolicy(a.bucketName, objectKeys, distributionArn, others)
+	if err != nil {
+		return err
+	}
+	return a.createPrivateBucket(ctx, true, policy, tags)
+}
+
+// RemoveCloudFrontReadPolicy removes the statement of the given CloudFront distribution from the bucket policy,
+// and deletes the bucket policy when no statements are left in it.
+func (a *AwsS3Client) RemoveCloudFrontReadPolicy(ctx context.Context, distributionArn string) error {
+	statements, err := a.bucketPolicyStatements(ctx)
+	if err != nil {
+		return err
+	}
+	others := withoutStatement(statements, cloudFrontReadStatementID(distributionArn))
+	if len(others) == len(statements) {
+		return nil
+	}
+	if len(others) == 0 {
+		_, err := a.Client.DeleteBucketPolicy(ctx, &s3.DeleteBucketPolicyInput{Bucket: aws.String(a.bucketName)})
+		return err
+	}
+	policy, err := readPolicy(others...)
+	if err != nil {
+		return err
+	}
+	_, err = a.Client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
+		Bucket: aws.String(a.bucketName),
+		Policy: aws.String(policy),
+	})
+	return err
+}
+
+// bucketPolicyStatements returns the statements of the bucket policy, or nil if the bucket or its policy does not exist.
+func (a *AwsS3Client) bucketPolicyStatements(ctx context.Context) ([]map[string]interface{}, error) {
+	out, err := a.Client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(a.bucketName)})
+	if err != nil {
+		var ae smithy.APIError
+		if errors.As(err, &ae) && slices.Contains([]string{"NoSuchBucketPolicy", "NoSuchBucket"}, ae.ErrorCode()) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	policy := struct {
+		Statement []map[string]interface{}
+	}{}
+	if err := json.Unmarshal([]byte(aws.ToString(out.Policy)), &policy); err != nil {
+		return nil, fmt.Errorf("unable to parse the policy of bucket %s, %w", a.bucketName, err)
+	}
+	return policy.Statement, nil
+}
+
+func withoutStatement(statements []map[string]interface{}, sid string) []map[string]interface{} {
+	return slices.DeleteFunc(slices.Clone(statements), func(statement map[string]interface{}) bool {
+		return statement["Sid"] == sid
+	})
+}
+
+// createPrivateBucket creates a bucket whose ACLs are disabled and applies the given bucket policy.
+// Public bucket policies are blocked as well when blockPublicPolicy is true.
+func (a *AwsS3Client) createPrivateBucket(ctx context.Context, blockPublicPolicy bool, policy string, tags map[string]string) error {
+	if err := a.createBucket(ctx); err != nil {
+		return err
+	}
+	bucket := aws.String(a.bucketName)
+	_, err := a.Client.PutBucketOwnershipControls(ctx, &s3.PutBucketOwnershipControlsInput{
+		Bucket: bucket,
+		OwnershipControls: &s3types.OwnershipControls{
+			Rules: []s3types.OwnershipControlsRule{
+				{
+					ObjectOwnership: s3types.ObjectOwnershipBucketOwnerEnforced,
+				},
+			},
+		},
+	})
+	if err := skipNotImplemented(err); err != nil {
+		return err
+	}
+	_, err = a.Client.PutPublicAccessBlock(ctx, &s3.PutPublicAccessBlockInput{
+		Bucket: bucket,
+		PublicAccessBlockConfiguration: &s3types.PublicAccessBlockConfiguration{
+			BlockPublicAcls:       aws.Bool(true),
+			IgnorePublicAcls:      aws.Bool(true),
+			BlockPublicPolicy:     aws.Bool(blockPublicPolicy),
+			RestrictPublicBuckets: aws.Bool(blockPublicPolicy),
+		},
+	})
+	if err := skipNotImplemented(err); err != nil {
+		return err
+	}
+	_, err = a.Client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: bucket,
+		ServerSideEncryptionConfiguration: &s3types.ServerSideEncryptionConfiguration{
+			Rules: []s3types.ServerSideEncryptionRule{
+				{
+					ApplyServerSideEncryptionByDefault: &s3types.ServerSideEncryptionByDefault{
+						SSEAlgorithm: s3types.ServerSideEncryptionAes256,
+					},
+				},
+			},
+		},
+	})
+	if err := skipNotImplemented(err); err != nil {
+		return err
+	}
+	_, err = a.Client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: bucket,
+		VersioningConfiguration: &s3types.VersioningConfiguration{
+			Status: s3types.BucketVersioningStatusEnabled,
+		},
+	})
+	if err := skipNotImplemented(err); err != nil {
+		return err
+	}
+	if err := a.putBucketTagging(ctx, tags); err != nil {
+		return err
+	}
+	_, err = a.Client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
+		Bucket: bucket,
+		Policy: aws.String(policy),
+	})
+	return err
+}
+
+// skipNotImplemented ignores the error of a bucket setting that an S3-compatible storage does not implement.
+func skipNotImplemented(err error) error {
+	var ae smithy.APIError
+	if errors.As(err, &ae) && ae.ErrorCode() == "NotImplemented" {
+		log.Println("skipped error", err)
+		return nil
+	}
+	return err
+}
+
+func (a *AwsS3Client) createBucket(ctx context.Context) error {
 	log.Printf("creating S3 bucket... Name: %s, Region: %s \n", a.bucketName, a.Region())
 	bucket := aws.String(a.bucketName)
 	var input *s3.CreateBucketInput
@@ -144,36 +448,94 @@ func (a *AwsS3Client) CreateBucketPublic(ctx context.Context) error {
 			return err
 		}
 	}
-	_, err = a.Client.DeletePublicAccessBlock(ctx, &s3.DeletePublicAccessBlockInput{Bucket: bucket})
-	if err != nil {
-		return err
+	return nil
+}
+
+func (a *AwsS3Client) putBucketTagging(ctx context.Context, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
 	}
-	_, err = a.Client.PutBucketOwnershipControls(ctx, &s3.PutBucketOwnershipControlsInput{
-		Bucket: bucket,
-		OwnershipControls: &s3types.OwnershipControls{
-			Rules: []s3types.OwnershipControlsRule{
-				{
-					ObjectOwnership: s3types.ObjectOwnershipBucketOwnerPreferred,
-				},
+	tagSet := make([]s3types.Tag, 0, len(tags))
+	for key, value := range tags {
+		tagSet = append(tagSet, s3types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	slices.SortFunc(tagSet, func(a, b s3types.Tag) int {
+		return strings.Compare(*a.Key, *b.Key)
+	})
+	_, err := a.Client.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{
+		Bucket:  aws.String(a.bucketName),
+		Tagging: &s3types.Tagging{TagSet: tagSet},
+	})
+	return err
+}
+
+// publicReadPolicy returns a bucket policy that grants anyone read-only access to the given objects.
+func publicReadPolicy(bucketName string, objectKeys []string) (string, error) {
+	return readPolicy(map[string]interface{}{
+		"Sid":       "PublicReadOIDCDiscovery",
+		"Effect":    "Allow",
+		"Principal": "*",
+		"Action":    "s3:GetObject",
+		"Resource":  objectArns(bucketName, objectKeys),
+	})
+}
+
+// cloudFrontReadPolicy returns a bucket policy that grants only the given CloudFront distribution
+// read-only access to the given objects, through its Origin Access Control.
+// The other statements are kept in the policy as they are.
+func cloudFrontReadPolicy(bucketName string, objectKeys []string, distributionArn string, others []map[string]interface{}) (string, error) {
+	statement := map[string]interface{}{
+		"Sid":    cloudFrontReadStatementID(distributionArn),
+		"Effect": "Allow",
+		"Principal": map[string]string{
+			"Service": "cloudfront.amazonaws.com",
+		},
+		"Action":   "s3:GetObject",
+		"Resource": objectArns(bucketName, objectKeys),
+		"Condition": map[string]interface{}{
+			"StringEquals": map[string]string{
+				"AWS:SourceArn": distributionArn,
 			},
 		},
-	})
+	}
+	return readPolicy(append(others, statement)...)
+}
+
+// cloudFrontReadStatementID returns the ID of the bucket policy statement of a distribution,
+// which only consists of alphanumeric characters like the ID of the distribution.
+func cloudFrontReadStatementID(distributionArn string) string {
+	return "CloudFrontReadOIDCDiscovery" + path.Base(distributionArn)
+}
+
+func readPolicy(statements ...map[string]interface{}) (string, error) {
+	policy := map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": statements,
+	}
+	b, err := json.Marshal(policy)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	return string(b), nil
 }
 
-// DeleteBucket attempts to delete the specified bucket.
-// If the bucket contains any objects, the deletion will not be forced to prevent accidental data loss.
+func objectArns(bucketName string, objectKeys []string) []string {
+	arns := make([]string, 0, len(objectKeys))
+	for _, key := range objectKeys {
+		arns = append(arns, fmt.Sprintf("arn:aws:s3:::%s/%s", bucketName, key))
+	}
+	return arns
+}
+
+// DeleteBucket deletes the specified bucket.
+// It fails if any objects, including the noncurrent versions of a versioned bucket, are left in it.
 func (a *AwsS3Client) DeleteBucket(ctx context.Context) error {
 	_, err := a.Client.DeleteBucket(ctx, &s3.DeleteBucketInput{
 		Bucket: aws.String(a.bucketName),
 	})
 	if err != nil {
 		var ae smithy.APIError
-		errorCodes := []string{"BucketNotEmpty", "NoSuchBucket"}
-		if errors.As(err, &ae) && slices.Contains(errorCodes, ae.ErrorCode()) {
+		if errors.As(err, &ae) && ae.ErrorCode() == "NoSuchBucket" {
 			log.Println("Deletion skipped: ", err)
 			return nil
 		}
@@ -182,16 +544,53 @@ func (a *AwsS3Client) DeleteBucket(ctx context.Context) error {
 	return nil
 }
 
-// DeleteObjects removes a list of objects from a specified bucket.
-func (a *AwsS3Client) DeleteObjects(ctx context.Context, objectKeys []string) error {
-	objectIds := make([]s3types.ObjectIdentifier, len(objectKeys))
-	for i, key := range objectKeys {
-		objectIds[i] = s3types.ObjectIdentifier{Key: aws.String(key)}
+// DeleteBucketIfEmpty deletes the bucket only if no objects are left in it,
+// e.g. the discovery information of other clusters sharing the bucket.
+// The noncurrent versions and the delete markers of a versioned bucket count as objects as well.
+func (a *AwsS3Client) DeleteBucketIfEmpty(ctx context.Context) error {
+	empty, err := a.isEmpty(ctx)
+	if err != nil {
+		var ae smithy.APIError
+		if errors.As(err, &ae) && ae.ErrorCode() == "NoSuchBucket" {
+			log.Println("Deletion skipped: the bucket does not exist.", err)
+			return nil
+		}
+		return err
 	}
-	_, err := a.Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
-		Bucket: aws.String(a.bucketName),
-		Delete: &s3types.Delete{Objects: objectIds},
+	if !empty {
+		log.Printf("Deletion skipped: the bucket %s is not empty.\n", a.bucketName)
+		return nil
+	}
+	return a.DeleteBucket(ctx)
+}
+
+// isEmpty reports whether neither objects nor object versions are left in the bucket.
+func (a *AwsS3Client) isEmpty(ctx context.Context) (bool, error) {
+	versions, err := a.Client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{
+		Bucket:  aws.String(a.bucketName),
+		MaxKeys: aws.Int32(1),
+	})
+	if err := skipNotImplemented(err); err != nil {
+		return false, err
+	}
+	if versions != nil {
+		return len(versions.Versions) == 0 && len(versions.DeleteMarkers) == 0, nil
+	}
+	// an S3-compatible storage without versioning
+	out, err := a.Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(a.bucketName),
+		MaxKeys: aws.Int32(1),
 	})
+	if err != nil {
+		return false, err
+	}
+	return len(out.Contents) == 0, nil
+}
+
+// DeleteObjects removes a list of objects from a specified bucket.
+// Every version and delete marker of the objects is removed as well, so that nothing is left in a versioned bucket.
+func (a *AwsS3Client) DeleteObjects(ctx context.Context, objectKeys []string) error {
+	objectIds, err := a.objectVersions(ctx, objectKeys)
 	if err != nil {
 		var ae smithy.APIError
 		if errors.As(err, &ae) && ae.ErrorCode() == "NoSuchBucket" {
@@ -200,7 +599,66 @@ func (a *AwsS3Client) DeleteObjects(ctx context.Context, objectKeys []string) er
 		}
 		return err
 	}
-	return err
+	// a DeleteObjects request removes up to 1000 objects
+	for len(objectIds) > 0 {
+		n := min(len(objectIds), 1000)
+		out, err := a.Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(a.bucketName),
+			Delete: &s3types.Delete{Objects: objectIds[:n]},
+		})
+		if err != nil {
+			return err
+		}
+		if out != nil && len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return fmt.Errorf("unable to delete object %s, %s: %s", aws.ToString(e.Key), aws.ToString(e.Code), aws.ToString(e.Message))
+		}
+		objectIds = objectIds[n:]
+	}
+	return nil
+}
+
+// objectVersions returns the identifiers of every version and delete marker of the given objects.
+// If the storage does not implement versioning, the objects are identified by their keys only.
+func (a *AwsS3Client) objectVersions(ctx context.Context, objectKeys []string) ([]s3types.ObjectIdentifier, error) {
+	objectIds := []s3types.ObjectIdentifier{}
+	for _, key := range objectKeys {
+		paginator := s3.NewListObjectVersionsPaginator(a.Client, &s3.ListObjectVersionsInput{
+			Bucket: aws.String(a.bucketName),
+			Prefix: aws.String(key),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				var ae smithy.APIError
+				if errors.As(err, &ae) && ae.ErrorCode() == "NotImplemented" {
+					log.Println("skipped error", err)
+					return keyIdentifiers(objectKeys), nil
+				}
+				return nil, err
+			}
+			// the prefix also matches the keys that merely start with the key
+			for _, v := range page.Versions {
+				if aws.ToString(v.Key) == key {
+					objectIds = append(objectIds, s3types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+				}
+			}
+			for _, m := range page.DeleteMarkers {
+				if aws.ToString(m.Key) == key {
+					objectIds = append(objectIds, s3types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+				}
+			}
+		}
+	}
+	return objectIds, nil
+}
+
+func keyIdentifiers(objectKeys []string) []s3types.ObjectIdentifier {
+	objectIds := make([]s3types.ObjectIdentifier, len(objectKeys))
+	for i, key := range objectKeys {
+		objectIds[i] = s3types.ObjectIdentifier{Key: aws.String(key)}
+	}
+	return objectIds
 }
 
 func (a *AwsS3Client) BucketName() string {
@@ -211,14 +669,21 @@ func (a *AwsS3Client) Region() string {
 	return a.region
 }
 
-// CreateOIDCProvider creates an OpenID Connect (OIDC) provider in AWS IAM.
-func (a *AwsIamClient) CreateOIDCProvider(ctx context.Context, providerUrl string) error {
-	_, err := a.Client.CreateOpenIDConnectProvider(ctx, &iam.CreateOpenIDConnectProviderInput{
-		Url:          &providerUrl,
-		ClientIDList: []string{"sts.amazonaws.com"},
-		ThumbprintList: []string{
+// STSAudience is the client ID of the OIDC provider used by AWS STS.
+const STSAudience = "sts.amazonaws.com"
+
+// CreateOIDCProvider creates an OpenID Connect (OIDC) provider in AWS IAM with the given client IDs and thumbprints.
+// Without thumbprints, a placeholder is used, which is fine for an issuer whose certificate is trusted by AWS IAM.
+func (a *AwsIamClient) CreateOIDCProvider(ctx context.Context, providerUrl string, clientIDs, thumbprints []string) error {
+	if len(thumbprints) == 0 {
+		thumbprints = []string{
 			strings.Repeat("x", 40), // Thumbprint is required, but IAM will retrieve and use the top intermediate CA thumbprint of the OpenID Connect identity provider server certificate.
-		},
+		}
+	}
+	_, err := a.Client.CreateOpenIDConnectProvider(ctx, &iam.CreateOpenIDConnectProviderInput{
+		Url:            &providerUrl,
+		ClientIDList:   clientIDs,
+		ThumbprintList: thumbprints,
 	})
 	if err != nil {
 		var entityAlreadyExists *iamtypes.EntityAlreadyExistsException
@@ -234,7 +699,7 @@ func (a *AwsIamClient) CreateOIDCProvider(ctx context.Context, providerUrl strin
 // DeleteOIDCProvider deletes an OpenID Connect (OIDC) provider in AWS IAM.
 func (a *AwsIamClient) DeleteOIDCProvider(ctx context.Context, accountId, issuerHostPath string) error {
 	_, err := a.Client.DeleteOpenIDConnectProvider(ctx, &iam.DeleteOpenIDConnectProviderInput{
-		OpenIDConnectProviderArn: aws.String(fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", accountId, issuerHostPath)),
+		OpenIDConnectProviderArn: aws.String(oidcProviderArn(accountId, issuerHostPath)),
 	})
 	if err != nil {
 		var ae smithy.APIError
@@ -247,6 +712,69 @@ func (a *AwsIamClient) DeleteOIDCProvider(ctx context.Context, accountId, issuer
 	return nil
 }
 
+// GetOIDCProvider returns an OpenID Connect (OIDC) provider in AWS IAM.
+// It returns nil without an error if the provider does not exist.
+func (a *AwsIamClient) GetOIDCProvider(ctx context.Context, accountId, issuerHostPath string) (*iam.GetOpenIDConnectProviderOutput, error) {
+	output, err := a.Client.GetOpenIDConnectProvider(ctx, &iam.GetOpenIDConnectProviderInput{
+		OpenIDConnectProviderArn: aws.String(oidcProviderArn(accountId, issuerHostPath)),
+	})
+	if err != nil {
+		var noSuchEntity *iamtypes.NoSuchEntityException
+		if errors.As(err, &noSuchEntity) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
+// AddOIDCProviderClientID adds a client ID (audience) to an OpenID Connect (OIDC) provider in AWS IAM.
+func (a *AwsIamClient) AddOIDCProviderClientID(ctx context.Context, accountId, issuerHostPath, clientID string) error {
+	_, err := a.Client.AddClientIDToOpenIDConnectProvider(ctx, &iam.AddClientIDToOpenIDConnectProviderInput{
+		OpenIDConnectProviderArn: aws.String(oidcProviderArn(accountId, issuerHostPath)),
+		ClientID:                 aws.String(clientID),
+	})
+	return err
+}
+
+// RemoveOIDCProviderClientID removes a client ID (audience) from an OpenID Connect (OIDC) provider in AWS IAM.
+func (a *AwsIamClient) RemoveOIDCProviderClientID(ctx context.Context, accountId, issuerHostPath, clientID string) error {
+	_, err := a.Client.RemoveClientIDFromOpenIDConnectProvider(ctx, &iam.RemoveClientIDFromOpenIDConnectProviderInput{
+		OpenIDConnectProviderArn: aws.String(oidcProviderArn(accountId, issuerHostPath)),
+		ClientID:                 aws.String(clientID),
+	})
+	return err
+}
+
+// EnsureOIDCProviderClientID adds a client ID (audience) to an OpenID Connect (OIDC) provider in AWS IAM unless it already has it.
+// It returns an error if the provider does not exist.
+func (a *AwsIamClient) EnsureOIDCProviderClientID(ctx context.Context, accountId, issuerHostPath, clientID string) error {
+	provider, err := a.GetOIDCProvider(ctx, accountId, issuerHostPath)
+	if err != nil {
+		return err
+	}
+	if provider == nil {
+		return fmt.Errorf("the IAM OIDC provider %s does not exist", oidcProviderArn(accountId, issuerHostPath))
+	}
+	if slices.Contains(provider.ClientIDList, clientID) {
+		return nil
+	}
+	return a.AddOIDCProviderClientID(ctx, accountId, issuerHostPath, clientID)
+}
+
+// UpdateOIDCProviderThumbprints replaces the thumbprints of an OpenID Connect (OIDC) provider in AWS IAM.
+func (a *AwsIamClient) UpdateOIDCProviderThumbprints(ctx context.Context, accountId, issuerHostPath string, thumbprints []string) error {
+	_, err := a.Client.UpdateOpenIDConnectProviderThumbprint(ctx, &iam.UpdateOpenIDConnectProviderThumbprintInput{
+		OpenIDConnectProviderArn: aws.String(oidcProviderArn(accountId, issuerHostPath)),
+		ThumbprintList:           thumbprints,
+	})
+	return err
+}
+
+func oidcProviderArn(accountId, issuerHostPath string) string {
+	return fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", accountId, issuerHostPath)
+}
+
 func (a *AwsStsClient) GetAccountId() (string, error) {
 	req, err := a.Client.GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
 	if err != nil {
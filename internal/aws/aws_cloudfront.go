@@ -0,0 +1,237 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+)
+
+// cachingDisabledPolicyID is the ID of the AWS managed cache policy "Managed-CachingDisabled".
+// The discovery documents are not cached, so that a rotated JWKS is served immediately.
+const cachingDisabledPolicyID = "4135ea2d-6df8-44a3-9df3-4b5a84be39ad"
+
+// ErrDistributionInProgress is returned while a distribution is being disabled before it can be deleted.
+var ErrDistributionInProgress = errors.New("the CloudFront distribution is being disabled")
+
+type AwsCloudFrontAPI interface {
+	CreateDistribution(ctx context.Context, params *cloudfront.CreateDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateDistributionOutput, error)
+	GetDistribution(ctx context.Context, params *cloudfront.GetDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetDistributionOutput, error)
+	ListDistributions(ctx context.Context, params *cloudfront.ListDistributionsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListDistributionsOutput, error)
+	UpdateDistribution(ctx context.Context, params *cloudfront.UpdateDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.UpdateDistributionOutput, error)
+	DeleteDistribution(ctx context.Context, params *cloudfront.DeleteDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.DeleteDistributionOutput, error)
+	CreateOriginAccessControl(ctx context.Context, params *cloudfront.CreateOriginAccessControlInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateOriginAccessControlOutput, error)
+	GetOriginAccessControl(ctx context.Context, params *cloudfront.GetOriginAccessControlInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetOriginAccessControlOutput, error)
+	ListOriginAccessControls(ctx context.Context, params *cloudfront.ListOriginAccessControlsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListOriginAccessControlsOutput, error)
+	DeleteOriginAccessControl(ctx context.Context, params *cloudfront.DeleteOriginAccessControlInput, optFns ...func(*cloudfront.Options)) (*cloudfront.DeleteOriginAccessControlOutput, error)
+}
+
+type AwsCloudFrontClient struct {
+	Client AwsCloudFrontAPI
+}
+
+// Distribution holds the attributes of a CloudFront distribution.
+type Distribution struct {
+	ID         string
+	ARN        string
+	DomainName string
+}
+
+// DistributionInput holds the settings of a distribution in front of an S3 bucket.
+type DistributionInput struct {
+	// Comment identifies the distribution, so that it is found again instead of being created twice.
+	Comment               string
+	OriginDomainName      string
+	OriginAccessControlID string
+	Alias                 string
+	CertificateArn        string
+}
+
+// GetDistribution returns the distribution with the given ID.
+func (a *AwsCloudFrontClient) GetDistribution(ctx context.Context, id string) (*Distribution, error) {
+	out, err := a.Client.GetDistribution(ctx, &cloudfront.GetDistributionInput{Id: aws.String(id)})
+	if err != nil {
+		return nil, err
+	}
+	return newDistribution(out.Distribution.Id, out.Distribution.ARN, out.Distribution.DomainName), nil
+}
+
+// IsNoSuchDistribution reports whether a request failed because the distribution does not exist.
+func IsNoSuchDistribution(err error) bool {
+	var noSuchDistribution *cftypes.NoSuchDistribution
+	return errors.As(err, &noSuchDistribution)
+}
+
+// FindDistribution returns the distribution with the given comment, or nil if it does not exist.
+func (a *AwsCloudFrontClient) FindDistribution(ctx context.Context, comment string) (*Distribution, error) {
+	var marker *string
+	for {
+		out, err := a.Client.ListDistributions(ctx, &cloudfront.ListDistributionsInput{Marker: marker})
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range out.DistributionList.Items {
+			if aws.ToString(d.Comment) == comment {
+				return newDistribution(d.Id, d.ARN, d.DomainName), nil
+			}
+		}
+		if !aws.ToBool(out.DistributionList.IsTruncated) {
+			return nil, nil
+		}
+		marker = out.DistributionList.NextMarker
+	}
+}
+
+// CreateDistribution creates a distribution serving an S3 bucket through the given Origin Access Control.
+// Only HTTPS is allowed, and the objects are not cached.
+func (a *AwsCloudFrontClient) CreateDistribution(ctx context.Context, input DistributionInput) (*Distribution, error) {
+	log.Printf("creating CloudFront distribution... Origin: %s \n", input.OriginDomainName)
+	originID := "s3"
+	config := &cftypes.DistributionConfig{
+		CallerReference: aws.String(fmt.Sprintf("%s-%d", input.Comment, time.Now().UnixNano())),
+		Comment:         aws.String(input.Comment),
+		Enabled:         aws.Bool(true),
+		Origins: &cftypes.Origins{
+			Quantity: aws.Int32(1),
+			Items: []cftypes.Origin{
+				{
+					Id:                    aws.String(originID),
+					DomainName:            aws.String(input.OriginDomainName),
+					OriginAccessControlId: aws.String(input.OriginAccessControlID),
+					S3OriginConfig:        &cftypes.S3OriginConfig{OriginAccessIdentity: aws.String("")},
+				},
+			},
+		},
+		DefaultCacheBehavior: &cftypes.DefaultCacheBehavior{
+			TargetOriginId:       aws.String(originID),
+			ViewerProtocolPolicy: cftypes.ViewerProtocolPolicyHttpsOnly,
+			CachePolicyId:        aws.String(cachingDisabledPolicyID),
+		},
+		ViewerCertificate: &cftypes.ViewerCertificate{CloudFrontDefaultCertificate: aws.Bool(true)},
+	}
+	if input.Alias != "" {
+		config.Aliases = &cftypes.Aliases{Quantity: aws.Int32(1), Items: []string{input.Alias}}
+		config.ViewerCertificate = &cftypes.ViewerCertificate{
+			ACMCertificateArn:      aws.String(input.CertificateArn),
+			SSLSupportMethod:       cftypes.SSLSupportMethodSniOnly,
+			MinimumProtocolVersion: cftypes.MinimumProtocolVersionTLSv122021,
+		}
+	}
+	out, err := a.Client.CreateDistribution(ctx, &cloudfront.CreateDistributionInput{DistributionConfig: config})
+	if err != nil {
+		return nil, err
+	}
+	return newDistribution(out.Distribution.Id, out.Distribution.ARN, out.Distribution.DomainName), nil
+}
+
+// DeleteDistribution deletes the distribution with the given ID.
+// An enabled distribution has to be disabled and deployed before it can be deleted,
+// so ErrDistributionInProgress is returned until then.
+func (a *AwsCloudFrontClient) DeleteDistribution(ctx context.Context, id string) error {
+	out, err := a.Client.GetDistribution(ctx, &cloudfront.GetDistributionInput{Id: aws.String(id)})
+	if err != nil {
+		if IsNoSuchDistribution(err) {
+			log.Println("skipped error", err)
+			return nil
+		}
+		return err
+	}
+	config := out.Distribution.DistributionConfig
+	if aws.ToBool(config.Enabled) {
+		log.Printf("disabling CloudFront distribution... ID: %s \n", id)
+		config.Enabled = aws.Bool(false)
+		_, err := a.Client.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
+			Id:                 aws.String(id),
+			IfMatch:            out.ETag,
+			DistributionConfig: config,
+		})
+		if err != nil {
+			return err
+		}
+		return ErrDistributionInProgress
+	}
+	if aws.ToString(out.Distribution.Status) != "Deployed" {
+		return ErrDistributionInProgress
+	}
+	_, err = a.Client.DeleteDistribution(ctx, &cloudfront.DeleteDistributionInput{
+		Id:      aws.String(id),
+		IfMatch: out.ETag,
+	})
+	return err
+}
+
+// CreateOriginAccessControl creates an Origin Access Control for S3 with the given name
+// and returns its ID. If it already exists, the ID of the existing one is returned.
+func (a *AwsCloudFrontClient) CreateOriginAccessControl(ctx context.Context, name string) (string, error) {
+	var marker *string
+	for {
+		out, err := a.Client.ListOriginAccessControls(ctx, &cloudfront.ListOriginAccessControlsInput{Marker: marker})
+		if err != nil {
+			return "", err
+		}
+		for _, oac := range out.OriginAccessControlList.Items {
+			if aws.ToString(oac.Name) == name {
+				return aws.ToString(oac.Id), nil
+			}
+		}
+		if !aws.ToBool(out.OriginAccessControlList.IsTruncated) {
+			break
+		}
+		marker = out.OriginAccessControlList.NextMarker
+	}
+	out, err := a.Client.CreateOriginAccessControl(ctx, &cloudfront.CreateOriginAccessControlInput{
+		OriginAccessControlConfig: &cftypes.OriginAccessControlConfig{
+			Name:                          aws.String(name),
+			Description:                   aws.String("created by irsa-manager"),
+			OriginAccessControlOriginType: cftypes.OriginAccessControlOriginTypesS3,
+			SigningBehavior:               cftypes.OriginAccessControlSigningBehaviorsAlways,
+			SigningProtocol:               cftypes.OriginAccessControlSigningProtocolsSigv4,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.OriginAccessControl.Id), nil
+}
+
+// DeleteOriginAccessControl deletes the Origin Access Control with the given ID.
+// It is left as is while another distribution still uses it.
+func (a *AwsCloudFrontClient) DeleteOriginAccessControl(ctx context.Context, id string) error {
+	out, err := a.Client.GetOriginAccessControl(ctx, &cloudfront.GetOriginAccessControlInput{Id: aws.String(id)})
+	if err != nil {
+		var noSuchOriginAccessControl *cftypes.NoSuchOriginAccessControl
+		if errors.As(err, &noSuchOriginAccessControl) {
+			log.Println("skipped error", err)
+			return nil
+		}
+		return err
+	}
+	_, err = a.Client.DeleteOriginAccessControl(ctx, &cloudfront.DeleteOriginAccessControlInput{
+		Id:      aws.String(id),
+		IfMatch: out.ETag,
+	})
+	var inUse *cftypes.OriginAccessControlInUse
+	if errors.As(err, &inUse) {
+		log.Println("Deletion skipped: ", err)
+		return nil
+	}
+	return err
+}
+
+// S3OriginDomainName returns the regional domain name of an S3 bucket used as a CloudFront origin.
+func S3OriginDomainName(region, bucketName string) string {
+	return fmt.Sprintf("%s.s3.%s.amazonaws.com", bucketName, region)
+}
+
+func newDistribution(id, arn, domainName *string) *Distribution {
+	return &Distribution{
+		ID:         aws.ToString(id),
+		ARN:        aws.ToString(arn),
+		DomainName: aws.ToString(domainName),
+	}
+}
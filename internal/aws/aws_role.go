@@ -25,6 +25,8 @@ type RoleManager struct {
 	ServiceAccount irsav1alpha1.IRSAServiceAccount
 	// Policies represents the list of policies to be attached to the role
 	Policies []string
+	// Audience represents the audience of the service account tokens, which is required by the trust policy if it is set
+	Audience string
 
 	// AccountId represents the AWS Account Id
 	AccountId string
@@ -114,11 +116,18 @@ func (a *AwsIamClient) AttachRolePolicy(ctx context.Context, roleName, policyArn
 	return err
 }
 
-// UpdateIRSARole creates an IAM role with the specified trust policy and attaches specified policies to it
-func (a *AwsIamClient) UpdateIRSARole(ctx context.Context, issuerMeta issuer.OIDCIssuerMeta, r RoleManager) error {
+// TrustPolicy returns the trust policy of the role, which allows the service accounts to assume it
+// with tokens of the issuer that have the audience of the role.
+func (r *RoleManager) TrustPolicy(issuerMeta issuer.OIDCIssuerMeta) ([]byte, error) {
 	providerArn := fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", r.AccountId, issuerMeta.IssuerHostPath())
 	statement := make([]map[string]interface{}, len(r.ServiceAccount.Namespaces))
 	for i, ns := range r.ServiceAccount.Namespaces {
+		conditions := map[string]interface{}{
+			fmt.Sprintf("%s:sub", issuerMeta.IssuerHostPath()): fmt.Sprintf("system:serviceaccount:%s:%s", ns, r.ServiceAccount.Name),
+		}
+		if r.Audience != "" {
+			conditions[fmt.Sprintf("%s:aud", issuerMeta.IssuerHostPath())] = r.Audience
+		}
 		statement[i] = map[string]interface{}{
 			"Effect": "Allow",
 			"Principal": map[string]interface{}{
@@ -126,9 +135,7 @@ func (a *AwsIamClient) UpdateIRSARole(ctx context.Context, issuerMeta issuer.OID
 			},
 			"Action": "sts:AssumeRoleWithWebIdentity",
 			"Condition": map[string]interface{}{
-				"StringEquals": map[string]interface{}{
-					fmt.Sprintf("%s:sub", issuerMeta.IssuerHostPath()): fmt.Sprintf("system:serviceaccount:%s:%s", ns, r.ServiceAccount.Name),
-				},
+				"StringEquals": conditions,
 			},
 		}
 	}
@@ -138,7 +145,16 @@ func (a *AwsIamClient) UpdateIRSARole(ctx context.Context, issuerMeta issuer.OID
 	}
 	trustPolicyJSON, err := json.Marshal(trustPolicy)
 	if err != nil {
-		return fmt.Errorf("failed to marshal trust policy: %w", err)
+		return nil, fmt.Errorf("failed to marshal trust policy: %w", err)
+	}
+	return trustPolicyJSON, nil
+}
+
+// UpdateIRSARole creates an IAM role with the specified trust policy and attaches specified policies to it
+func (a *AwsIamClient) UpdateIRSARole(ctx context.Context, issuerMeta issuer.OIDCIssuerMeta, r RoleManager) error {
+	trustPolicyJSON, err := r.TrustPolicy(issuerMeta)
+	if err != nil {
+		return err
 	}
 	createRoleInput := &iam.CreateRoleInput{
 		RoleName:                 aws.String(r.RoleName),
@@ -7,6 +7,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/iam/types"
 	"github.com/stretchr/testify/assert"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/kkb0318/irsa-manager/internal/issuer"
 )
 
 func TestExtractNewPolicies(t *testing.T) {
@@ -107,3 +110,50 @@ func TestExtractStalePolicies(t *testing.T) {
 		})
 	}
 }
+
+func TestTrustPolicy(t *testing.T) {
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(&irsav1alpha1.IRSASetup{
+		Spec: irsav1alpha1.IRSASetupSpec{
+			Discovery: irsav1alpha1.Discovery{
+				S3: irsav1alpha1.S3Discovery{Region: "ap-northeast-1", BucketName: "irsa-manager"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	tests := []struct {
+		name     string
+		audience string
+		expected string
+	}{
+		{
+			"WithAudience",
+			"sts.amazonaws.com",
+			`{"Statement":[{"Action":"sts:AssumeRoleWithWebIdentity",` +
+				`"Condition":{"StringEquals":{"s3-ap-northeast-1.amazonaws.com/irsa-manager:aud":"sts.amazonaws.com",` +
+				`"s3-ap-northeast-1.amazonaws.com/irsa-manager:sub":"system:serviceaccount:default:sa"}},"Effect":"Allow",` +
+				`"Principal":{"Federated":"arn:aws:iam::123456789012:oidc-provider/s3-ap-northeast-1.amazonaws.com/irsa-manager"}}],` +
+				`"Version":"2012-10-17"}`,
+		},
+		{
+			"WithoutAudience",
+			"",
+			`{"Statement":[{"Action":"sts:AssumeRoleWithWebIdentity",` +
+				`"Condition":{"StringEquals":{"s3-ap-northeast-1.amazonaws.com/irsa-manager:sub":"system:serviceaccount:default:sa"}},"Effect":"Allow",` +
+				`"Principal":{"Federated":"arn:aws:iam::123456789012:oidc-provider/s3-ap-northeast-1.amazonaws.com/irsa-manager"}}],` +
+				`"Version":"2012-10-17"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &RoleManager{
+				ServiceAccount: irsav1alpha1.IRSAServiceAccount{Name: "sa", Namespaces: []string{"default"}},
+				Audience:       tt.audience,
+				AccountId:      "123456789012",
+			}
+			result, err := r.TrustPolicy(issuerMeta)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(result))
+		})
+	}
+}
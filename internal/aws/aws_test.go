@@ -0,0 +1,121 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicReadPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		bucketName string
+		objectKeys []string
+		expected   string
+	}{
+		{
+			"DiscoveryDocuments",
+			"irsa-manager",
+			[]string{".well-known/openid-configuration", "keys.json"},
+			`{"Statement":[{"Action":"s3:GetObject","Effect":"Allow","Principal":"*",` +
+				`"Resource":["arn:aws:s3:::irsa-manager/.well-known/openid-configuration","arn:aws:s3:::irsa-manager/keys.json"],` +
+				`"Sid":"PublicReadOIDCDiscovery"}],"Version":"2012-10-17"}`,
+		},
+		{
+			"DiscoveryDocumentsUnderAnyPrefix",
+			"irsa-manager",
+			[]string{"*/.well-known/openid-configuration", "*/keys.json"},
+			`{"Statement":[{"Action":"s3:GetObject","Effect":"Allow","Principal":"*",` +
+				`"Resource":["arn:aws:s3:::irsa-manager/*/.well-known/openid-configuration","arn:aws:s3:::irsa-manager/*/keys.json"],` +
+				`"Sid":"PublicReadOIDCDiscovery"}],"Version":"2012-10-17"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := publicReadPolicy(tt.bucketName, tt.objectKeys)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, result)
+		})
+	}
+}
+
+func TestCloudFrontReadPolicy(t *testing.T) {
+	tests := []struct {
+		name            string
+		bucketName      string
+		objectKeys      []string
+		distributionArn string
+		others          []map[string]interface{}
+		expected        string
+	}{
+		{
+			"DiscoveryDocuments",
+			"irsa-manager",
+			[]string{".well-known/openid-configuration", "keys.json"},
+			"arn:aws:cloudfront::123456789012:distribution/EDFDVBD6EXAMPLE",
+			nil,
+			`{"Statement":[{"Action":"s3:GetObject","Effect":"Allow","Principal":{"Service":"cloudfront.amazonaws.com"},` +
+				`"Resource":["arn:aws:s3:::irsa-manager/.well-known/openid-configuration","arn:aws:s3:::irsa-manager/keys.json"],` +
+				`"Condition":{"StringEquals":{"AWS:SourceArn":"arn:aws:cloudfront::123456789012:distribution/EDFDVBD6EXAMPLE"}},` +
+				`"Sid":"CloudFrontReadOIDCDiscoveryEDFDVBD6EXAMPLE"}],"Version":"2012-10-17"}`,
+		},
+		{
+			"KeepingOtherDistributions",
+			"irsa-manager",
+			[]string{"cluster-a/.well-known/openid-configuration", "cluster-a/keys.json"},
+			"arn:aws:cloudfront::123456789012:distribution/EDFDVBD6EXAMPLE",
+			[]map[string]interface{}{{"Sid": "CloudFrontReadOIDCDiscoveryEOTHER", "Effect": "Allow"}},
+			`{"Statement":[{"Effect":"Allow","Sid":"CloudFrontReadOIDCDiscoveryEOTHER"},` +
+				`{"Action":"s3:GetObject","Effect":"Allow","Principal":{"Service":"cloudfront.amazonaws.com"},` +
+				`"Resource":["arn:aws:s3:::irsa-manager/cluster-a/.well-known/openid-configuration","arn:aws:s3:::irsa-manager/cluster-a/keys.json"],` +
+				`"Condition":{"StringEquals":{"AWS:SourceArn":"arn:aws:cloudfront::123456789012:distribution/EDFDVBD6EXAMPLE"}},` +
+				`"Sid":"CloudFrontReadOIDCDiscoveryEDFDVBD6EXAMPLE"}],"Version":"2012-10-17"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := cloudFrontReadPolicy(tt.bucketName, tt.objectKeys, tt.distributionArn, tt.others)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, result)
+		})
+	}
+}
+
+func TestS3CompatibleOptions(t *testing.T) {
+	tests := []struct {
+		name                string
+		endpoint            string
+		usePathStyle        bool
+		accessKeyID         string
+		expectedEndpoint    *string
+		expectedPathStyle   bool
+		expectedCredentials bool
+	}{
+		{
+			name: "aws s3",
+		},
+		{
+			name:                "s3 compatible endpoint with an access key",
+			endpoint:            "https://minio.example.com",
+			usePathStyle:        true,
+			accessKeyID:         "minio",
+			expectedEndpoint:    aws.String("https://minio.example.com"),
+			expectedPathStyle:   true,
+			expectedCredentials: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := s3.Options{}
+			S3CompatibleOptions(tt.endpoint, tt.usePathStyle, tt.accessKeyID, "secret")(&o)
+			assert.Equal(t, tt.expectedEndpoint, o.BaseEndpoint)
+			assert.Equal(t, tt.expectedPathStyle, o.UsePathStyle)
+			assert.Equal(t, tt.expectedCredentials, o.Credentials != nil)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package aws
+
+import (
+	"context"
+	"sync"
+)
+
+// AwsClientCache caches the AwsClients of each IRSASetup, so that the clients and the credentials they have obtained,
+// e.g. by assuming a role, are reused across reconciliations.
+// An IRSASetup has one entry, which is replaced when its credentials change, and one client per deployer role in it.
+type AwsClientCache struct {
+	mu        sync.Mutex
+	newClient func(ctx context.Context, c Credentials) (AwsClient, error)
+	setups    map[string]*cachedSetupClients
+}
+
+type cachedSetupClients struct {
+	// credentials are the credentials of the IRSASetup, without a deployer role
+	credentials Credentials
+	// clients are keyed by the deployer role they assume, the empty one being the client of the credentials themselves
+	clients map[string]cachedAwsClient
+}
+
+type cachedAwsClient struct {
+	credentials Credentials
+	client      AwsClient
+}
+
+// NewAwsClientCache returns a cache which creates the clients with newClient.
+func NewAwsClientCache(newClient func(ctx context.Context, c Credentials) (AwsClient, error)) *AwsClientCache {
+	return &AwsClientCache{
+		newClient: newClient,
+		setups:    map[string]*cachedSetupClients{},
+	}
+}
+
+// Get returns the client with the credentials of the IRSASetup identified by setup. The client is created when there is none yet,
+// or when the credentials have changed since, e.g. because the access key in a Secret has been rotated,
+// in which case it replaces the clients created with the former credentials.
+func (c *AwsClientCache) Get(ctx context.Context, setup string, credentials Credentials) (AwsClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	setupCredentials := credentials
+	setupCredentials.DeployerRoleArn = ""
+	setupCredentials.DeployerExternalID = ""
+	cached, ok := c.setups[setup]
+	if !ok || cached.credentials != setupCredentials {
+		cached = &cachedSetupClients{credentials: setupCredentials, clients: map[string]cachedAwsClient{}}
+		c.setups[setup] = cached
+	}
+	if client, ok := cached.clients[credentials.DeployerRoleArn]; ok && client.credentials == credentials {
+		return client.client, nil
+	}
+	client, err := c.newClient(ctx, credentials)
+	if err != nil {
+		return nil, err
+	}
+	cached.clients[credentials.DeployerRoleArn] = cachedAwsClient{credentials: credentials, client: client}
+	return client, nil
+}
+
+// Delete removes the clients of the IRSASetup identified by setup, e.g. once it has been deleted.
+func (c *AwsClientCache) Delete(setup string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.setups, setup)
+}
@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAwsClientCache_Get(t *testing.T) {
+	tests := []struct {
+		name        string
+		first       Credentials
+		second      Credentials
+		secondKey   string
+		expectedNew int
+		reused      bool
+		// expectedClients is the number of cached clients of the first IRSASetup
+		expectedClients int
+	}{
+		{
+			name:            "same credential set",
+			first:           Credentials{RoleArn: "arn:aws:iam::111111111111:role/irsa-manager"},
+			second:          Credentials{RoleArn: "arn:aws:iam::111111111111:role/irsa-manager"},
+			secondKey:       "a",
+			expectedNew:     1,
+			reused:          true,
+			expectedClients: 1,
+		},
+		{
+			name:            "rotated access key",
+			first:           Credentials{AccessKeyID: "AKIA1", SecretAccessKey: "secret1"},
+			second:          Credentials{AccessKeyID: "AKIA2", SecretAccessKey: "secret2"},
+			secondKey:       "a",
+			expectedNew:     2,
+			reused:          false,
+			expectedClients: 1,
+		},
+		{
+			name:            "changed role",
+			first:           Credentials{RoleArn: "arn:aws:iam::111111111111:role/irsa-manager", DeployerRoleArn: "arn:aws:iam::222222222222:role/irsa-deployer"},
+			second:          Credentials{RoleArn: "arn:aws:iam::111111111111:role/irsa-manager-2"},
+			secondKey:       "a",
+			expectedNew:     2,
+			reused:          false,
+			expectedClients: 1,
+		},
+		{
+			name:            "deployer role",
+			first:           Credentials{RoleArn: "arn:aws:iam::111111111111:role/irsa-manager"},
+			second:          Credentials{RoleArn: "arn:aws:iam::111111111111:role/irsa-manager", DeployerRoleArn: "arn:aws:iam::222222222222:role/irsa-deployer"},
+			secondKey:       "a",
+			expectedNew:     2,
+			reused:          false,
+			expectedClients: 2,
+		},
+		{
+			name:            "changed deployer external ID",
+			first:           Credentials{DeployerRoleArn: "arn:aws:iam::222222222222:role/irsa-deployer", DeployerExternalID: "1"},
+			second:          Credentials{DeployerRoleArn: "arn:aws:iam::222222222222:role/irsa-deployer", DeployerExternalID: "2"},
+			secondKey:       "a",
+			expectedNew:     2,
+			reused:          false,
+			expectedClients: 1,
+		},
+		{
+			name:            "another IRSASetup",
+			first:           Credentials{RoleArn: "arn:aws:iam::111111111111:role/irsa-manager"},
+			second:          Credentials{RoleArn: "arn:aws:iam::111111111111:role/irsa-manager"},
+			secondKey:       "b",
+			expectedNew:     2,
+			reused:          false,
+			expectedClients: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := 0
+			cache := NewAwsClientCache(func(_ context.Context, _ Credentials) (AwsClient, error) {
+				created++
+				return &AwsClientFactory{}, nil
+			})
+			first, err := cache.Get(context.TODO(), "a", tt.first)
+			assert.NoError(t, err)
+			second, err := cache.Get(context.TODO(), tt.secondKey, tt.second)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedNew, created)
+			assert.Equal(t, tt.reused, first == second)
+			assert.Len(t, cache.setups["a"].clients, tt.expectedClients)
+			cache.Delete("a")
+			assert.NotContains(t, cache.setups, "a")
+		})
+	}
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
@@ -16,7 +18,8 @@ import (
 type AwsClient interface {
 	IamClient() *AwsIamClient
 	StsClient() *AwsStsClient
-	S3Client(region, bucketName string) *AwsS3Client
+	S3Client(region, bucketName string, optFns ...func(*s3.Options)) *AwsS3Client
+	CloudFrontClient() *AwsCloudFrontClient
 }
 
 type AwsIamClient struct {
@@ -31,22 +34,80 @@ type AwsS3Client struct {
 	bucketName string
 }
 
+// Credentials configures the credentials of an AwsClientFactory.
+// The zero value means the credentials of the controller.
+type Credentials struct {
+	// AccessKeyID, SecretAccessKey and SessionToken are a static access key used instead of the credentials of the controller.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// RoleArn is the role assumed with the credentials, with ExternalID and SessionName if they are set.
+	RoleArn     string
+	ExternalID  string
+	SessionName string
+	// DeployerRoleArn is the role assumed in turn with the credentials of RoleArn, or the ones above if it is not set,
+	// with DeployerExternalID if it is set, e.g. to manage the resources of another AWS account.
+	DeployerRoleArn    string
+	DeployerExternalID string
+	// Region overrides the region of the controller.
+	Region string
+}
+
 func NewAwsClientFactory(ctx context.Context) (*AwsClientFactory, error) {
+	return NewAwsClientFactoryWithCredentials(ctx, Credentials{})
+}
+
+// NewAwsClientFactoryWithCredentials returns a factory whose clients use the given credentials.
+// Without a static access key, the credentials of the controller are used, including the role set by AWS_ROLE_ARN.
+func NewAwsClientFactoryWithCredentials(ctx context.Context, c Credentials) (*AwsClientFactory, error) {
+	optFns := []func(*config.LoadOptions) error{}
+	if c.Region != "" {
+		optFns = append(optFns, config.WithRegion(c.Region))
+	}
+	if c.AccessKeyID != "" {
+		optFns = append(optFns, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(c.AccessKeyID, c.SecretAccessKey, c.SessionToken),
+		))
+	}
 	cfg, err := config.LoadDefaultConfig(
 		ctx,
+		optFns...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("unable to load SDK config, %w", err)
 	}
-	roleArn := os.Getenv("AWS_ROLE_ARN")
-	if roleArn != "" {
-		stsSvc := sts.NewFromConfig(cfg)
-		creds := stscreds.NewAssumeRoleProvider(stsSvc, roleArn)
-		cfg.Credentials = aws.NewCredentialsCache(creds)
+	if c.AccessKeyID == "" {
+		cfg = assumeRole(cfg, os.Getenv("AWS_ROLE_ARN"), "", "")
 	}
+	cfg = assumeRole(cfg, c.RoleArn, c.ExternalID, c.SessionName)
+	cfg = assumeRole(cfg, c.DeployerRoleArn, c.DeployerExternalID, c.SessionName)
 	return &AwsClientFactory{config: cfg}, nil
 }
 
+// NewAwsClient is like NewAwsClientFactoryWithCredentials, but returns the factory as an AwsClient.
+func NewAwsClient(ctx context.Context, c Credentials) (AwsClient, error) {
+	return NewAwsClientFactoryWithCredentials(ctx, c)
+}
+
+// assumeRole returns the config whose credentials are the ones of the role assumed with the credentials of cfg.
+// It returns cfg as is if roleArn is empty.
+func assumeRole(cfg aws.Config, roleArn, externalID, sessionName string) aws.Config {
+	if roleArn == "" {
+		return cfg
+	}
+	stsSvc := sts.NewFromConfig(cfg)
+	creds := stscreds.NewAssumeRoleProvider(stsSvc, roleArn, func(o *stscreds.AssumeRoleOptions) {
+		if externalID != "" {
+			o.ExternalID = aws.String(externalID)
+		}
+		if sessionName != "" {
+			o.RoleSessionName = sessionName
+		}
+	})
+	cfg.Credentials = aws.NewCredentialsCache(creds)
+	return cfg
+}
+
 func (a *AwsClientFactory) IamClient() *AwsIamClient {
 	return &AwsIamClient{
 		iam.NewFromConfig(a.config),
@@ -59,10 +120,30 @@ func (a *AwsClientFactory) StsClient() *AwsStsClient {
 	}
 }
 
-func (a *AwsClientFactory) S3Client(region, bucketName string) *AwsS3Client {
+func (a *AwsClientFactory) S3Client(region, bucketName string, optFns ...func(*s3.Options)) *AwsS3Client {
 	return &AwsS3Client{
-		Client:     s3.NewFromConfig(a.config),
+		Client:     s3.NewFromConfig(a.config, optFns...),
 		region:     region,
 		bucketName: bucketName,
 	}
 }
+
+func (a *AwsClientFactory) CloudFrontClient() *AwsCloudFrontClient {
+	return &AwsCloudFrontClient{
+		cloudfront.NewFromConfig(a.config),
+	}
+}
+
+// S3CompatibleOptions returns the options to use an S3-compatible storage at the given endpoint.
+// The static access key is used instead of the AWS credentials when accessKeyID is not empty.
+func S3CompatibleOptions(endpoint string, usePathStyle bool, accessKeyID, secretAccessKey string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = usePathStyle
+		}
+		if accessKeyID != "" {
+			o.Credentials = credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, "")
+		}
+	}
+}
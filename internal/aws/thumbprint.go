@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// thumbprintTimeout is the timeout of the TLS handshake with the issuer.
+const thumbprintTimeout = 10 * time.Second
+
+// ThumbprintOptions configures how the certificate chain served by the issuer is verified.
+type ThumbprintOptions struct {
+	// CABundle holds PEM encoded CA certificates trusted in addition to the system roots.
+	CABundle []byte
+	// TrustOnFirstUse accepts a certificate chain that cannot be verified.
+	TrustOnFirstUse bool
+}
+
+// Thumbprint returns the thumbprint of the certificate of the issuer, which is the hex encoded SHA-1 hash
+// of the last certificate in the chain it serves, i.e. the top intermediate CA or the root CA,
+// and whether the chain has been verified.
+// The chain is verified against the system roots and the CA bundle, so that a man-in-the-middle cannot register its own CA.
+// A chain that cannot be verified is only accepted with TrustOnFirstUse.
+func Thumbprint(ctx context.Context, issuerUrl string, options ThumbprintOptions) (string, bool, error) {
+	u, err := url.Parse(issuerUrl)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid issuer URL %q, %w", issuerUrl, err)
+	}
+	if u.Scheme != "https" || u.Hostname() == "" {
+		return "", false, fmt.Errorf("invalid issuer URL %q, it must be an https URL", issuerUrl)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if len(options.CABundle) > 0 && !roots.AppendCertsFromPEM(options.CABundle) {
+		return "", false, errors.New("the CA bundle of the issuer has no PEM encoded certificate")
+	}
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: thumbprintTimeout},
+		Config: &tls.Config{
+			ServerName: u.Hostname(),
+			RootCAs:    roots,
+		},
+	}
+	verified := true
+	var certs []*x509.Certificate
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	var verificationErr *tls.CertificateVerificationError
+	switch {
+	case err == nil:
+		defer conn.Close()
+		certs = conn.(*tls.Conn).ConnectionState().PeerCertificates
+	case errors.As(err, &verificationErr) && options.TrustOnFirstUse:
+		verified = false
+		certs = verificationErr.UnverifiedCertificates
+	default:
+		return "", false, fmt.Errorf("unable to get the certificate of the issuer %s, %w", issuerUrl, err)
+	}
+	if len(certs) == 0 {
+		return "", false, fmt.Errorf("the issuer %s served no certificate", issuerUrl)
+	}
+	sum := sha1.Sum(certs[len(certs)-1].Raw)
+	return hex.EncodeToString(sum[:]), verified, nil
+}
@@ -0,0 +1,72 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThumbprint(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	sum := sha1.Sum(server.Certificate().Raw)
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := []struct {
+		name             string
+		issuerUrl        string
+		options          ThumbprintOptions
+		expected         string
+		expectedVerified bool
+		expectedErr      bool
+	}{
+		{
+			name:        "unverified certificate",
+			issuerUrl:   server.URL + "/cluster-a",
+			expectedErr: true,
+		},
+		{
+			name:             "certificate verified with the CA bundle",
+			issuerUrl:        server.URL + "/cluster-a",
+			options:          ThumbprintOptions{CABundle: caBundle},
+			expected:         hex.EncodeToString(sum[:]),
+			expectedVerified: true,
+		},
+		{
+			name:             "unverified certificate trusted on first use",
+			issuerUrl:        server.URL + "/cluster-a",
+			options:          ThumbprintOptions{TrustOnFirstUse: true},
+			expected:         hex.EncodeToString(sum[:]),
+			expectedVerified: false,
+		},
+		{
+			name:        "invalid CA bundle",
+			issuerUrl:   server.URL + "/cluster-a",
+			options:     ThumbprintOptions{CABundle: []byte("not a certificate")},
+			expectedErr: true,
+		},
+		{
+			name:        "http",
+			issuerUrl:   "http://oidc.example.com",
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumbprint, verified, err := Thumbprint(context.Background(), tt.issuerUrl, tt.options)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, thumbprint)
+			assert.Equal(t, tt.expectedVerified, verified)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+)
+
+// awsClientFor returns the AWS client with the credentials of the IRSASetup, or defaultClient if it sets none.
+// The clients are cached per IRSASetup, and the credentials Secret is read every time,
+// so that a rotated access key is picked up by the next reconciliation.
+func awsClientFor(ctx context.Context, obj *irsav1alpha1.IRSASetup, defaultClient awsclient.AwsClient, cache *awsclient.AwsClientCache, kubeClient *kubernetes.KubernetesClient) (awsclient.AwsClient, error) {
+	return awsClientForRole(ctx, obj, irsav1alpha1.IamRole{}, defaultClient, cache, kubeClient)
+}
+
+// awsClientForRole is like awsClientFor, but returns the client which manages the IAM role,
+// i.e. the one of the deployer role of the IAM role assumed with the credentials of the IRSASetup if it is set.
+func awsClientForRole(ctx context.Context, obj *irsav1alpha1.IRSASetup, role irsav1alpha1.IamRole, defaultClient awsclient.AwsClient, cache *awsclient.AwsClientCache, kubeClient *kubernetes.KubernetesClient) (awsclient.AwsClient, error) {
+	c := obj.Spec.Credentials
+	if c == nil && role.DeployerRoleArn == "" {
+		return defaultClient, nil
+	}
+	credentials := awsclient.Credentials{
+		DeployerRoleArn:    role.DeployerRoleArn,
+		DeployerExternalID: role.ExternalID,
+	}
+	if c != nil {
+		credentials.RoleArn = c.RoleArn
+		credentials.ExternalID = c.ExternalID
+		credentials.SessionName = c.SessionName
+		credentials.Region = c.Region
+	}
+	if c != nil && c.SecretRef != nil {
+		ref := c.SecretRef
+		secret, err := getSecret(ctx, kubeClient, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the AWS credentials Secret %s/%s, %w", ref.Namespace, ref.Name, err)
+		}
+		credentials.AccessKeyID = string(secret.Data[irsav1alpha1.AwsAccessKeyIDKey])
+		credentials.SecretAccessKey = string(secret.Data[irsav1alpha1.AwsSecretAccessKeyKey])
+		credentials.SessionToken = string(secret.Data[irsav1alpha1.AwsSessionTokenKey])
+		if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+			return nil, fmt.Errorf("the AWS credentials Secret %s/%s must have the %q and %q keys", ref.Namespace, ref.Name, irsav1alpha1.AwsAccessKeyIDKey, irsav1alpha1.AwsSecretAccessKeyKey)
+		}
+	}
+	return cache.Get(ctx, awsClientCacheKey(obj), credentials)
+}
+
+// awsClientCacheKey identifies the clients of the IRSASetup in the cache.
+func awsClientCacheKey(obj *irsav1alpha1.IRSASetup) string {
+	return types.NamespacedName{Name: obj.Name, Namespace: obj.Namespace}.String()
+}
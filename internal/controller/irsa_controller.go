@@ -19,6 +19,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
 	"github.com/kkb0318/irsa-manager/internal/handler"
@@ -26,6 +27,7 @@ import (
 	"github.com/kkb0318/irsa-manager/internal/kubernetes"
 	"github.com/kkb0318/irsa-manager/internal/manifests"
 	"github.com/kkb0318/irsa-manager/internal/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -41,6 +43,8 @@ type IRSAReconciler struct {
 	client.Client
 	Scheme    *runtime.Scheme
 	AwsClient awsclient.AwsClient
+	// AwsClientCache caches the AWS clients of the IRSASetups with credentials.
+	AwsClientCache *awsclient.AwsClientCache
 }
 
 //+kubebuilder:rbac:groups=irsa-manager.kkb0318.github.io,resources=irsas,verbs=get;list;watch;create;update;patch;delete
@@ -48,6 +52,7 @@ type IRSAReconciler struct {
 //+kubebuilder:rbac:groups=irsa-manager.kkb0318.github.io,resources=irsas/finalizers,verbs=update
 //+kubebuilder:rbac:groups=irsa-manager.kkb0318.github.io,resources=irsasetups,verbs=get;list
 //+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -64,6 +69,9 @@ func (r *IRSAReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		}
 		r.AwsClient = awsClient
 	}
+	if r.AwsClientCache == nil {
+		r.AwsClientCache = awsclient.NewAwsClientCache(awsclient.NewAwsClient)
+	}
 	kubeClient, err := kubernetes.NewKubernetesClient(r.Client, kubernetes.Owner{Field: "irsa-manager"})
 	if err != nil {
 		return ctrl.Result{}, err
@@ -112,11 +120,16 @@ func (r *IRSAReconciler) reconcileDelete(ctx context.Context, obj *irsav1alpha1.
 	if !obj.Spec.Cleanup {
 		return nil
 	}
+	awsClient, reason, err := r.deletionAwsClient(ctx, obj, kubeClient)
+	if err != nil {
+		*obj = irsav1alpha1.IRSAStatusNotReady(*obj, string(reason), err.Error())
+		return err
+	}
 	roleManager := awsclient.RoleManager{
 		RoleName: obj.Spec.IamRole.Name,
 		Policies: obj.Spec.IamPolicies,
 	}
-	err := r.AwsClient.IamClient().DeleteIRSARole(
+	err = awsClient.IamClient().DeleteIRSARole(
 		ctx,
 		roleManager,
 	)
@@ -131,24 +144,32 @@ func (r *IRSAReconciler) reconcileDelete(ctx context.Context, obj *irsav1alpha1.
 	return nil
 }
 
-func (r *IRSAReconciler) reconcile(ctx context.Context, obj *irsav1alpha1.IRSA, kubeClient *kubernetes.KubernetesClient) error {
-	list, err := kubeClient.List(ctx, irsav1alpha1.GroupVersion.WithKind(irsav1alpha1.IRSASetupKind))
-	if err != nil {
-		return err
+// deletionAwsClient returns the AWS client which deletes the IAM role.
+// When the IRSASetup has been deleted first, the credentials of the controller are used only if the role has been created
+// in their account without a deployer role, so that a role is never deleted in another account with the same name.
+func (r *IRSAReconciler) deletionAwsClient(ctx context.Context, obj *irsav1alpha1.IRSA, kubeClient *kubernetes.KubernetesClient) (awsclient.AwsClient, irsav1alpha1.IRSAReason, error) {
+	irsaSetup, reason, err := r.irsaSetup(ctx, obj, kubeClient)
+	if err == nil {
+		awsClient, err := awsClientForRole(ctx, irsaSetup, obj.Spec.IamRole, r.AwsClient, r.AwsClientCache, kubeClient)
+		if err != nil {
+			return nil, irsav1alpha1.IRSAReasonFailedCredentials, err
+		}
+		return awsClient, "", nil
 	}
-	if len(list.Items) != 1 {
-		return fmt.Errorf("there should be exactly one IRSASetup item")
+	if obj.Spec.IamRole.DeployerRoleArn != "" || obj.Status.AccountID == "" {
+		return nil, reason, fmt.Errorf("unable to delete the IAM role without its IRSASetup, restore the IRSASetup or disable cleanup to keep the role: %v", err)
 	}
-	irsaSetup := &irsav1alpha1.IRSASetup{}
-	err = runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[0].Object, irsaSetup)
-	if err != nil {
-		return fmt.Errorf("error converting to IRSASetup for %s: %v", list.Items[0].GetName(), err)
+	accountId, e := r.AwsClient.StsClient().GetAccountId()
+	if e != nil {
+		return nil, irsav1alpha1.IRSAReasonFailedCredentials, e
 	}
-	serviceAccount := obj.Spec.ServiceAccount
-	issuerMeta, err := issuer.NewOIDCIssuerMeta(irsaSetup)
-	if err != nil {
-		return err
+	if accountId != obj.Status.AccountID {
+		return nil, reason, fmt.Errorf("unable to delete the IAM role in the account %s with the credentials of the controller, restore the IRSASetup or disable cleanup to keep the role: %v", obj.Status.AccountID, err)
 	}
+	return r.AwsClient, "", nil
+}
+
+func (r *IRSAReconciler) reconcile(ctx context.Context, obj *irsav1alpha1.IRSA, kubeClient *kubernetes.KubernetesClient) error {
 	// e is set only when an error occurs in an external dependency process and is reflected in the CRs status
 	var e error
 	var reason irsav1alpha1.IRSAReason
@@ -158,18 +179,49 @@ func (r *IRSAReconciler) reconcile(ctx context.Context, obj *irsav1alpha1.IRSA,
 		}
 	}()
 
-	accountId, err := r.AwsClient.StsClient().GetAccountId()
+	irsaSetup, reason, err := r.irsaSetup(ctx, obj, kubeClient)
+	if err != nil {
+		e = err
+		return err
+	}
+	awsClient, err := awsClientForRole(ctx, irsaSetup, obj.Spec.IamRole, r.AwsClient, r.AwsClientCache, kubeClient)
 	if err != nil {
 		e = err
+		reason = irsav1alpha1.IRSAReasonFailedCredentials
 		return err
 	}
+	serviceAccount := obj.Spec.ServiceAccount
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(irsaSetup)
+	if err != nil {
+		return err
+	}
+
+	accountId, err := awsClient.StsClient().GetAccountId()
+	if err != nil {
+		e = err
+		return err
+	}
+	obj.Status.AccountID = accountId
 	roleManager := awsclient.RoleManager{
 		RoleName:       obj.Spec.IamRole.Name,
 		ServiceAccount: serviceAccount,
 		Policies:       obj.Spec.IamPolicies,
+		Audience:       obj.TokenAudience(irsaSetup),
 		AccountId:      accountId,
 	}
-	err = r.AwsClient.IamClient().UpdateIRSARole(
+	if obj.Spec.IamRole.DeployerRoleArn != "" {
+		// the role in another account trusts the IAM OIDC provider of the issuer in that account
+		err = ensureRoleAccountOIDCProvider(ctx, irsaSetup, issuerMeta, awsClient, accountId, roleManager.Audience)
+	} else if !slices.Contains(irsaSetup.ClientIDs(), roleManager.Audience) {
+		// the audiences of the IRSASetup are registered by it, other ones are registered by the IRSAs using them
+		err = awsClient.IamClient().EnsureOIDCProviderClientID(ctx, accountId, issuerMeta.IssuerHostPath(), roleManager.Audience)
+	}
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.IRSAReasonFailedRoleUpdate
+		return err
+	}
+	err = awsClient.IamClient().UpdateIRSARole(
 		ctx,
 		issuerMeta,
 		roleManager,
@@ -208,6 +260,71 @@ func (r *IRSAReconciler) reconcile(ctx context.Context, obj *irsav1alpha1.IRSA,
 	return nil
 }
 
+// irsaSetup returns the IRSASetup referenced by the IRSA, or the only one in the cluster if the IRSA references none.
+// When the IRSASetup cannot be determined, the reason to be reflected in the status of the IRSA is returned with the error.
+func (r *IRSAReconciler) irsaSetup(ctx context.Context, obj *irsav1alpha1.IRSA, kubeClient *kubernetes.KubernetesClient) (*irsav1alpha1.IRSASetup, irsav1alpha1.IRSAReason, error) {
+	irsaSetup := &irsav1alpha1.IRSASetup{}
+	if ref := obj.Spec.SetupRef; ref != nil {
+		namespacedName := types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}
+		if namespacedName.Namespace == "" {
+			namespacedName.Namespace = obj.Namespace
+		}
+		if err := r.Get(ctx, namespacedName, irsaSetup); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, irsav1alpha1.IRSAReasonSetupNotFound, fmt.Errorf("the referenced IRSASetup %s is not found", namespacedName)
+			}
+			return nil, irsav1alpha1.IRSAReasonSetupNotFound, err
+		}
+		if !irsaSetup.AllowsNamespace(obj.Namespace) {
+			// the IRSAs bound to an IRSASetup use its credentials, which must not be used from any namespace
+			return nil, irsav1alpha1.IRSAReasonSetupNotAllowed, fmt.Errorf("the referenced IRSASetup %s does not allow IRSAs in the namespace %s, add it to allowedNamespaces of the IRSASetup", namespacedName, obj.Namespace)
+		}
+		return irsaSetup, "", nil
+	}
+	list, err := kubeClient.List(ctx, irsav1alpha1.GroupVersion.WithKind(irsav1alpha1.IRSASetupKind))
+	if err != nil {
+		return nil, irsav1alpha1.IRSAReasonSetupNotFound, err
+	}
+	switch len(list.Items) {
+	case 0:
+		return nil, irsav1alpha1.IRSAReasonSetupNotFound, fmt.Errorf("no IRSASetup is found, create one or set setupRef")
+	case 1:
+	default:
+		return nil, irsav1alpha1.IRSAReasonSetupAmbiguous, fmt.Errorf("there are %d IRSASetups, set setupRef to select one of them", len(list.Items))
+	}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[0].Object, irsaSetup)
+	if err != nil {
+		return nil, irsav1alpha1.IRSAReasonSetupNotFound, fmt.Errorf("error converting to IRSASetup for %s: %v", list.Items[0].GetName(), err)
+	}
+	return irsaSetup, "", nil
+}
+
+// ensureRoleAccountOIDCProvider creates the IAM OIDC provider of the issuer in the account of the AWS client if it does not exist,
+// with the client IDs of the IRSASetup and the thumbprint it has recorded, or adds the audience of the IRSA to the existing one.
+// The client IDs registered in the account of the IRSASetup are not removed from it, and the IRSASetup deletes it once no IRSA uses it.
+func ensureRoleAccountOIDCProvider(ctx context.Context, irsaSetup *irsav1alpha1.IRSASetup, issuerMeta issuer.OIDCIssuerMeta, awsClient awsclient.AwsClient, accountId, audience string) error {
+	provider, err := awsClient.IamClient().GetOIDCProvider(ctx, accountId, issuerMeta.IssuerHostPath())
+	if err != nil {
+		return err
+	}
+	if provider != nil {
+		if slices.Contains(provider.ClientIDList, audience) {
+			return nil
+		}
+		return awsClient.IamClient().AddOIDCProviderClientID(ctx, accountId, issuerMeta.IssuerHostPath(), audience)
+	}
+	clientIDs := irsaSetup.ClientIDs()
+	if !slices.Contains(clientIDs, audience) {
+		clientIDs = append(clientIDs, audience)
+	}
+	thumbprints := irsaSetup.Spec.Thumbprints
+	if status := irsaSetup.Status.OIDCProvider; len(thumbprints) == 0 && !issuerMeta.ServedByAWS() &&
+		status != nil && status.IssuerURL == issuerMeta.IssuerUrl() && status.Thumbprint != "" {
+		thumbprints = []string{status.Thumbprint}
+	}
+	return awsClient.IamClient().CreateOIDCProvider(ctx, issuerMeta.IssuerUrl(), clientIDs, thumbprints)
+}
+
 func cleanupKubernetesResources(ctx context.Context, client *kubernetes.KubernetesClient, nsNames []types.NamespacedName) ([]types.NamespacedName, error) {
 	kubeHandler := handler.NewKubernetesHandler(client)
 	for _, namespacedName := range nsNames {
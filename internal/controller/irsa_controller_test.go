@@ -22,7 +22,9 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -30,6 +32,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
 )
 
 var _ = Describe("IRSA Controller", func() {
@@ -166,6 +169,321 @@ var _ = Describe("IRSA Controller", func() {
 					}
 				},
 			},
+			{
+				name: "audience of the IRSA",
+				obj: &irsav1alpha1.IRSA{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-audience",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASpec{
+						Cleanup: true,
+						ServiceAccount: irsav1alpha1.IRSAServiceAccount{
+							Name:       "sa-audience",
+							Namespaces: []string{"default"},
+						},
+						Audience: "app.example.com",
+					},
+				},
+				irsaSetupObj: newMockIRSASetup(),
+				f: func(r *IRSAReconciler, obj *irsav1alpha1.IRSA) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					iamAPI := &mockAwsIamAPI{}
+					r.AwsClient = newMockAwsClient(iamAPI, nil, nil)
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					By("requiring the audience in the trust policy")
+					Expect(iamAPI.assumeRolePolicy).To(ContainSubstring(`"s3-ap-northeast-1.amazonaws.com/irsa-manager-1:aud":"app.example.com"`))
+					By("registering the audience in the IAM OIDC provider")
+					Expect(iamAPI.clientIDList).To(ContainElement("app.example.com"))
+					By("annotating the service account with the audience")
+					sa := newServiceAccount()
+					Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sa-audience", Namespace: "default"}, sa)).To(Succeed())
+					Expect(sa.GetAnnotations()).To(HaveKeyWithValue("eks.amazonaws.com/audience", "app.example.com"))
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+				},
+			},
+			{
+				name: "should reconcile against the referenced IRSASetup",
+				obj: &irsav1alpha1.IRSA{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-setup-ref",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASpec{
+						Cleanup: true,
+						ServiceAccount: irsav1alpha1.IRSAServiceAccount{
+							Name:       "sa-setup-ref",
+							Namespaces: []string{"default"},
+						},
+					},
+				},
+				irsaSetupObj: newMockIRSASetup(),
+				f: func(r *IRSAReconciler, obj *irsav1alpha1.IRSA) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					readyReason := func() string {
+						current := &irsav1alpha1.IRSA{}
+						Expect(k8sClient.Get(ctx, typeNamespacedName, current)).To(Succeed())
+						condition := apimeta.FindStatusCondition(current.Status.Conditions, irsav1alpha1.ReadyCondition)
+						Expect(condition).NotTo(BeNil())
+						return condition.Reason
+					}
+					setSetupRef := func(ref *irsav1alpha1.IRSASetupReference) {
+						Eventually(func() error {
+							Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+							obj.Spec.SetupRef = ref
+							return k8sClient.Update(ctx, obj)
+						}, timeout).Should(Succeed())
+					}
+					second := newMockIRSASetup()
+					second.Name = "test-2"
+					second.Spec.Discovery.S3.BucketName = "irsa-manager-2"
+					Expect(k8sClient.Create(ctx, second)).To(Succeed())
+
+					By("failing when there are several IRSASetups and none is referenced")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(readyReason()).To(Equal(string(irsav1alpha1.IRSAReasonSetupAmbiguous)))
+
+					By("failing when the referenced IRSASetup does not exist")
+					setSetupRef(&irsav1alpha1.IRSASetupReference{Name: "missing"})
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(readyReason()).To(Equal(string(irsav1alpha1.IRSAReasonSetupNotFound)))
+
+					By("failing when the referenced IRSASetup in another namespace does not allow the namespace of the IRSA")
+					third := newMockIRSASetup()
+					third.Name = "test-3"
+					third.Namespace = "kube-system"
+					third.Spec.Discovery.S3.BucketName = "irsa-manager-3"
+					Expect(k8sClient.Create(ctx, third)).To(Succeed())
+					setSetupRef(&irsav1alpha1.IRSASetupReference{Name: "test-3", Namespace: "kube-system"})
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(readyReason()).To(Equal(string(irsav1alpha1.IRSAReasonSetupNotAllowed)))
+
+					By("trusting the issuer of the referenced IRSASetup in another namespace that allows the namespace of the IRSA")
+					Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(third), third)).To(Succeed())
+					third.Spec.AllowedNamespaces = []string{obj.Namespace}
+					Expect(k8sClient.Update(ctx, third)).To(Succeed())
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{}, nil, nil)
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(readyReason()).To(Equal(string(irsav1alpha1.IRSAReasonReady)))
+					Expect(k8sClient.Delete(ctx, third)).To(Succeed())
+
+					By("trusting the issuer of the referenced IRSASetup")
+					setSetupRef(&irsav1alpha1.IRSASetupReference{Name: "test-2", Namespace: "default"})
+					iamAPI := &mockAwsIamAPI{}
+					r.AwsClient = newMockAwsClient(iamAPI, nil, nil)
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(readyReason()).To(Equal(string(irsav1alpha1.IRSAReasonReady)))
+					Expect(iamAPI.assumeRolePolicy).To(ContainSubstring("oidc-provider/s3-ap-northeast-1.amazonaws.com/irsa-manager-2"))
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+					Expect(k8sClient.Delete(ctx, second)).To(Succeed())
+				},
+			},
+			{
+				name: "should use the credentials of the IRSASetup",
+				obj: &irsav1alpha1.IRSA{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-credentials",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASpec{
+						Cleanup: true,
+						ServiceAccount: irsav1alpha1.IRSAServiceAccount{
+							Name:       "sa-credentials",
+							Namespaces: []string{"default"},
+						},
+						SetupRef: &irsav1alpha1.IRSASetupReference{Name: "test-credentials"},
+					},
+				},
+				irsaSetupObj: newMockIRSASetup(),
+				f: func(r *IRSAReconciler, obj *irsav1alpha1.IRSA) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					secret := &corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Name: "aws-credentials", Namespace: "default"},
+						Data: map[string][]byte{
+							irsav1alpha1.AwsAccessKeyIDKey:     []byte("AKIA1"),
+							irsav1alpha1.AwsSecretAccessKeyKey: []byte("secret1"),
+						},
+					}
+					Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+					setup := newMockIRSASetup()
+					setup.Name = "test-credentials"
+					setup.Spec.Credentials = &irsav1alpha1.AwsCredentials{
+						SecretRef:  &irsav1alpha1.AwsCredentialsSecretReference{Name: "aws-credentials", Namespace: "default"},
+						RoleArn:    "arn:aws:iam::222222222222:role/irsa-manager",
+						ExternalID: "external-id",
+					}
+					Expect(k8sClient.Create(ctx, setup)).To(Succeed())
+
+					defaultAPI := &mockAwsIamAPI{}
+					r.AwsClient = newMockAwsClient(defaultAPI, nil, nil)
+					iamAPI := &mockAwsIamAPI{}
+					created := []awsclient.Credentials{}
+					r.AwsClientCache = awsclient.NewAwsClientCache(func(_ context.Context, c awsclient.Credentials) (awsclient.AwsClient, error) {
+						created = append(created, c)
+						return newMockAwsClient(iamAPI, nil, &mockAwsStsAPI{account: "222222222222"}), nil
+					})
+
+					By("managing the role with the credentials of the IRSASetup")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(iamAPI.assumeRolePolicy).NotTo(BeEmpty())
+					Expect(defaultAPI.assumeRolePolicy).To(BeEmpty())
+					Expect(created).To(Equal([]awsclient.Credentials{{
+						AccessKeyID:     "AKIA1",
+						SecretAccessKey: "secret1",
+						RoleArn:         "arn:aws:iam::222222222222:role/irsa-manager",
+						ExternalID:      "external-id",
+					}}))
+
+					By("reusing the cached client")
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(created).To(HaveLen(1))
+
+					By("picking up the rotated access key")
+					secret.Data[irsav1alpha1.AwsAccessKeyIDKey] = []byte("AKIA2")
+					secret.Data[irsav1alpha1.AwsSecretAccessKeyKey] = []byte("secret2")
+					Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(created).To(HaveLen(2))
+					Expect(created[1].AccessKeyID).To(Equal("AKIA2"))
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.AccountID).To(Equal("222222222222"))
+
+					By("keeping the role in another account when the IRSASetup has been deleted first")
+					Expect(k8sClient.Delete(ctx, setup)).To(Succeed())
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Finalizers).To(ContainElement(irsamanagerFinalizer))
+
+					By("removing the custom resource for the Kind without cleanup")
+					obj.Spec.Cleanup = false
+					Expect(k8sClient.Update(ctx, obj)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+					Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+				},
+			},
+			{
+				name: "should create the role in another account",
+				obj: &irsav1alpha1.IRSA{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-cross-account",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASpec{
+						Cleanup: true,
+						ServiceAccount: irsav1alpha1.IRSAServiceAccount{
+							Name:       "sa-cross-account",
+							Namespaces: []string{"default"},
+						},
+						IamRole: irsav1alpha1.IamRole{
+							Name:            "cross-account-role",
+							DeployerRoleArn: "arn:aws:iam::333333333333:role/irsa-deployer",
+							ExternalID:      "external-id",
+						},
+					},
+				},
+				irsaSetupObj: newMockIRSASetup(),
+				f: func(r *IRSAReconciler, obj *irsav1alpha1.IRSA) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					defaultAPI := &mockAwsIamAPI{}
+					r.AwsClient = newMockAwsClient(defaultAPI, nil, nil)
+					iamAPI := &mockAwsIamAPI{oidcNotFound: true}
+					created := []awsclient.Credentials{}
+					r.AwsClientCache = awsclient.NewAwsClientCache(func(_ context.Context, c awsclient.Credentials) (awsclient.AwsClient, error) {
+						created = append(created, c)
+						return newMockAwsClient(iamAPI, nil, &mockAwsStsAPI{account: "333333333333"}), nil
+					})
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					By("assuming the deployer role")
+					Expect(created).To(Equal([]awsclient.Credentials{{
+						DeployerRoleArn:    "arn:aws:iam::333333333333:role/irsa-deployer",
+						DeployerExternalID: "external-id",
+					}}))
+					By("creating the IAM OIDC provider and the role in the account of the deployer role")
+					Expect(iamAPI.clientIDList).To(ContainElement("sts.amazonaws.com"))
+					Expect(iamAPI.assumeRolePolicy).To(ContainSubstring("arn:aws:iam::333333333333:oidc-provider/s3-ap-northeast-1.amazonaws.com/irsa-manager-1"))
+					Expect(defaultAPI.assumeRolePolicy).To(BeEmpty())
+					By("annotating the service account with the role in the other account")
+					sa := newServiceAccount()
+					Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sa-cross-account", Namespace: "default"}, sa)).To(Succeed())
+					Expect(sa.GetAnnotations()).To(HaveKeyWithValue("eks.amazonaws.com/role-arn", "arn:aws:iam::333333333333:role/cross-account-role"))
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+				},
+			},
 			{
 				name: "should update serviceaccount successfully",
 				obj: &irsav1alpha1.IRSA{
@@ -0,0 +1,63 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/kkb0318/irsa-manager/internal/handler"
+	"github.com/kkb0318/irsa-manager/internal/issuer"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+	"github.com/kkb0318/irsa-manager/internal/manifests"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+)
+
+// apiServerConfigMapName returns the name of the ConfigMap holding the configuration of the kube-apiserver.
+func apiServerConfigMapName(obj *irsav1alpha1.IRSASetup) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      obj.Name + "-apiserver-config",
+		Namespace: obj.Namespace,
+	}
+}
+
+// reconcileAPIServerConfig publishes the configuration of the kube-apiserver required by the issuer in the status and a ConfigMap.
+// It is derived from the same issuer as the discovery documents and the IAM OIDC provider.
+func reconcileAPIServerConfig(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) error {
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+	if err != nil {
+		return err
+	}
+	config := selfhosted.NewAPIServerConfig(issuerMeta.IssuerUrl(), obj.ClientIDs(), !obj.Spec.SigningKey.IsExternal())
+	builder, err := manifests.NewConfigMapBuilder().WithAPIServerConfig(config)
+	if err != nil {
+		return err
+	}
+	namespacedName := apiServerConfigMapName(obj)
+	kubeHandler := handler.NewKubernetesHandler(kubeClient)
+	kubeHandler.Append(builder.Build(namespacedName))
+	if _, err := kubeHandler.ApplyAll(ctx); err != nil {
+		return err
+	}
+	obj.Status.APIServer = &irsav1alpha1.APIServerStatus{
+		Flags:         config.Flags(),
+		ConfigMapName: namespacedName.Name,
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted/oidc"
+)
+
+// cloudFrontDeletionRequeueAfter is the period after which the deletion of a distribution that is being disabled is retried.
+const cloudFrontDeletionRequeueAfter = time.Minute
+
+// reconcileCloudFront ensures the CloudFront distribution in front of the S3 bucket when it is configured,
+// and records it in the status. The issuer is derived from the recorded domain name unless an alias is set,
+// so this has to be done before the discovery documents and the IAM OIDC provider are set up.
+func reconcileCloudFront(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient) error {
+	if obj.Spec.Discovery.S3.CloudFront == nil {
+		return nil
+	}
+	status, err := newCloudFrontDistribution(obj, awsClient).Ensure(ctx, obj.Status.CloudFront)
+	if err != nil {
+		return err
+	}
+	obj.Status.CloudFront = status
+	return nil
+}
+
+// deleteCloudFront deletes the CloudFront distribution if it was created by irsa-manager.
+// It returns awsclient.ErrDistributionInProgress until the distribution has been disabled.
+func deleteCloudFront(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient) error {
+	if obj.Spec.Discovery.S3.CloudFront == nil {
+		return nil
+	}
+	return newCloudFrontDistribution(obj, awsClient).Delete(ctx, obj.Status.CloudFront)
+}
+
+func newCloudFrontDistribution(obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient) *oidc.CloudFrontDistribution {
+	return oidc.NewCloudFrontDistribution(awsClient, obj.Spec.Discovery.S3, client.ObjectKeyFromObject(obj).String(), string(obj.UID))
+}
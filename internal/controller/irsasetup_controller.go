@@ -18,12 +18,24 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	ctrlhandler "sigs.k8s.io/controller-runtime/pkg/handler"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
 	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
@@ -34,29 +46,49 @@ import (
 	"github.com/kkb0318/irsa-manager/internal/manifests"
 	"github.com/kkb0318/irsa-manager/internal/selfhosted"
 	"github.com/kkb0318/irsa-manager/internal/selfhosted/oidc"
-	"github.com/kkb0318/irsa-manager/internal/selfhosted/webhook"
 )
 
 const irsamanagerFinalizer = "irsa-manager.kkb0318.github.io/finalizers"
 
+// jwksFileName is the name of the JWKS published next to the discovery document.
+const jwksFileName = "keys.json"
+
 // IRSASetupReconciler reconciles a IRSASetup object
 type IRSASetupReconciler struct {
 	client.Client
 	Scheme    *runtime.Scheme
 	AwsClient awsclient.AwsClient
+	// AwsClientCache caches the AWS clients of the IRSASetups with credentials.
+	AwsClientCache *awsclient.AwsClientCache
+	// APIServerClient reads the service account issuer discovery documents of the kube-apiserver.
+	APIServerClient rest.Interface
+	// NodeAgentImage is the image of the node agents of the IRSASetups that do not set one.
+	NodeAgentImage string
 }
 
 //+kubebuilder:rbac:groups=irsa-manager.kkb0318.github.io,resources=irsasetups,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=irsa-manager.kkb0318.github.io,resources=irsasetups/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=irsa-manager.kkb0318.github.io,resources=irsasetups/finalizers,verbs=update
+//+kubebuilder:rbac:groups=irsa-manager.kkb0318.github.io,resources=irsas,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=serviceaccounts/token,verbs=create
 //+kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="apps",resources=daemonsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="policy",resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="coordination.k8s.io",resources=leases,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=roles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="certificates.k8s.io",resources=certificatesigningrequests,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="cert-manager.io",resources=certificates;issuers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=clusterroles,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="admissionregistration.k8s.io",resources=mutatingwebhookconfigurations,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:urls=/.well-known/openid-configuration;/openid/v1/jwks,verbs=get
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -73,6 +105,9 @@ func (r *IRSASetupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 		r.AwsClient = awsClient
 	}
+	if r.AwsClientCache == nil {
+		r.AwsClientCache = awsclient.NewAwsClientCache(awsclient.NewAwsClient)
+	}
 	kubeClient, err := kubernetes.NewKubernetesClient(r.Client, kubernetes.Owner{Field: "irsa-manager"})
 	if err != nil {
 		return ctrl.Result{}, err
@@ -97,11 +132,30 @@ func (r *IRSASetupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}()
 
+	// the AWS resources are only managed by the IRSASetup in the selfhosted mode
+	awsClient := r.AwsClient
+	if obj.Spec.Mode != irsav1alpha1.ModeEks {
+		awsClient, err = awsClientFor(ctx, obj, r.AwsClient, r.AwsClientCache, kubeClient)
+		if err != nil && !obj.DeletionTimestamp.IsZero() && apierrors.IsNotFound(err) {
+			// the AWS resources cannot be deleted with the credentials of the controller, which may belong to another account
+			log.Error(err, "the credentials Secret has been deleted, the AWS resources of the IRSASetup are kept")
+			awsClient, err = nil, nil
+		}
+		if err != nil {
+			*obj = irsav1alpha1.StatusNotReady(*obj, string(irsav1alpha1.SelfHostedReasonFailedCredentials), err.Error())
+			return ctrl.Result{}, err
+		}
+	}
+
 	if !obj.DeletionTimestamp.IsZero() {
 		if obj.Spec.Mode == irsav1alpha1.ModeEks {
 			err = r.reconcileDeleteEks()
 		} else {
-			err = r.reconcileDeleteSelfhosted(ctx, obj, kubeClient)
+			err = r.reconcileDeleteSelfhosted(ctx, obj, awsClient, kubeClient)
+		}
+		if errors.Is(err, awsclient.ErrDistributionInProgress) {
+			log.Info("waiting for the CloudFront distribution to be disabled")
+			return ctrl.Result{RequeueAfter: cloudFrontDeletionRequeueAfter}, nil
 		}
 		if err != nil {
 			return ctrl.Result{}, err
@@ -109,52 +163,102 @@ func (r *IRSASetupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		controllerutil.RemoveFinalizer(obj, irsamanagerFinalizer)
 		err = r.Update(ctx, obj)
 		if err == nil {
+			r.AwsClientCache.Delete(awsClientCacheKey(obj))
 			log.Info("successfully deleted")
 		}
 		return ctrl.Result{}, err
 	}
 
-	if err := r.reconcile(ctx, obj, kubeClient); err != nil {
+	result, err := r.reconcile(ctx, obj, awsClient, kubeClient)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 
 	log.Info("successfully reconciled")
-	return ctrl.Result{}, nil
+	return result, nil
 }
 
-func (r *IRSASetupReconciler) reconcile(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) error {
+func (r *IRSASetupReconciler) reconcile(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient) (ctrl.Result, error) {
 	if obj.Spec.Mode == irsav1alpha1.ModeEks {
-		return reconcileEks(ctx, obj)
+		return ctrl.Result{}, reconcileEks(ctx, obj)
+	}
+	var apiServer *selfhosted.APIServerDiscovery
+	if r.APIServerClient != nil {
+		apiServer = selfhosted.NewAPIServerDiscovery(r.APIServerClient)
+	}
+	result, err := reconcileSelfhosted(ctx, obj, awsClient, kubeClient, apiServer)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileRoleAccountOIDCProviders(ctx, obj, kubeClient); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := reconcileAPIServerConfig(ctx, obj, kubeClient); err != nil {
+		return ctrl.Result{}, err
+	}
+	nodeAgentRequeueAfter, err := r.reconcileNodeAgent(ctx, obj, kubeClient)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var tokenIssuanceRequeueAfter time.Duration
+	if irsav1alpha1.IsReadyConditionTrue(*obj) {
+		tokenIssuanceRequeueAfter, err = r.reconcileTokenIssuance(ctx, obj, awsClient, kubeClient)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 	}
-	return reconcileSelfhosted(ctx, obj, r.AwsClient, kubeClient)
+	result.RequeueAfter = shortestRequeue(result.RequeueAfter, nodeAgentRequeueAfter, tokenIssuanceRequeueAfter)
+	return result, nil
 }
 
 func (r *IRSASetupReconciler) reconcileDeleteEks() error {
 	return nil
 }
 
-func (r *IRSASetupReconciler) reconcileDeleteSelfhosted(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) error {
+// reconcileDeleteSelfhosted deletes the resources of the IRSASetup when Cleanup is enabled.
+// The AWS resources are kept when awsClient is nil, i.e. the credentials Secret has been deleted before the IRSASetup.
+func (r *IRSASetupReconciler) reconcileDeleteSelfhosted(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient) error {
 	if !obj.Spec.Cleanup {
 		return nil
 	}
-	factory, err := newOIDCIdpFactory(ctx, obj, nil, r.AwsClient)
-	if err != nil {
-		return err
-	}
 	secret, err := manifests.NewSecretBuilder().Build(manifests.SshKeyNamespacedName())
 	if err != nil {
 		return err
 	}
 	kubeHandler := handler.NewKubernetesHandler(kubeClient)
 	kubeHandler.Append(secret)
-	webhookSetup, err := webhook.NewWebHookSetup()
+	kubeHandler.Append(manifests.NewConfigMapBuilder().Build(apiServerConfigMapName(obj)))
+	kubeHandler.Append(manifests.NewServiceAccountBuilder().Build(tokenProbeServiceAccountName(obj)))
+	_, err = kubeHandler.DeleteAll(ctx)
 	if err != nil {
 		return err
 	}
-	for _, r := range webhookSetup.Resources() {
-		kubeHandler.Append(r)
+	if err := r.deleteNodeAgent(ctx, obj, kubeClient); err != nil {
+		return err
 	}
-	_, err = kubeHandler.DeleteAll(ctx)
+	// a webhook that has never been deployed is left untouched, since the cluster may run its own one
+	if namespace := deployedWebhookNamespace(obj); namespace != "" || obj.Spec.Webhook.IsEnabled() {
+		if namespace == "" {
+			namespace = obj.Spec.Webhook.GetNamespace()
+		}
+		certManager := obj.Spec.Webhook.GetCertificate().GetSource() == irsav1alpha1.WebhookCertificateCertManager ||
+			(obj.Status.Webhook != nil && obj.Status.Webhook.CertificateSource == irsav1alpha1.WebhookCertificateCertManager)
+		if err := deleteWebhook(ctx, kubeClient, namespace, certManager); err != nil {
+			return err
+		}
+	}
+	if awsClient == nil {
+		// the credentials Secret has been deleted before the IRSASetup
+		return nil
+	}
+	if err := r.deleteRoleAccountOIDCProviders(ctx, obj, kubeClient); err != nil {
+		return err
+	}
+	if obj.Spec.Discovery.S3.CloudFront != nil && obj.Status.CloudFront == nil {
+		// the discovery resources are only created once the distribution has been set up
+		return nil
+	}
+	factory, err := newOIDCIdpFactory(ctx, obj, nil, awsClient, kubeClient)
 	if err != nil {
 		return err
 	}
@@ -162,50 +266,64 @@ func (r *IRSASetupReconciler) reconcileDeleteSelfhosted(ctx context.Context, obj
 	if err != nil {
 		return err
 	}
-	return selfhosted.Delete(
+	err = selfhosted.Delete(
 		ctx,
 		factory,
 		issuerMeta,
 	)
+	if err != nil {
+		return err
+	}
+	if err := deleteReplicas(ctx, obj, issuerMeta, awsClient, kubeClient); err != nil {
+		return err
+	}
+	return deleteCloudFront(ctx, obj, awsClient)
 }
 
 // reconcileSelfhosted ensures that the self-hosted resources are set up correctly.
 // This function performs the following operations based on the state of the object:
-// - If the self-hosted setup has previously succeeded, the function returns immediately without making changes.
+// - If the self-hosted setup has previously succeeded, the function only reconciles the signing keys, the IAM OIDC provider, the webhook and the replicas.
 // - If the self-hosted setup was previously attempted but failed, or if it's being run for the first time, it will attempt to create all necessary resources. This includes the creation of key pairs, JWKs, OIDC IDP configurations, and Kubernetes secrets.
 // - The function enforces a 'force update' strategy in case of failures related to kubernetes Secrets creation or OIDC setup. This means it starts from scratch to ensure all components are correctly configured.
-func reconcileSelfhosted(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient) error {
+func reconcileSelfhosted(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient, apiServer *selfhosted.APIServerDiscovery) (ctrl.Result, error) {
 	log := ctrllog.FromContext(ctx)
-	if irsav1alpha1.IsReadyConditionTrue(*obj) {
+	if irsav1alpha1.IsReadyConditionTrue(*obj) || hasDrifted(*obj) {
 		// Selfhosted Setup have already succeeded
 		log.Info("the self-hosted resources have already set up")
-		return nil
+		var requeueAfter time.Duration
+		if obj.Spec.SigningKey.IsExternal() {
+			if err := reconcileExternalSigningKey(ctx, obj, awsClient, kubeClient, apiServer, time.Now()); err != nil {
+				return ctrl.Result{}, err
+			}
+			requeueAfter = externalSigningKeyResyncPeriod(obj.Spec.SigningKey)
+		} else if obj.Spec.KeyRotation != nil {
+			var err error
+			requeueAfter, err = reconcileKeyRotation(ctx, obj, awsClient, kubeClient, time.Now())
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if obj.Spec.DriftDetection != nil {
+			if err := reconcileDrift(ctx, obj, awsClient, kubeClient, apiServer); err != nil {
+				return ctrl.Result{}, err
+			}
+		} else {
+			if err := reconcileOIDCProvider(ctx, obj, awsClient, kubeClient); err != nil {
+				return ctrl.Result{}, err
+			}
+			if _, err := reconcileWebhook(ctx, obj, kubeClient, time.Now()); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if err := reconcileSigningKeySync(ctx, obj, awsClient, kubeClient, apiServer); err != nil {
+			return ctrl.Result{}, err
+		}
+		replicaRequeueAfter := reconcileReplicas(ctx, obj, awsClient, kubeClient, apiServer, time.Now())
+		webhookRequeueAfter := webhookCertificateRequeueAfter(obj, time.Now())
+		thumbprintRequeueAfter := issuerThumbprintRequeueAfter(obj, time.Now())
+		return ctrl.Result{RequeueAfter: shortestRequeue(requeueAfter, obj.Spec.DriftDetection.RequeueAfter(), replicaRequeueAfter, webhookRequeueAfter, thumbprintRequeueAfter)}, nil
 	}
 	log.Info("the self-hosted resources are setting up")
-	keyPair, err := selfhosted.CreateKeyPair()
-	if err != nil {
-		return err
-	}
-	jwk, err := selfhosted.NewJWK(keyPair.PublicKey())
-	if err != nil {
-		return err
-	}
-	factory, err := newOIDCIdpFactory(ctx, obj, jwk, awsClient)
-	if err != nil {
-		return err
-	}
-	secret, err := manifests.NewSecretBuilder().WithSSHKey(*keyPair).Build(manifests.SshKeyNamespacedName())
-	if err != nil {
-		return err
-	}
-	kubeHandlerForOidc := handler.NewKubernetesHandler(kubeClient)
-	kubeHandlerForOidc.Append(secret)
-
-	// for webhook setup
-	webhookSetup, err := webhook.NewWebHookSetup()
-	if err != nil {
-		return err
-	}
 
 	// e is set only when an error occurs in an external dependency process and is reflected in the CRs status
 	var e error
@@ -216,6 +334,45 @@ func reconcileSelfhosted(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsCl
 		}
 	}()
 
+	var pubs []byte
+	var jwk *selfhosted.JWK
+	var keys *selfhosted.SigningKeys
+	var keysCreatedAt metav1.Time
+	var err error
+	if obj.Spec.SigningKey.IsExternal() {
+		// the signing key is managed outside of irsa-manager, so only its public keys are published
+		pubs, jwk, err = loadExternalKeys(ctx, obj, kubeClient, apiServer)
+		if err != nil {
+			e = err
+			reason = irsav1alpha1.SelfHostedReasonFailedSigningKey
+			return ctrl.Result{}, err
+		}
+	} else {
+		// the key Secret is the single source of truth, so the JWKS is always derived from it
+		keys, keysCreatedAt, err = ensureSigningKeys(ctx, obj, kubeClient)
+		if err != nil {
+			e = err
+			reason = irsav1alpha1.SelfHostedReasonFailedKeys
+			return ctrl.Result{}, err
+		}
+		pubs = keys.PublicKeys()
+		jwk, err = selfhosted.NewJWK(pubs)
+		if err != nil {
+			e = err
+			reason = irsav1alpha1.SelfHostedReasonFailedSigningKey
+			return ctrl.Result{}, err
+		}
+	}
+	if err := reconcileCloudFront(ctx, obj, awsClient); err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonFailedCloudFront
+		return ctrl.Result{}, err
+	}
+	factory, err := newOIDCIdpFactory(ctx, obj, jwk, awsClient, kubeClient)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	forceUpdate := irsav1alpha1.HasConditionReason(
 		irsav1alpha1.ReadyStatus(*obj),
 		string(irsav1alpha1.SelfHostedReasonFailedKeys),
@@ -225,7 +382,7 @@ func reconcileSelfhosted(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsCl
 	if err != nil {
 		e = err
 		reason = irsav1alpha1.SelfHostedReasonFailedIssuer
-		return err
+		return ctrl.Result{}, err
 	}
 	err = selfhosted.Execute(
 		ctx,
@@ -236,32 +393,42 @@ func reconcileSelfhosted(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsCl
 	if err != nil {
 		e = err
 		reason = irsav1alpha1.SelfHostedReasonFailedOidc
-		return err
-	}
-	if forceUpdate {
-		_, err = kubeHandlerForOidc.ApplyAll(ctx)
-	} else {
-		err = kubeHandlerForOidc.CreateAll(ctx)
+		return ctrl.Result{}, err
 	}
+	recordOIDCProviderClientIDs(obj)
+	// for webhook update, the serving certificate is kept until it is due for renewal
+	err = applyWebhook(ctx, obj, kubeClient, time.Now(), true)
 	if err != nil {
 		e = err
-		reason = irsav1alpha1.SelfHostedReasonFailedKeys
-		return err
+		reason = irsav1alpha1.SelfHostedReasonFailedWebhook
+		return ctrl.Result{}, err
 	}
-	// for webhook update
-	kubeHandlerForWebhook := handler.NewKubernetesHandler(kubeClient)
-	for _, r := range webhookSetup.Resources() {
-		kubeHandlerForWebhook.Append(r)
+	if obj.Spec.SigningKey.IsExternal() {
+		err = syncSigningKeyStatus(&obj.Status, pubs, "", obj.Spec.SigningKey.KeySource(), metav1.Now(), time.Now())
+	} else {
+		err = syncGeneratedSigningKeyStatus(&obj.Status, keys, keysCreatedAt, time.Now())
 	}
-	_, err = kubeHandlerForWebhook.ApplyAll(ctx)
 	if err != nil {
 		e = err
-		reason = irsav1alpha1.SelfHostedReasonFailedWebhook
-		return err
+		reason = irsav1alpha1.SelfHostedReasonFailedSigningKey
+		return ctrl.Result{}, err
+	}
+	if obj.Spec.KeyRotation != nil && !obj.Spec.SigningKey.IsExternal() {
+		obj.Status.LastRotationTrigger = obj.Spec.KeyRotation.Trigger
 	}
 	*obj = irsav1alpha1.SetupStatusReady(*obj, string(irsav1alpha1.SelfHostedReasonReady), "successfully setup resources for self-hosted")
 	log.Info("the self-hosted resources have successfully set up")
-	return nil
+	if err := reconcileSigningKeySync(ctx, obj, awsClient, kubeClient, apiServer); err != nil {
+		return ctrl.Result{}, err
+	}
+	requeueAfter := obj.Spec.KeyRotation.NextEvent(obj.Status, time.Now())
+	if obj.Spec.SigningKey.IsExternal() {
+		requeueAfter = externalSigningKeyResyncPeriod(obj.Spec.SigningKey)
+	}
+	replicaRequeueAfter := reconcileReplicas(ctx, obj, awsClient, kubeClient, apiServer, time.Now())
+	webhookRequeueAfter := webhookCertificateRequeueAfter(obj, time.Now())
+	thumbprintRequeueAfter := issuerThumbprintRequeueAfter(obj, time.Now())
+	return ctrl.Result{RequeueAfter: shortestRequeue(requeueAfter, obj.Spec.DriftDetection.RequeueAfter(), replicaRequeueAfter, webhookRequeueAfter, thumbprintRequeueAfter)}, nil
 }
 
 // reconcileEks iterates tasks for EKS mode.
@@ -287,17 +454,28 @@ func reconcileEks(ctx context.Context, obj *irsav1alpha1.IRSASetup) error {
 	return nil
 }
 
-func newOIDCIdpFactory(ctx context.Context, obj *irsav1alpha1.IRSASetup, jwk *selfhosted.JWK, awsClient awsclient.AwsClient) (selfhosted.OIDCIdPFactory, error) {
-	region := obj.Spec.Discovery.S3.Region
-	bucketName := obj.Spec.Discovery.S3.BucketName
-	jwksFileName := "keys.json"
+func newOIDCIdpFactory(ctx context.Context, obj *irsav1alpha1.IRSASetup, jwk *selfhosted.JWK, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient) (selfhosted.OIDCIdPFactory, error) {
+	idpOptions, err := newAwsIdPOptions(ctx, obj, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	if inCluster := obj.Spec.Discovery.InCluster; inCluster != nil {
+		return oidc.NewInClusterIdPFactory(*inCluster, jwk, jwksFileName, idpOptions, awsClient, kubeClient), nil
+	}
+	s3Options, err := newS3Options(ctx, obj.Spec.Discovery.S3, kubeClient)
+	if err != nil {
+		return nil, err
+	}
 	factory, err := oidc.NewAwsS3IdpFactory(
 		ctx,
-		region,
-		bucketName,
+		obj.Spec.Discovery.S3,
+		obj.Status.CloudFront,
+		string(obj.UID),
 		jwk,
 		jwksFileName,
+		idpOptions,
 		awsClient,
+		s3Options,
 	)
 	if err != nil {
 		return nil, err
@@ -305,9 +483,214 @@ func newOIDCIdpFactory(ctx context.Context, obj *irsav1alpha1.IRSASetup, jwk *se
 	return factory, nil
 }
 
+// reconcileOIDCProvider adds the missing client IDs to the IAM OIDC provider, removes the ones that are no longer wanted and updates its thumbprints,
+// so that the changes of the audiences and the thumbprints are applied once the setup has succeeded.
+// The drift detection does the same when it is configured.
+func reconcileOIDCProvider(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient) error {
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+	if err != nil {
+		return err
+	}
+	factory, err := newOIDCIdpFactory(ctx, obj, nil, awsClient, kubeClient)
+	if err != nil {
+		return err
+	}
+	idp, err := factory.IdP(issuerMeta)
+	if err != nil {
+		return err
+	}
+	if err := idp.Update(ctx); err != nil {
+		return err
+	}
+	recordOIDCProviderClientIDs(obj)
+	return nil
+}
+
+// issuerThumbprint computes the thumbprint of the certificate of an issuer that is not served by AWS.
+var issuerThumbprint = awsclient.Thumbprint
+
+// newAwsIdPOptions returns the client IDs and the thumbprints of the IAM OIDC provider.
+func newAwsIdPOptions(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) (oidc.AwsIdPOptions, error) {
+	inUse, err := boundIRSAAudiences(ctx, obj, kubeClient)
+	if err != nil {
+		return oidc.AwsIdPOptions{}, err
+	}
+	options := oidc.AwsIdPOptions{
+		Audiences:      obj.ClientIDs(),
+		InUseClientIDs: inUse,
+		Thumbprints:    obj.Spec.Thumbprints,
+		Thumbprint:     recordedIssuerThumbprint(obj),
+	}
+	if obj.Status.OIDCProvider != nil {
+		options.RegisteredClientIDs = obj.Status.OIDCProvider.ClientIDs
+	}
+	return options, nil
+}
+
+// boundIRSAAudiences returns the audiences of the IRSAs that may be bound to the IRSASetup,
+// so that a client ID is not removed from the IAM OIDC provider while the IAM roles of the IRSAs still trust it.
+func boundIRSAAudiences(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) ([]string, error) {
+	irsas, err := boundIRSAs(ctx, obj, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	audiences := []string{}
+	for _, irsa := range irsas {
+		if audience := irsa.TokenAudience(obj); !slices.Contains(audiences, audience) {
+			audiences = append(audiences, audience)
+		}
+	}
+	return audiences, nil
+}
+
+// boundIRSAs returns the IRSAs that may be bound to the IRSASetup.
+// The IRSAs without setupRef are counted as bound, as they are bound to the IRSASetup as long as it is the only one.
+func boundIRSAs(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) ([]irsav1alpha1.IRSA, error) {
+	list, err := kubeClient.List(ctx, irsav1alpha1.GroupVersion.WithKind(irsav1alpha1.IRSAKind))
+	if err != nil {
+		return nil, err
+	}
+	irsas := []irsav1alpha1.IRSA{}
+	for _, item := range list.Items {
+		irsa := irsav1alpha1.IRSA{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &irsa); err != nil {
+			return nil, fmt.Errorf("error converting to IRSA for %s: %v", item.GetName(), err)
+		}
+		if ref := irsa.Spec.SetupRef; ref != nil {
+			namespace := ref.Namespace
+			if namespace == "" {
+				namespace = irsa.Namespace
+			}
+			if ref.Name != obj.Name || namespace != obj.Namespace || !obj.AllowsNamespace(irsa.Namespace) {
+				continue
+			}
+		}
+		irsas = append(irsas, irsa)
+	}
+	return irsas, nil
+}
+
+// issuerThumbprintResyncPeriod is the period after which the thumbprint of the issuer is computed again,
+// so that a renewed certificate chain, e.g. a rotated intermediate CA, is registered in the IAM OIDC provider.
+const issuerThumbprintResyncPeriod = time.Hour
+
+// recordedIssuerThumbprint returns a function that computes the thumbprint of the issuer only when none has been recorded for the issuer URL
+// within the resync period, and records the computed one in the status, so that the issuer is not connected to on every reconciliation.
+// The IssuerCertificateVerified condition reports whether the certificate chain of the issuer has been verified.
+// A thumbprint trusted on first use is refused when it differs from the recorded one, as the chain may have been replaced by a man-in-the-middle.
+func recordedIssuerThumbprint(obj *irsav1alpha1.IRSASetup) func(ctx context.Context, issuerUrl string) (string, error) {
+	return func(ctx context.Context, issuerUrl string) (string, error) {
+		now := time.Now()
+		status := obj.Status.OIDCProvider
+		recorded := status != nil && status.IssuerURL == issuerUrl && status.Thumbprint != ""
+		if recorded && status.ThumbprintCheckedAt != nil && now.Before(status.ThumbprintCheckedAt.Add(issuerThumbprintResyncPeriod)) {
+			return status.Thumbprint, nil
+		}
+		options := awsclient.ThumbprintOptions{}
+		if c := obj.Spec.IssuerCertificate; c != nil {
+			options.CABundle = []byte(c.CABundle)
+			options.TrustOnFirstUse = c.TrustOnFirstUse
+		}
+		thumbprint, verified, err := issuerThumbprint(ctx, issuerUrl, options)
+		if err == nil && !verified && recorded && !strings.EqualFold(status.Thumbprint, thumbprint) {
+			err = fmt.Errorf("the thumbprint %s of the unverified certificate chain of the issuer differs from the one trusted on first use %s, "+
+				"set issuerCertificate.caBundle or thumbprints to trust the new certificate chain", thumbprint, status.Thumbprint)
+		}
+		switch {
+		case err != nil:
+			*obj = irsav1alpha1.SetupStatusIssuerCertificateVerified(*obj, metav1.ConditionFalse, string(irsav1alpha1.SelfHostedReasonIssuerCertificateUnverified), err.Error())
+			return "", err
+		case verified:
+			*obj = irsav1alpha1.SetupStatusIssuerCertificateVerified(*obj, metav1.ConditionTrue, string(irsav1alpha1.SelfHostedReasonIssuerCertificateVerified),
+				"the certificate chain of the issuer has been verified")
+		default:
+			*obj = irsav1alpha1.SetupStatusIssuerCertificateVerified(*obj, metav1.ConditionFalse, string(irsav1alpha1.SelfHostedReasonIssuerCertificateTrustedOnFirstUse),
+				"the certificate chain of the issuer could not be verified and its thumbprint is trusted on first use")
+		}
+		if recorded && status.Thumbprint != thumbprint {
+			ctrllog.FromContext(ctx).Info("the thumbprint of the issuer has changed", "issuer", issuerUrl, "previous", status.Thumbprint, "thumbprint", thumbprint)
+		}
+		if obj.Status.OIDCProvider == nil {
+			obj.Status.OIDCProvider = &irsav1alpha1.OIDCProviderStatus{}
+		}
+		checkedAt := metav1.NewTime(now)
+		obj.Status.OIDCProvider.IssuerURL = issuerUrl
+		obj.Status.OIDCProvider.Thumbprint = thumbprint
+		obj.Status.OIDCProvider.ThumbprintCheckedAt = &checkedAt
+		return thumbprint, nil
+	}
+}
+
+// issuerThumbprintRequeueAfter returns the period until the thumbprint of the issuer has to be computed again,
+// or zero when no thumbprint has been computed.
+func issuerThumbprintRequeueAfter(obj *irsav1alpha1.IRSASetup, now time.Time) time.Duration {
+	status := obj.Status.OIDCProvider
+	if status == nil || status.Thumbprint == "" || status.ThumbprintCheckedAt == nil {
+		return 0
+	}
+	if d := status.ThumbprintCheckedAt.Add(issuerThumbprintResyncPeriod).Sub(now); d > 0 {
+		return d
+	}
+	return time.Second
+}
+
+// recordOIDCProviderClientIDs records the client IDs registered in the IAM OIDC provider,
+// so that they are removed from the provider once they are no longer wanted.
+func recordOIDCProviderClientIDs(obj *irsav1alpha1.IRSASetup) {
+	if obj.Status.OIDCProvider == nil {
+		obj.Status.OIDCProvider = &irsav1alpha1.OIDCProviderStatus{}
+	}
+	obj.Status.OIDCProvider.ClientIDs = obj.ClientIDs()
+}
+
+// newS3Options returns the options of the S3 client for an S3-compatible storage.
+// The access key is read from the referenced Secret if any.
+func newS3Options(ctx context.Context, s3 irsav1alpha1.S3Discovery, kubeClient *kubernetes.KubernetesClient) (func(*awss3.Options), error) {
+	var accessKeyID, secretAccessKey string
+	if ref := s3.CredentialsSecretRef; ref != nil {
+		secret, err := getSecret(ctx, kubeClient, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the S3 credentials Secret %s/%s, %w", ref.Namespace, ref.Name, err)
+		}
+		accessKeyID = string(secret.Data[irsav1alpha1.S3AccessKeyIDKey])
+		secretAccessKey = string(secret.Data[irsav1alpha1.S3SecretAccessKeyKey])
+		if accessKeyID == "" || secretAccessKey == "" {
+			return nil, fmt.Errorf("the S3 credentials Secret %s/%s must have the %q and %q keys", ref.Namespace, ref.Name, irsav1alpha1.S3AccessKeyIDKey, irsav1alpha1.S3SecretAccessKeyKey)
+		}
+	}
+	return awsclient.S3CompatibleOptions(s3.Endpoint, s3.UsePathStyle, accessKeyID, secretAccessKey), nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *IRSASetupReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&irsav1alpha1.IRSASetup{}).
+		Watches(&irsav1alpha1.IRSA{}, ctrlhandler.EnqueueRequestsFromMapFunc(r.roleAccountIRSASetupRequests)).
 		Complete(r)
 }
+
+// roleAccountIRSASetupRequests returns the IRSASetups an IRSA with a deployer role may be bound to,
+// so that they record the IAM OIDC provider in the account of the deployer role and delete it once no IRSA uses it.
+func (r *IRSASetupReconciler) roleAccountIRSASetupRequests(ctx context.Context, o client.Object) []reconcile.Request {
+	irsa, ok := o.(*irsav1alpha1.IRSA)
+	if !ok || irsa.Spec.IamRole.DeployerRoleArn == "" {
+		return nil
+	}
+	if ref := irsa.Spec.SetupRef; ref != nil {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = irsa.Namespace
+		}
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: ref.Name, Namespace: namespace}}}
+	}
+	list := &irsav1alpha1.IRSASetupList{}
+	if err := r.List(ctx, list); err != nil {
+		ctrllog.FromContext(ctx).Error(err, "unable to list the IRSASetups")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, setup := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&setup)})
+	}
+	return requests
+}
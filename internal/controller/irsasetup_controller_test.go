@@ -17,21 +17,55 @@ limitations under the License.
 package controller
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	regv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+	"github.com/kkb0318/irsa-manager/internal/manifests"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted/nodeagent"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted/webhook"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
@@ -125,78 +159,1486 @@ var _ = Describe("IRSASetup Controller", func() {
 					}
 				},
 			},
+			{
+				name: "private bucket with a bucket policy",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-bucket-policy",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+								Access:     irsav1alpha1.S3AccessBucketPolicy,
+								Tags:       map[string]string{"owner": "platform"},
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					s3API := &mockAwsS3API{}
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{}, s3API, &mockAwsStsAPI{})
+					By("uploading the discovery documents without ACLs")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(s3API.objects).To(HaveKey(".well-known/openid-configuration"))
+					Expect(s3API.objects).To(HaveKey("keys.json"))
+					for key, acl := range s3API.objectACLs {
+						Expect(acl).To(BeEmpty(), key)
+					}
+					By("granting public read access to the discovery documents only")
+					Expect(s3API.bucketPolicy).To(ContainSubstring(`"arn:aws:s3:::irsa-manager-1/.well-known/openid-configuration"`))
+					Expect(s3API.bucketPolicy).To(ContainSubstring(`"arn:aws:s3:::irsa-manager-1/keys.json"`))
+					Expect(s3API.bucketTags).To(HaveLen(1))
+					Expect(*s3API.bucketTags[0].Key).To(Equal("owner"))
+					By("publishing the kube-apiserver configuration")
+					updated := &irsav1alpha1.IRSASetup{}
+					Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+					Expect(updated.Status.APIServer).NotTo(BeNil())
+					Expect(updated.Status.APIServer.Flags).To(ContainElement("--service-account-issuer=https://s3-ap-northeast-1.amazonaws.com/irsa-manager-1"))
+					Expect(updated.Status.APIServer.ConfigMapName).To(Equal("test-resource-bucket-policy-apiserver-config"))
+					configMap := &corev1.ConfigMap{}
+					Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "test-resource-bucket-policy-apiserver-config", Namespace: "default"}, configMap)).To(Succeed())
+					Expect(configMap.Data).To(HaveKey("kubeadm.yaml"))
+					Expect(configMap.Data["k3s.yaml"]).To(ContainSubstring("service-account-issuer=https://s3-ap-northeast-1.amazonaws.com/irsa-manager-1"))
+					By("verifying the tokens issued by the kube-apiserver")
+					// the kube-apiserver of envtest is not configured for the issuer
+					cond := apimeta.FindStatusCondition(updated.Status.Conditions, irsav1alpha1.TokenIssuanceVerifiedCondition)
+					Expect(cond).NotTo(BeNil())
+					Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+					Expect(cond.Reason).To(Equal(string(irsav1alpha1.SelfHostedReasonTokenIssuerMismatch)))
+					checkExist(expectedResource{
+						NamespacedName: types.NamespacedName{Name: "test-resource-bucket-policy-token-probe", Namespace: "default"},
+						f:              newServiceAccount,
+					})
+
+					By("removing every version of the objects together with the bucket")
+					s3API.putObject("keys.json", s3API.objects["keys.json"])
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+					Expect(s3API.objects).To(BeEmpty())
+					Expect(s3API.noncurrentVersions).To(BeEmpty())
+					Expect(s3API.bucketDeleted).To(BeTrue())
+				},
+			},
+			{
+				name: "node agent on the control plane nodes",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-node-agent",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+							},
+						},
+						NodeAgent: &irsav1alpha1.NodeAgent{},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					expected := []expectedResource{
+						{
+							NamespacedName: types.NamespacedName{Name: "irsa-manager-node-agent", Namespace: "kube-system"},
+							f:              newDaemonSet,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "irsa-manager-node-agent", Namespace: "kube-system"},
+							f:              newServiceAccount,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "irsa-manager-node-agent", Namespace: "kube-system"},
+							f:              newRole,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "irsa-manager-node-agent", Namespace: "kube-system"},
+							f:              newRoleBinding,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "irsa-manager-node-agent-status", Namespace: "kube-system"},
+							f:              newConfigMap,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "irsa-manager-node-agent", Namespace: "kube-system"},
+							f:              newLease,
+						},
+					}
+					By("failing without the image of the node agent")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(HaveOccurred())
+
+					By("deploying the node agents")
+					r.NodeAgentImage = "ghcr.io/kkb0318/irsa-manager:test"
+					result, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.RequeueAfter).To(Equal(nodeAgentRequeueAfter))
+					for _, expect := range expected {
+						checkExist(expect)
+					}
+					ds := &appsv1.DaemonSet{}
+					Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "irsa-manager-node-agent", Namespace: "kube-system"}, ds)).To(Succeed())
+					container := ds.Spec.Template.Spec.Containers[0]
+					Expect(container.Image).To(Equal("ghcr.io/kkb0318/irsa-manager:test"))
+					Expect(container.Args).To(ContainElement("--apiserver-flag=--service-account-issuer=https://s3-ap-northeast-1.amazonaws.com/irsa-manager-1"))
+					Expect(container.Args).To(ContainElement("--key-dir=/etc/kubernetes/pki"))
+					Expect(ds.Spec.Template.Spec.NodeSelector).To(HaveKey("node-role.kubernetes.io/control-plane"))
+					Expect(ds.Annotations).To(HaveKeyWithValue(nodeagent.OwnerAnnotation, "default/test-resource-node-agent"))
+
+					By("refusing to take over the node agents deployed by another IRSASetup")
+					ds.Annotations[nodeagent.OwnerAnnotation] = "default/other"
+					Expect(k8sClient.Update(ctx, ds)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "irsa-manager-node-agent", Namespace: "kube-system"}, ds)).To(Succeed())
+					ds.Annotations[nodeagent.OwnerAnnotation] = "default/test-resource-node-agent"
+					Expect(k8sClient.Update(ctx, ds)).To(Succeed())
+
+					By("reporting the progress of the node agents")
+					pod := &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "irsa-manager-node-agent-test",
+							Namespace: "kube-system",
+							Labels:    map[string]string{"app": "irsa-manager-node-agent"},
+						},
+						Spec: corev1.PodSpec{
+							NodeName:   "control-plane-1",
+							Containers: []corev1.Container{{Name: "node-agent", Image: "ghcr.io/kkb0318/irsa-manager:test"}},
+						},
+					}
+					Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+					reports := &corev1.ConfigMap{}
+					Expect(k8sClient.Get(ctx, nodeagent.StatusConfigMapName, reports)).To(Succeed())
+					reports.Data = map[string]string{
+						"control-plane-1": `{"configured":true,"lastUpdateTime":"2024-08-01T00:00:00Z"}`,
+						"removed-node":    `{"configured":false,"lastUpdateTime":"2024-08-01T00:00:00Z"}`,
+					}
+					Expect(k8sClient.Update(ctx, reports)).To(Succeed())
+					Eventually(func() []irsav1alpha1.NodeAgentStatus {
+						_, err := r.Reconcile(ctx, reconcile.Request{
+							NamespacedName: typeNamespacedName,
+						})
+						Expect(err).NotTo(HaveOccurred())
+						updated := &irsav1alpha1.IRSASetup{}
+						Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+						return updated.Status.NodeAgents
+					}, timeout).Should(HaveLen(1))
+					updated := &irsav1alpha1.IRSASetup{}
+					Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+					Expect(updated.Status.NodeAgents[0].NodeName).To(Equal("control-plane-1"))
+					Expect(updated.Status.NodeAgents[0].Configured).To(BeTrue())
+
+					By("removing the node agents when NodeAgent is unset")
+					updated.Spec.NodeAgent = nil
+					Expect(k8sClient.Update(ctx, updated)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					for _, expect := range expected {
+						checkNoExist(expect)
+					}
+					Expect(k8sClient.Delete(ctx, pod)).To(Succeed())
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+				},
+			},
+			{
+				name: "bucket shared under a prefix",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-prefix",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+								Prefix:     "cluster-a",
+								Access:     irsav1alpha1.S3AccessBucketPolicy,
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					s3API := &mockAwsS3API{objects: map[string][]byte{"cluster-b/keys.json": []byte(`{"keys":[]}`)}}
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{}, s3API, &mockAwsStsAPI{})
+					By("publishing under the prefix")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(s3API.objects["cluster-a/.well-known/openid-configuration"]).To(ContainSubstring(`"issuer": "https://s3-ap-northeast-1.amazonaws.com/irsa-manager-1/cluster-a/"`))
+					Expect(s3API.objects).To(HaveKey("cluster-a/keys.json"))
+					Expect(s3API.bucketPolicy).To(ContainSubstring(`"arn:aws:s3:::irsa-manager-1/*/.well-known/openid-configuration"`))
+					Expect(s3API.bucketPolicy).To(ContainSubstring(`"arn:aws:s3:::irsa-manager-1/*/keys.json"`))
+
+					By("keeping the bucket while other prefixes are left in it")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+					Expect(s3API.objects).NotTo(HaveKey("cluster-a/keys.json"))
+					Expect(s3API.objects).To(HaveKey("cluster-b/keys.json"))
+					Expect(s3API.bucketDeleted).To(BeFalse())
+				},
+			},
+			{
+				name: "signing key activated after the propagation delay",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-key-rotation",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+							},
+						},
+						KeyRotation: &irsav1alpha1.KeyRotation{
+							OverlapWindow:    metav1.Duration{Duration: 2 * time.Hour},
+							PropagationDelay: metav1.Duration{Duration: time.Hour},
+							Trigger:          "1",
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					s3API := &mockAwsS3API{}
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{}, s3API, &mockAwsStsAPI{})
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.SigningKeys).To(HaveLen(1))
+					first := obj.Status.SigningKeys[0]
+					secret := &corev1.Secret{}
+					Expect(k8sClient.Get(ctx, manifests.SshKeyNamespacedName(), secret)).To(Succeed())
+					firstKey := secret.Data[corev1.SSHAuthPrivateKey]
+					kubeClient, err := kubernetes.NewKubernetesClient(k8sClient, kubernetes.Owner{Field: "irsa-manager"})
+					Expect(err).NotTo(HaveOccurred())
+
+					By("publishing the new key without signing with it")
+					now := time.Now()
+					obj.Spec.KeyRotation.Trigger = "2"
+					requeueAfter, err := reconcileKeyRotation(ctx, obj, r.AwsClient, kubeClient, now)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(requeueAfter).To(Equal(time.Hour))
+					Expect(obj.Status.PendingSigningKey()).NotTo(BeNil())
+					pending := *obj.Status.PendingSigningKey()
+					Expect(obj.Status.ActiveSigningKey().KeyID).To(Equal(first.KeyID))
+					Expect(k8sClient.Get(ctx, manifests.SshKeyNamespacedName(), secret)).To(Succeed())
+					Expect(secret.Data[corev1.SSHAuthPrivateKey]).To(Equal(firstKey))
+					pendingKey := secret.Data[manifests.PendingSigningKeyKey]
+					Expect(pendingKey).NotTo(BeEmpty())
+					Expect(string(s3API.objects["keys.json"])).To(ContainSubstring(pending.KeyID))
+					Expect(string(s3API.objects["keys.json"])).To(ContainSubstring(first.KeyID))
+
+					By("keeping the pending key during the propagation delay")
+					requeueAfter, err = reconcileKeyRotation(ctx, obj, r.AwsClient, kubeClient, now.Add(30*time.Minute))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(requeueAfter).To(Equal(30 * time.Minute))
+					Expect(obj.Status.PendingSigningKey()).NotTo(BeNil())
+					Expect(k8sClient.Get(ctx, manifests.SshKeyNamespacedName(), secret)).To(Succeed())
+					Expect(secret.Data[corev1.SSHAuthPrivateKey]).To(Equal(firstKey))
+
+					By("signing with the new key once the propagation delay has passed")
+					requeueAfter, err = reconcileKeyRotation(ctx, obj, r.AwsClient, kubeClient, now.Add(time.Hour))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(requeueAfter).To(Equal(2 * time.Hour))
+					Expect(obj.Status.PendingSigningKey()).To(BeNil())
+					Expect(obj.Status.ActiveSigningKey().KeyID).To(Equal(pending.KeyID))
+					Expect(obj.Status.SigningKeys).To(ContainElement(HaveField("State", irsav1alpha1.SigningKeyRetiring)))
+					Expect(k8sClient.Get(ctx, manifests.SshKeyNamespacedName(), secret)).To(Succeed())
+					Expect(secret.Data[corev1.SSHAuthPrivateKey]).To(Equal(pendingKey))
+					Expect(secret.Data).NotTo(HaveKey(manifests.PendingSigningKeyKey))
+
+					By("retiring the replaced key once the overlap window has passed")
+					_, err = reconcileKeyRotation(ctx, obj, r.AwsClient, kubeClient, now.Add(3*time.Hour))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(obj.Status.SigningKeys).To(HaveLen(1))
+					Expect(string(s3API.objects["keys.json"])).NotTo(ContainSubstring(first.KeyID))
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+				},
+			},
+			{
+				name: "discovery documents replicated to another region",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-replicas",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Issuer:  &irsav1alpha1.Issuer{URL: "https://oidc.example.com"},
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+								Replicas: []irsav1alpha1.S3Replica{
+									{Region: "ap-northeast-3", BucketName: "irsa-manager-1-replica"},
+								},
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					s3API := &mockAwsS3API{}
+					replicaAPI := &mockAwsS3API{}
+					r.AwsClient = &mockAwsClient{
+						iam:        &mockAwsIamAPI{},
+						s3:         s3API,
+						sts:        &mockAwsStsAPI{},
+						cloudFront: &mockAwsCloudFrontAPI{},
+						buckets:    map[string]*mockAwsS3API{"irsa-manager-1-replica": replicaAPI},
+					}
+					By("publishing the same documents in the replica bucket")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(replicaAPI.objects[".well-known/openid-configuration"]).To(Equal(s3API.objects[".well-known/openid-configuration"]))
+					Expect(replicaAPI.objects["keys.json"]).To(Equal(s3API.objects["keys.json"]))
+					updated := &irsav1alpha1.IRSASetup{}
+					Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+					Expect(updated.Status.Replicas).To(HaveLen(1))
+					Expect(updated.Status.Replicas[0].Synced).To(BeTrue())
+					Expect(updated.Status.Replicas[0].LastSyncedTime).NotTo(BeNil())
+
+					By("deleting the replica bucket")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+					Expect(replicaAPI.objects).To(BeEmpty())
+					Expect(replicaAPI.bucketDeleted).To(BeTrue())
+				},
+			},
+			{
+				name: "IAM OIDC providers in the accounts of the deployer roles",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-role-accounts",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{}, &mockAwsS3API{}, &mockAwsStsAPI{})
+					roleAccountAPI := &mockAwsIamAPI{}
+					created := []awsclient.Credentials{}
+					r.AwsClientCache = awsclient.NewAwsClientCache(func(_ context.Context, c awsclient.Credentials) (awsclient.AwsClient, error) {
+						created = append(created, c)
+						return newMockAwsClient(roleAccountAPI, nil, &mockAwsStsAPI{account: "333333333333"}), nil
+					})
+					irsa := &irsav1alpha1.IRSA{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test-resource-role-accounts-irsa",
+							Namespace: "default",
+						},
+						Spec: irsav1alpha1.IRSASpec{
+							SetupRef: &irsav1alpha1.IRSASetupReference{Name: typeNamespacedName.Name},
+							IamRole: irsav1alpha1.IamRole{
+								Name:            "cross-account-role",
+								DeployerRoleArn: "arn:aws:iam::333333333333:role/irsa-deployer",
+								ExternalID:      "external-id",
+							},
+							ServiceAccount: irsav1alpha1.IRSAServiceAccount{Name: "sa-cross-account", Namespaces: []string{"default"}},
+						},
+					}
+					Expect(k8sClient.Create(ctx, irsa)).To(Succeed())
+
+					By("recording the provider in the account of the deployer role of the IRSA")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					updated := &irsav1alpha1.IRSASetup{}
+					Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+					Expect(updated.Status.RoleAccountOIDCProviders).To(Equal([]irsav1alpha1.RoleAccountOIDCProviderStatus{{
+						AccountID:       "333333333333",
+						IssuerHostPath:  "s3-ap-northeast-1.amazonaws.com/irsa-manager-1",
+						DeployerRoleArn: "arn:aws:iam::333333333333:role/irsa-deployer",
+						ExternalID:      "external-id",
+					}}))
+					Expect(roleAccountAPI.deletedOIDCProviderArns).To(BeEmpty())
+
+					By("deleting the provider with the deployer role once no IRSA uses the account")
+					Expect(k8sClient.Delete(ctx, irsa)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(created).To(Equal([]awsclient.Credentials{{
+						DeployerRoleArn:    "arn:aws:iam::333333333333:role/irsa-deployer",
+						DeployerExternalID: "external-id",
+					}}))
+					Expect(roleAccountAPI.deletedOIDCProviderArns).To(Equal([]string{
+						"arn:aws:iam::333333333333:oidc-provider/s3-ap-northeast-1.amazonaws.com/irsa-manager-1",
+					}))
+					Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+					Expect(updated.Status.RoleAccountOIDCProviders).To(BeEmpty())
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+				},
+			},
+			{
+				name: "JWKS shared with another cluster",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-shared-jwks",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+								SharedJWKS: true,
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					otherJWK := func() []byte {
+						keyPair, err := selfhosted.CreateKeyPair(irsav1alpha1.SigningKeyECDSAP256)
+						Expect(err).NotTo(HaveOccurred())
+						jwk, err := selfhosted.NewJWK(keyPair.PublicKey())
+						Expect(err).NotTo(HaveOccurred())
+						body, err := json.Marshal(jwk)
+						Expect(err).NotTo(HaveOccurred())
+						return body
+					}
+					s3API := &mockAwsS3API{}
+					s3API.putObject("jwks.d/blue.json", otherJWK())
+					signingAlgorithms := func() []string {
+						discovery := struct {
+							Algorithms []string `json:"id_token_signing_alg_values_supported"`
+						}{}
+						Expect(json.Unmarshal(s3API.objects[".well-known/openid-configuration"], &discovery)).To(Succeed())
+						return discovery.Algorithms
+					}
+					By("merging the keys of another cluster uploaded concurrently")
+					s3API.beforeConditionalPut = func(m *mockAwsS3API) {
+						m.putObject("jwks.d/green.json", otherJWK())
+						m.putObject("keys.json", []byte(`{"keys":[]}`))
+					}
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{deleteOidcErr: fmt.Errorf("the shared IAM OIDC provider must not be deleted")}, s3API, &mockAwsStsAPI{})
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					updated := &irsav1alpha1.IRSASetup{}
+					Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+					memberKey := fmt.Sprintf("jwks.d/%s.json", updated.UID)
+					Expect(s3API.objects).To(HaveKey(memberKey))
+					Expect(s3API.objectACLs[memberKey]).To(BeEmpty())
+					shared := &selfhosted.JWK{}
+					Expect(json.Unmarshal(s3API.objects["keys.json"], shared)).To(Succeed())
+					Expect(shared.KeyIDs()).To(HaveLen(3))
+					By("advertising the signing algorithms of the keys of all clusters in the discovery document")
+					Expect(signingAlgorithms()).To(ConsistOf("ES256", "RS256"))
+
+					By("removing only the keys of this cluster")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+					Expect(s3API.objects).NotTo(HaveKey(memberKey))
+					Expect(s3API.objects).To(HaveKey(".well-known/openid-configuration"))
+					shared = &selfhosted.JWK{}
+					Expect(json.Unmarshal(s3API.objects["keys.json"], shared)).To(Succeed())
+					Expect(shared.KeyIDs()).To(HaveLen(2))
+					Expect(signingAlgorithms()).To(ConsistOf("ES256"))
+					Expect(s3API.bucketDeleted).To(BeFalse())
+				},
+			},
+			{
+				name: "issuer served through CloudFront",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-cloudfront",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+								CloudFront: &irsav1alpha1.CloudFrontDiscovery{},
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					s3API := &mockAwsS3API{}
+					cloudFrontAPI := &mockAwsCloudFrontAPI{}
+					r.AwsClient = &mockAwsClient{iam: &mockAwsIamAPI{}, s3: s3API, sts: &mockAwsStsAPI{}, cloudFront: cloudFrontAPI}
+					By("creating the distribution and using its domain name as the issuer")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(irsav1alpha1.IsReadyConditionTrue(*obj)).To(BeTrue())
+					Expect(obj.Status.CloudFront).NotTo(BeNil())
+					Expect(obj.Status.CloudFront.DomainName).To(Equal("e1.cloudfront.net"))
+					Expect(obj.Status.CloudFront.IsManaged()).To(BeTrue())
+					Expect(cloudFrontAPI.distributions).To(HaveLen(1))
+					Expect(cloudFrontAPI.originAccessControls).To(HaveLen(1))
+					Expect(s3API.objects[".well-known/openid-configuration"]).To(ContainSubstring(`"issuer": "https://e1.cloudfront.net/"`))
+					for key, acl := range s3API.objectACLs {
+						Expect(acl).To(BeEmpty(), key)
+					}
+					Expect(s3API.bucketPolicy).To(ContainSubstring(`"AWS:SourceArn":"arn:aws:cloudfront::123456789012:distribution/E1"`))
+
+					By("reusing the distribution")
+					Expect(reconcileCloudFront(ctx, obj, r.AwsClient)).To(Succeed())
+					Expect(cloudFrontAPI.distributions).To(HaveLen(1))
+					Expect(cloudFrontAPI.originAccessControls).To(HaveLen(1))
+					Expect(*cloudFrontAPI.distributions["E1"].DistributionConfig.Comment).To(ContainSubstring(string(obj.UID)))
+
+					By("not adopting the distribution of another IRSASetup with the same name")
+					other := obj.DeepCopy()
+					other.UID = "another-uid"
+					other.Status.CloudFront = nil
+					Expect(reconcileCloudFront(ctx, other, r.AwsClient)).To(Succeed())
+					Expect(other.Status.CloudFront.DistributionID).NotTo(Equal(obj.Status.CloudFront.DistributionID))
+					Expect(other.Status.CloudFront.OriginAccessControlID).NotTo(Equal(obj.Status.CloudFront.OriginAccessControlID))
+					delete(cloudFrontAPI.distributions, other.Status.CloudFront.DistributionID)
+					cloudFrontAPI.originAccessControls = cloudFrontAPI.originAccessControls[:1]
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					result, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.RequeueAfter).To(Equal(cloudFrontDeletionRequeueAfter))
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(cloudFrontAPI.distributions).To(BeEmpty())
+					Expect(cloudFrontAPI.originAccessControls).To(BeEmpty())
+				},
+			},
+			{
+				name: "CloudFront distributions sharing a bucket under a prefix",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-cloudfront-prefix",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+								Prefix:     "cluster-a",
+								CloudFront: &irsav1alpha1.CloudFrontDiscovery{},
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					otherStatement := `{"Sid":"CloudFrontReadOIDCDiscoveryEOTHER","Effect":"Allow","Resource":"arn:aws:s3:::irsa-manager-1/cluster-b/keys.json"}`
+					s3API := &mockAwsS3API{
+						objects:      map[string][]byte{"cluster-b/keys.json": []byte(`{"keys":[]}`)},
+						bucketPolicy: `{"Version":"2012-10-17","Statement":[` + otherStatement + `]}`,
+					}
+					cloudFrontAPI := &mockAwsCloudFrontAPI{}
+					r.AwsClient = &mockAwsClient{iam: &mockAwsIamAPI{}, s3: s3API, sts: &mockAwsStsAPI{}, cloudFront: cloudFrontAPI}
+					By("adding a statement for the distribution of the cluster")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(s3API.bucketPolicy).To(ContainSubstring(`"Sid":"CloudFrontReadOIDCDiscoveryEOTHER"`))
+					Expect(s3API.bucketPolicy).To(ContainSubstring(`"Sid":"CloudFrontReadOIDCDiscoveryE1"`))
+					Expect(s3API.bucketPolicy).To(ContainSubstring(`"arn:aws:s3:::irsa-manager-1/cluster-a/keys.json"`))
+					Expect(s3API.bucketPolicy).NotTo(ContainSubstring(`"arn:aws:s3:::irsa-manager-1/*/keys.json"`))
+
+					By("removing only the statement of the cluster")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					Eventually(func() error {
+						_, err := r.Reconcile(ctx, reconcile.Request{
+							NamespacedName: typeNamespacedName,
+						})
+						if err != nil {
+							return err
+						}
+						return k8sClient.Get(ctx, typeNamespacedName, &irsav1alpha1.IRSASetup{})
+					}, timeout).Should(Satisfy(errors.IsNotFound))
+					Expect(s3API.bucketPolicy).To(ContainSubstring(`"Sid":"CloudFrontReadOIDCDiscoveryEOTHER"`))
+					Expect(s3API.bucketPolicy).NotTo(ContainSubstring(`"Sid":"CloudFrontReadOIDCDiscoveryE1"`))
+					Expect(s3API.bucketDeleted).To(BeFalse())
+				},
+			},
+			{
+				name: "custom issuer",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-custom-issuer",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+							},
+						},
+						Issuer:    &irsav1alpha1.Issuer{URL: "https://oidc.example.com/cluster-a"},
+						Audiences: []string{"vault.example.com"},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					s3API := &mockAwsS3API{}
+					iamAPI := &mockAwsIamAPI{oidcNotFound: true}
+					r.AwsClient = newMockAwsClient(iamAPI, s3API, &mockAwsStsAPI{})
+					By("publishing the custom issuer at the same storage location")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					discovery := s3API.objects[".well-known/openid-configuration"]
+					Expect(discovery).To(ContainSubstring(`"issuer": "https://oidc.example.com/cluster-a/"`))
+					Expect(discovery).To(ContainSubstring(`"jwks_uri": "https://oidc.example.com/cluster-a/keys.json"`))
+					Expect(s3API.objects).To(HaveKey("keys.json"))
+					By("creating the IAM OIDC provider with the audiences and the thumbprint of the issuer")
+					Expect(iamAPI.clientIDList).To(Equal([]string{"sts.amazonaws.com", "vault.example.com"}))
+					Expect(iamAPI.thumbprintList).To(Equal([]string{issuerThumbprintForTest}))
+
+					By("updating the thumbprint once the certificate chain of the issuer has been renewed")
+					renewedThumbprint := strings.Repeat("b", 40)
+					issuerThumbprint = func(ctx context.Context, issuerUrl string, options awsclient.ThumbprintOptions) (string, bool, error) {
+						return renewedThumbprint, true, nil
+					}
+					iamAPI.oidcNotFound = false
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(iamAPI.thumbprintList).To(Equal([]string{issuerThumbprintForTest}), "the recorded thumbprint is used within the resync period")
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.OIDCProvider.ThumbprintCheckedAt).NotTo(BeNil())
+					checkedAt := metav1.NewTime(time.Now().Add(-issuerThumbprintResyncPeriod))
+					obj.Status.OIDCProvider.ThumbprintCheckedAt = &checkedAt
+					Expect(k8sClient.Status().Update(ctx, obj)).To(Succeed())
+					result, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.RequeueAfter).To(BeNumerically("<=", issuerThumbprintResyncPeriod))
+					Expect(iamAPI.thumbprintList).To(Equal([]string{renewedThumbprint}))
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.OIDCProvider.Thumbprint).To(Equal(renewedThumbprint))
+					cond := apimeta.FindStatusCondition(obj.Status.Conditions, irsav1alpha1.IssuerCertificateVerifiedCondition)
+					Expect(cond).NotTo(BeNil())
+					Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+
+					By("refusing an unverified certificate chain that differs from the recorded one")
+					issuerThumbprint = func(ctx context.Context, issuerUrl string, options awsclient.ThumbprintOptions) (string, bool, error) {
+						return strings.Repeat("c", 40), false, nil
+					}
+					obj.Status.OIDCProvider.ThumbprintCheckedAt = &checkedAt
+					Expect(k8sClient.Status().Update(ctx, obj)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(iamAPI.thumbprintList).To(Equal([]string{renewedThumbprint}))
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					cond = apimeta.FindStatusCondition(obj.Status.Conditions, irsav1alpha1.IssuerCertificateVerifiedCondition)
+					Expect(cond).NotTo(BeNil())
+					Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+					Expect(cond.Reason).To(Equal(string(irsav1alpha1.SelfHostedReasonIssuerCertificateUnverified)))
+
+					By("reconciling the client IDs and the thumbprints of an existing IAM OIDC provider")
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					obj.Spec.Thumbprints = []string{strings.Repeat("a", 40)}
+					Expect(k8sClient.Update(ctx, obj)).To(Succeed())
+					iamAPI = &mockAwsIamAPI{thumbprintList: []string{strings.Repeat("x", 40)}}
+					r.AwsClient = newMockAwsClient(iamAPI, s3API, &mockAwsStsAPI{})
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(iamAPI.clientIDList).To(Equal([]string{"sts.amazonaws.com", "vault.example.com"}))
+					Expect(iamAPI.thumbprintList).To(Equal([]string{strings.Repeat("a", 40)}))
+
+					By("keeping the client IDs that are still used by the IRSAs bound to the IRSASetup")
+					irsa := &irsav1alpha1.IRSA{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test-resource-custom-issuer-vault",
+							Namespace: "default",
+						},
+						Spec: irsav1alpha1.IRSASpec{
+							SetupRef:       &irsav1alpha1.IRSASetupReference{Name: typeNamespacedName.Name},
+							Audience:       "vault.example.com",
+							ServiceAccount: irsav1alpha1.IRSAServiceAccount{Name: "vault", Namespaces: []string{"default"}},
+						},
+					}
+					Expect(k8sClient.Create(ctx, irsa)).To(Succeed())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					obj.Spec.Audiences = nil
+					Expect(k8sClient.Update(ctx, obj)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(iamAPI.clientIDList).To(Equal([]string{"sts.amazonaws.com", "vault.example.com"}))
+					Expect(k8sClient.Delete(ctx, irsa)).To(Succeed())
+
+					By("removing the client IDs registered by irsa-manager that are no longer listed")
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					obj.Spec.Audiences = []string{"vault.example.com"}
+					Expect(k8sClient.Update(ctx, obj)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.OIDCProvider).NotTo(BeNil())
+					Expect(obj.Status.OIDCProvider.ClientIDs).To(ContainElement("vault.example.com"))
+					obj.Spec.Audiences = nil
+					Expect(k8sClient.Update(ctx, obj)).To(Succeed())
+					iamAPI.clientIDList = append(iamAPI.clientIDList, "other.example.com")
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(iamAPI.clientIDList).To(Equal([]string{"sts.amazonaws.com", "other.example.com"}))
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+				},
+			},
+			{
+				name: "in-cluster discovery",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-in-cluster",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							InCluster: &irsav1alpha1.InClusterDiscovery{
+								Host:          "oidc.example.com",
+								TLSSecretName: "oidc-tls",
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					discoveryName := types.NamespacedName{Name: "irsa-manager-oidc-discovery", Namespace: "kube-system"}
+					s3API := &mockAwsS3API{}
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{}, s3API, &mockAwsStsAPI{})
+					By("serving the discovery documents from the cluster")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(s3API.objects).To(BeEmpty())
+					configMap := &corev1.ConfigMap{}
+					Expect(k8sClient.Get(ctx, discoveryName, configMap)).To(Succeed())
+					Expect(configMap.Data["openid-configuration"]).To(ContainSubstring(`"issuer": "https://oidc.example.com"`))
+					Expect(configMap.Data).To(HaveKey("keys.json"))
+					ingress := &networkingv1.Ingress{}
+					Expect(k8sClient.Get(ctx, discoveryName, ingress)).To(Succeed())
+					Expect(ingress.Spec.Rules[0].Host).To(Equal("oidc.example.com"))
+					Expect(ingress.Spec.TLS[0].SecretName).To(Equal("oidc-tls"))
+					deployment := &appsv1.Deployment{}
+					Expect(k8sClient.Get(ctx, discoveryName, deployment)).To(Succeed())
+					Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal(irsav1alpha1.DefaultInClusterImage))
+					Expect(k8sClient.Get(ctx, discoveryName, &policyv1.PodDisruptionBudget{})).To(Succeed())
+					Expect(k8sClient.Get(ctx, discoveryName, &corev1.Service{})).To(Succeed())
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+					err = k8sClient.Get(ctx, discoveryName, &corev1.ConfigMap{})
+					Expect(errors.IsNotFound(err)).To(BeTrue())
+					err = k8sClient.Get(ctx, discoveryName, &networkingv1.Ingress{})
+					Expect(errors.IsNotFound(err)).To(BeTrue())
+					err = k8sClient.Get(ctx, discoveryName, &policyv1.PodDisruptionBudget{})
+					Expect(errors.IsNotFound(err)).To(BeTrue())
+				},
+			},
 			{
 				name: "error case",
 				obj: &irsav1alpha1.IRSASetup{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-resource2",
+						Name:      "test-resource2",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						// Mode:    "selfhoted",
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					expected := []expectedResource{
+						{
+							NamespacedName: types.NamespacedName{Name: "irsa-manager-key", Namespace: "kube-system"},
+							f:              newSecret,
+						},
+						// webhook
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newDeployment,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newService,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newMutatingWebhookConfiguration,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newServiceAccount,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newClusterRole,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newClusterRoleBinding,
+						},
+					}
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					keySecret, webhookResources := expected[0], expected[1:]
+					By("only the key secret exists when reconciling with the AwsClient error")
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{oidcNotFound: true, createOidcErr: fmt.Errorf("createOidcErr")}, &mockAwsS3API{}, &mockAwsStsAPI{})
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(HaveOccurred())
+					checkExist(keySecret)
+					for _, expect := range webhookResources {
+						checkNoExist(expect)
+					}
+					secret := &corev1.Secret{}
+					Expect(k8sClient.Get(ctx, keySecret.NamespacedName, secret)).To(Succeed())
+					kid, err := selfhosted.KeyID(secret.Data["ssh-publickey"])
+					Expect(err).NotTo(HaveOccurred())
+					By("successfully Reconciling with the stored key")
+					s3API := &mockAwsS3API{}
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{}, s3API, &mockAwsStsAPI{})
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					for _, expect := range expected {
+						checkExist(expect)
+					}
+					Expect(s3API.objects["keys.json"]).To(ContainSubstring(kid))
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.ActiveSigningKey().KeyID).To(Equal(kid))
+					Expect(apimeta.IsStatusConditionTrue(obj.Status.Conditions, irsav1alpha1.SigningKeySyncedCondition)).To(BeTrue())
+
+					By("reporting the mismatch of the published JWKS")
+					s3API.objects["keys.json"] = []byte(`{"keys":[]}`)
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(apimeta.IsStatusConditionFalse(obj.Status.Conditions, irsav1alpha1.SigningKeySyncedCondition)).To(BeTrue())
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+					for _, expect := range expected {
+						checkNoExist(expect)
+					}
+				},
+			},
+			{
+				name: "no cleanup",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource2",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: false,
+						// Mode:    "selfhoted",
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					expected := []expectedResource{
+						{
+							NamespacedName: types.NamespacedName{Name: "irsa-manager-key", Namespace: "kube-system"},
+							f:              newSecret,
+						},
+						// webhook
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newDeployment,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newService,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newMutatingWebhookConfiguration,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newServiceAccount,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newClusterRole,
+						},
+						{
+							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+							f:              newClusterRoleBinding,
+						},
+					}
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					for _, expect := range expected {
+						checkExist(expect)
+					}
+					By("removing the custom resource (not cleanup)")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+					for _, expect := range expected {
+						checkExist(expect)
+					}
+				},
+			},
+			{
+				name: "signing key from an existing Secret",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-external-key",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+							},
+						},
+						SigningKey: irsav1alpha1.SigningKey{
+							Source: irsav1alpha1.SigningKeySourceSecret,
+							SecretRef: &irsav1alpha1.SigningKeySecretReference{
+								Name:      "apiserver-signing-key",
+								Namespace: "default",
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					signingKey := &corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "apiserver-signing-key",
+							Namespace: "default",
+						},
+						Data: map[string][]byte{
+							"sa.key": []byte("this is not a key"),
+						},
+					}
+					Expect(k8sClient.Create(ctx, signingKey)).To(Succeed())
+
+					By("reporting the condition when the signing key cannot be parsed")
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(irsav1alpha1.HasConditionReason(
+						irsav1alpha1.ReadyStatus(*obj),
+						string(irsav1alpha1.SelfHostedReasonFailedSigningKey),
+					)).To(BeTrue())
+
+					By("publishing the JWKS of the existing signing key")
+					keyPair, err := selfhosted.CreateKeyPair(irsav1alpha1.SigningKeyRSA2048)
+					Expect(err).NotTo(HaveOccurred())
+					signingKey.Data["sa.key"] = keyPair.PrivateKey()
+					Expect(k8sClient.Update(ctx, signingKey)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(irsav1alpha1.IsReadyConditionTrue(*obj)).To(BeTrue())
+					kid, err := selfhosted.KeyID(keyPair.PublicKey())
+					Expect(err).NotTo(HaveOccurred())
+					Expect(obj.Status.SigningKeys).To(HaveLen(1))
+					Expect(obj.Status.SigningKeys[0].KeyID).To(Equal(kid))
+					checkNoExist(expectedResource{
+						NamespacedName: types.NamespacedName{Name: "irsa-manager-key", Namespace: "kube-system"},
+						f:              newSecret,
+					})
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+					checkExist(expectedResource{
+						NamespacedName: types.NamespacedName{Name: "apiserver-signing-key", Namespace: "default"},
+						f:              newSecret,
+					})
+					Expect(k8sClient.Delete(ctx, signingKey)).To(Succeed())
+				},
+			},
+			{
+				name: "drift detection",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-drift",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+							},
+						},
+						DriftDetection: &irsav1alpha1.DriftDetection{},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					webhookDeployment := expectedResource{
+						NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
+						f:              newDeployment,
+					}
+					result, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					// the token issuance is verified again sooner, as the kube-apiserver of envtest issues tokens for its own issuer
+					Expect(result.RequeueAfter).To(Equal(tokenIssuanceRequeueAfter))
+					checkExist(webhookDeployment)
+
+					By("repairing the deleted webhook and IAM OIDC provider")
+					deployment := newDeployment()
+					Expect(k8sClient.Get(ctx, webhookDeployment.NamespacedName, deployment)).To(Succeed())
+					Expect(k8sClient.Delete(ctx, deployment)).To(Succeed())
+					checkNoExist(webhookDeployment)
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{oidcNotFound: true}, &mockAwsS3API{}, &mockAwsStsAPI{})
+					result, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.RequeueAfter).To(Equal(tokenIssuanceRequeueAfter))
+					checkExist(webhookDeployment)
+
+					By("reporting the drift that cannot be repaired")
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{}, &mockAwsS3API{createBucketErr: true}, &mockAwsStsAPI{})
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(irsav1alpha1.HasConditionReason(
+						irsav1alpha1.ReadyStatus(*obj),
+						string(irsav1alpha1.SelfHostedReasonDriftFailedDiscovery),
+					)).To(BeTrue())
+
+					By("recovering once the drift is repaired")
+					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{}, &mockAwsS3API{}, &mockAwsStsAPI{})
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(irsav1alpha1.IsReadyConditionTrue(*obj)).To(BeTrue())
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+				},
+			},
+			{
+				name: "webhook certificate renewal",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-webhook-cert",
+						Namespace: "default",
+					},
+					Spec: irsav1alpha1.IRSASetupSpec{
+						Cleanup: true,
+						Discovery: irsav1alpha1.Discovery{
+							S3: irsav1alpha1.S3Discovery{
+								Region:     "ap-northeast-1",
+								BucketName: "irsa-manager-1",
+							},
+						},
+					},
+				},
+				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
+					typeNamespacedName := types.NamespacedName{
+						Name:      obj.Name,
+						Namespace: obj.Namespace,
+					}
+					webhookName := webhook.SecretNamespacedName(irsav1alpha1.DefaultWebhookNamespace)
+					_, err := r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					secret := &corev1.Secret{}
+					Expect(k8sClient.Get(ctx, webhookName, secret)).To(Succeed())
+					certificate := secret.Data[corev1.TLSCertKey]
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.Webhook).NotTo(BeNil())
+					notAfter, err := webhook.CertificateNotAfter(certificate)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(obj.Status.Webhook.CertificateNotAfter.Time).To(BeTemporally("==", notAfter))
+					Expect(obj.Status.Webhook.CertificateRenewalTime.Time).To(BeTemporally("==", notAfter.Add(-webhookCertificateRenewBefore)))
+
+					By("keeping the certificate across reconciles")
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, webhookName, secret)).To(Succeed())
+					Expect(secret.Data[corev1.TLSCertKey]).To(Equal(certificate))
+
+					By("renewing the certificate due for renewal")
+					expiring, expiringKey := newTestCertificate(time.Now().Add(webhookCertificateRenewBefore / 2))
+					secret.Data[corev1.TLSCertKey] = expiring
+					secret.Data[corev1.TLSPrivateKeyKey] = expiringKey
+					Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, webhookName, secret)).To(Succeed())
+					renewed := secret.Data[corev1.TLSCertKey]
+					Expect(renewed).NotTo(Equal(expiring))
+					Expect(secret.Data[webhook.PreviousCertificateKey]).To(Equal(expiring))
+					mutate := &regv1.MutatingWebhookConfiguration{}
+					Expect(k8sClient.Get(ctx, webhookName, mutate)).To(Succeed())
+					Expect(mutate.Webhooks[0].ClientConfig.CABundle).To(Equal(append(append([]byte{}, renewed...), expiring...)))
+					deployment := &appsv1.Deployment{}
+					Expect(k8sClient.Get(ctx, webhookName, deployment)).To(Succeed())
+					renewedHash := sha256.Sum256(renewed)
+					Expect(deployment.Spec.Template.Annotations).To(HaveKeyWithValue(webhook.CertificateHashAnnotation, hex.EncodeToString(renewedHash[:])))
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.Webhook.CertificateNotAfter.Time).To(BeTemporally(">", time.Now().Add(webhookCertificateRenewBefore)))
+
+					By("removing the custom resource for the Kind")
+					Eventually(func() error {
+						return k8sClient.Delete(ctx, obj)
+					}, timeout).Should(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).To(Not(HaveOccurred()))
+				},
+			},
+			{
+				name: "webhook certificate from a CertificateSigningRequest",
+				obj: &irsav1alpha1.IRSASetup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-resource-webhook-csr",
 						Namespace: "default",
 					},
 					Spec: irsav1alpha1.IRSASetupSpec{
 						Cleanup: true,
-						// Mode:    "selfhoted",
 						Discovery: irsav1alpha1.Discovery{
 							S3: irsav1alpha1.S3Discovery{
 								Region:     "ap-northeast-1",
 								BucketName: "irsa-manager-1",
 							},
 						},
+						Webhook: &irsav1alpha1.Webhook{
+							Certificate: irsav1alpha1.WebhookCertificate{
+								Source: irsav1alpha1.WebhookCertificateCertificateSigningRequest,
+								CertificateSigningRequest: &irsav1alpha1.WebhookCertificateSigningRequest{
+									SignerName: "example.com/webhook-serving",
+								},
+							},
+						},
 					},
 				},
 				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
-					expected := []expectedResource{
-						{
-							NamespacedName: types.NamespacedName{Name: "irsa-manager-key", Namespace: "kube-system"},
-							f:              newSecret,
-						},
-						// webhook
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newDeployment,
-						},
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newService,
-						},
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newMutatingWebhookConfiguration,
-						},
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newServiceAccount,
-						},
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newClusterRole,
-						},
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newClusterRoleBinding,
-						},
-					}
 					typeNamespacedName := types.NamespacedName{
 						Name:      obj.Name,
 						Namespace: obj.Namespace,
 					}
-					By("secret does not exist when reconciling with the AwsClient error")
-					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{createOidcErr: fmt.Errorf("createOidcErr")}, &mockAwsS3API{}, &mockAwsStsAPI{})
-					_, err := r.Reconcile(ctx, reconcile.Request{
+					webhookName := webhook.SecretNamespacedName(irsav1alpha1.DefaultWebhookNamespace)
+					result, err := r.Reconcile(ctx, reconcile.Request{
 						NamespacedName: typeNamespacedName,
 					})
-					Expect(err).To(HaveOccurred())
-					for _, expect := range expected {
-						checkNoExist(expect)
-					}
-					By("successfully Reconciling")
-					r.AwsClient = newMockAwsClient(&mockAwsIamAPI{}, &mockAwsS3API{}, &mockAwsStsAPI{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.RequeueAfter).To(Equal(webhookCertificatePendingRequeueAfter))
+					secret := &corev1.Secret{}
+					Expect(k8sClient.Get(ctx, webhookName, secret)).To(Succeed())
+					Expect(secret.Data[corev1.TLSCertKey]).To(BeEmpty())
+					pendingKey := secret.Data[webhook.PendingPrivateKeyKey]
+					Expect(pendingKey).NotTo(BeEmpty())
+					request, err := webhook.NewCertificateSigningRequest(webhook.ServiceNamespacedName(irsav1alpha1.DefaultWebhookNamespace), pendingKey, "example.com/webhook-serving", nil)
+					Expect(err).NotTo(HaveOccurred())
+					csr := &certificatesv1.CertificateSigningRequest{}
+					Expect(k8sClient.Get(ctx, types.NamespacedName{Name: request.Name}, csr)).To(Succeed())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.Webhook.CertificateSource).To(Equal(irsav1alpha1.WebhookCertificateCertificateSigningRequest))
+					Expect(obj.Status.Webhook.Message).To(ContainSubstring(request.Name))
+					Expect(obj.Status.Webhook.CertificateNotAfter).To(BeNil())
+
+					By("using the certificate once the request is approved and signed")
+					csr.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{{
+						Type:   certificatesv1.CertificateApproved,
+						Status: corev1.ConditionTrue,
+						Reason: "Test",
+					}}
+					Expect(k8sClient.SubResource("approval").Update(ctx, csr)).To(Succeed())
+					caCertificate, certificate := signTestCertificateRequest(csr.Spec.Request)
+					csr.Status.Certificate = certificate
+					Expect(k8sClient.Status().Update(ctx, csr)).To(Succeed())
 					_, err = r.Reconcile(ctx, reconcile.Request{
 						NamespacedName: typeNamespacedName,
 					})
 					Expect(err).NotTo(HaveOccurred())
-					for _, expect := range expected {
-						checkExist(expect)
-					}
+					Expect(k8sClient.Get(ctx, webhookName, secret)).To(Succeed())
+					Expect(secret.Data[corev1.TLSCertKey]).To(Equal(certificate))
+					Expect(secret.Data[corev1.TLSPrivateKeyKey]).To(Equal(pendingKey))
+					Expect(secret.Data).NotTo(HaveKey(webhook.PendingPrivateKeyKey))
+					mutate := &regv1.MutatingWebhookConfiguration{}
+					Expect(k8sClient.Get(ctx, webhookName, mutate)).To(Succeed())
+					Expect(mutate.Webhooks[0].ClientConfig.CABundle).To(Equal(certificate))
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.Webhook.Message).To(BeEmpty())
+					Expect(obj.Status.Webhook.CertificateNotAfter).NotTo(BeNil())
+
+					By("keeping the replaced certificate in the CA bundle once the renewed one is signed")
+					expiring, expiringKey := newTestCertificate(time.Now().Add(webhookCertificateRenewBefore / 2))
+					secret.Data[corev1.TLSCertKey] = expiring
+					secret.Data[corev1.TLSPrivateKeyKey] = expiringKey
+					Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, webhookName, secret)).To(Succeed())
+					Expect(secret.Data[corev1.TLSCertKey]).To(Equal(expiring))
+					renewalKey := secret.Data[webhook.PendingPrivateKeyKey]
+					Expect(renewalKey).NotTo(BeEmpty())
+					renewalRequest, err := webhook.NewCertificateSigningRequest(webhook.ServiceNamespacedName(irsav1alpha1.DefaultWebhookNamespace), renewalKey, "example.com/webhook-serving", nil)
+					Expect(err).NotTo(HaveOccurred())
+					renewalCSR := &certificatesv1.CertificateSigningRequest{}
+					Expect(k8sClient.Get(ctx, types.NamespacedName{Name: renewalRequest.Name}, renewalCSR)).To(Succeed())
+					renewalCSR.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{{
+						Type:   certificatesv1.CertificateApproved,
+						Status: corev1.ConditionTrue,
+						Reason: "Test",
+					}}
+					Expect(k8sClient.SubResource("approval").Update(ctx, renewalCSR)).To(Succeed())
+					_, renewed := signTestCertificateRequest(renewalCSR.Spec.Request)
+					renewalCSR.Status.Certificate = renewed
+					Expect(k8sClient.Status().Update(ctx, renewalCSR)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, webhookName, secret)).To(Succeed())
+					Expect(secret.Data[corev1.TLSCertKey]).To(Equal(renewed))
+					Expect(secret.Data[webhook.PreviousCertificateKey]).To(Equal(expiring))
+					Expect(k8sClient.Get(ctx, webhookName, mutate)).To(Succeed())
+					Expect(mutate.Webhooks[0].ClientConfig.CABundle).To(Equal(append(append([]byte{}, renewed...), expiring...)))
+
+					By("setting the CA bundle of the signer")
+					obj.Spec.Webhook.Certificate.CertificateSigningRequest.CABundle = string(caCertificate)
+					Expect(k8sClient.Update(ctx, obj)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(k8sClient.Get(ctx, webhookName, mutate)).To(Succeed())
+					Expect(mutate.Webhooks[0].ClientConfig.CABundle).To(Equal(caCertificate))
+
 					By("removing the custom resource for the Kind")
 					Eventually(func() error {
 						return k8sClient.Delete(ctx, obj)
@@ -205,77 +1647,91 @@ var _ = Describe("IRSASetup Controller", func() {
 						NamespacedName: typeNamespacedName,
 					})
 					Expect(err).To(Not(HaveOccurred()))
-					for _, expect := range expected {
-						checkNoExist(expect)
-					}
+					Expect(k8sClient.Delete(ctx, csr)).To(Succeed())
+					Expect(k8sClient.Delete(ctx, renewalCSR)).To(Succeed())
 				},
 			},
 			{
-				name: "no cleanup",
+				name: "webhook deployment configuration",
 				obj: &irsav1alpha1.IRSASetup{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test-resource2",
+						Name:      "test-resource-webhook-config",
 						Namespace: "default",
 					},
 					Spec: irsav1alpha1.IRSASetupSpec{
-						Cleanup: false,
-						// Mode:    "selfhoted",
+						Cleanup: true,
 						Discovery: irsav1alpha1.Discovery{
 							S3: irsav1alpha1.S3Discovery{
 								Region:     "ap-northeast-1",
 								BucketName: "irsa-manager-1",
 							},
 						},
+						Webhook: &irsav1alpha1.Webhook{
+							Namespace:         "pod-identity",
+							Image:             "registry.example.com/pod-identity-webhook:v0.5.5",
+							ImagePullSecrets:  []corev1.LocalObjectReference{{Name: "registry"}},
+							Replicas:          aws.Int32(2),
+							PriorityClassName: "system-cluster-critical",
+						},
 					},
 				},
 				f: func(r *IRSASetupReconciler, obj *irsav1alpha1.IRSASetup) {
-					expected := []expectedResource{
-						{
-							NamespacedName: types.NamespacedName{Name: "irsa-manager-key", Namespace: "kube-system"},
-							f:              newSecret,
-						},
-						// webhook
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newDeployment,
-						},
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newService,
-						},
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newMutatingWebhookConfiguration,
-						},
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newServiceAccount,
-						},
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newClusterRole,
-						},
-						{
-							NamespacedName: types.NamespacedName{Name: "pod-identity-webhook", Namespace: "kube-system"},
-							f:              newClusterRoleBinding,
-						},
-					}
 					typeNamespacedName := types.NamespacedName{
 						Name:      obj.Name,
 						Namespace: obj.Namespace,
 					}
+					Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pod-identity"}})).To(Succeed())
+					movedName := webhook.SecretNamespacedName("pod-identity")
+					defaultName := webhook.SecretNamespacedName(irsav1alpha1.DefaultWebhookNamespace)
 					_, err := r.Reconcile(ctx, reconcile.Request{
 						NamespacedName: typeNamespacedName,
 					})
 					Expect(err).NotTo(HaveOccurred())
+					deployment := &appsv1.Deployment{}
+					Expect(k8sClient.Get(ctx, movedName, deployment)).To(Succeed())
+					Expect(*deployment.Spec.Replicas).To(Equal(int32(2)))
+					Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("registry.example.com/pod-identity-webhook:v0.5.5"))
+					Expect(deployment.Spec.Template.Spec.Containers[0].ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+					Expect(deployment.Spec.Template.Spec.ImagePullSecrets).To(Equal([]corev1.LocalObjectReference{{Name: "registry"}}))
+					Expect(deployment.Spec.Template.Spec.PriorityClassName).To(Equal("system-cluster-critical"))
+					Expect(k8sClient.Get(ctx, movedName, &policyv1.PodDisruptionBudget{})).To(Succeed())
+					mutate := &regv1.MutatingWebhookConfiguration{}
+					Expect(k8sClient.Get(ctx, movedName, mutate)).To(Succeed())
+					Expect(mutate.Webhooks[0].ClientConfig.Service.Namespace).To(Equal("pod-identity"))
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.Webhook.Namespace).To(Equal("pod-identity"))
+
+					By("moving the webhook back to the default namespace with a single replica")
+					obj.Spec.Webhook.Namespace = ""
+					obj.Spec.Webhook.Replicas = nil
+					Expect(k8sClient.Update(ctx, obj)).To(Succeed())
 					_, err = r.Reconcile(ctx, reconcile.Request{
 						NamespacedName: typeNamespacedName,
 					})
 					Expect(err).NotTo(HaveOccurred())
-					for _, expect := range expected {
-						checkExist(expect)
-					}
-					By("removing the custom resource (not cleanup)")
+					Expect(k8sClient.Get(ctx, defaultName, deployment)).To(Succeed())
+					Expect(*deployment.Spec.Replicas).To(Equal(int32(1)))
+					Expect(errors.IsNotFound(k8sClient.Get(ctx, movedName, &appsv1.Deployment{}))).To(BeTrue())
+					Expect(errors.IsNotFound(k8sClient.Get(ctx, movedName, &corev1.Secret{}))).To(BeTrue())
+					Expect(errors.IsNotFound(k8sClient.Get(ctx, movedName, &policyv1.PodDisruptionBudget{}))).To(BeTrue())
+					Expect(errors.IsNotFound(k8sClient.Get(ctx, defaultName, &policyv1.PodDisruptionBudget{}))).To(BeTrue())
+					Expect(k8sClient.Get(ctx, defaultName, mutate)).To(Succeed())
+					Expect(mutate.Webhooks[0].ClientConfig.Service.Namespace).To(Equal(irsav1alpha1.DefaultWebhookNamespace))
+
+					By("disabling the webhook")
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					obj.Spec.Webhook.Enabled = aws.Bool(false)
+					Expect(k8sClient.Update(ctx, obj)).To(Succeed())
+					_, err = r.Reconcile(ctx, reconcile.Request{
+						NamespacedName: typeNamespacedName,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(errors.IsNotFound(k8sClient.Get(ctx, defaultName, &appsv1.Deployment{}))).To(BeTrue())
+					Expect(errors.IsNotFound(k8sClient.Get(ctx, defaultName, &regv1.MutatingWebhookConfiguration{}))).To(BeTrue())
+					Expect(k8sClient.Get(ctx, typeNamespacedName, obj)).To(Succeed())
+					Expect(obj.Status.Webhook).To(BeNil())
+
+					By("removing the custom resource for the Kind")
 					Eventually(func() error {
 						return k8sClient.Delete(ctx, obj)
 					}, timeout).Should(Succeed())
@@ -283,9 +1739,6 @@ var _ = Describe("IRSASetup Controller", func() {
 						NamespacedName: typeNamespacedName,
 					})
 					Expect(err).To(Not(HaveOccurred()))
-					for _, expect := range expected {
-						checkExist(expect)
-					}
 				},
 			},
 			{
@@ -349,31 +1802,89 @@ var _ = Describe("IRSASetup Controller", func() {
 			})
 		}
 		BeforeEach(func() {
+			issuerThumbprint = func(ctx context.Context, issuerUrl string, options awsclient.ThumbprintOptions) (string, bool, error) {
+				return issuerThumbprintForTest, true, nil
+			}
 		})
 		AfterEach(func() {
 		})
 	})
 })
 
+// issuerThumbprintForTest is the thumbprint of the issuers that are not served by AWS, which are not reachable in the tests.
+const issuerThumbprintForTest = "9e99a48a9960b14926bb7f3b02e22da2b0ab7280"
+
+// newTestCertificate returns a PEM encoded self-signed certificate expiring at notAfter and its private key.
+func newTestCertificate(notAfter time.Time) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pod-identity-webhook.kube-system.svc"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+// signTestCertificateRequest signs a PEM encoded certificate request with a new CA,
+// and returns the PEM encoded CA certificate and signed certificate.
+func signTestCertificateRequest(request []byte) ([]byte, []byte) {
+	caCertificate, caKey := newTestCertificate(time.Now().Add(365 * 24 * time.Hour))
+	caBlock, _ := pem.Decode(caCertificate)
+	ca, err := x509.ParseCertificate(caBlock.Bytes)
+	Expect(err).NotTo(HaveOccurred())
+	keyBlock, _ := pem.Decode(caKey)
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	Expect(err).NotTo(HaveOccurred())
+	requestBlock, _ := pem.Decode(request)
+	csr, err := x509.ParseCertificateRequest(requestBlock.Bytes)
+	Expect(err).NotTo(HaveOccurred())
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, ca, csr.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	return caCertificate, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
 func newMockAwsClient(iam *mockAwsIamAPI, s3 *mockAwsS3API, sts *mockAwsStsAPI) awsclient.AwsClient {
 	return &mockAwsClient{
-		iam,
-		s3,
-		sts,
+		iam:        iam,
+		s3:         s3,
+		sts:        sts,
+		cloudFront: &mockAwsCloudFrontAPI{},
 	}
 }
 
 type mockAwsClient struct {
-	iam *mockAwsIamAPI
-	s3  *mockAwsS3API
-	sts *mockAwsStsAPI
+	iam        *mockAwsIamAPI
+	s3         *mockAwsS3API
+	sts        *mockAwsStsAPI
+	cloudFront *mockAwsCloudFrontAPI
+	// buckets are the buckets other than s3, keyed by their names.
+	buckets map[string]*mockAwsS3API
 }
 
 func (m *mockAwsClient) IamClient() *awsclient.AwsIamClient {
 	return &awsclient.AwsIamClient{Client: m.iam}
 }
 
-func (m *mockAwsClient) S3Client(region, bucketName string) *awsclient.AwsS3Client {
+func (m *mockAwsClient) S3Client(region, bucketName string, optFns ...func(*s3.Options)) *awsclient.AwsS3Client {
+	if bucket, ok := m.buckets[bucketName]; ok {
+		return &awsclient.AwsS3Client{Client: bucket}
+	}
 	return &awsclient.AwsS3Client{Client: m.s3}
 }
 
@@ -381,6 +1892,10 @@ func (m *mockAwsClient) StsClient() *awsclient.AwsStsClient {
 	return &awsclient.AwsStsClient{Client: m.sts}
 }
 
+func (m *mockAwsClient) CloudFrontClient() *awsclient.AwsCloudFrontClient {
+	return &awsclient.AwsCloudFrontClient{Client: m.cloudFront}
+}
+
 type (
 	mockAwsIamAPI struct {
 		createOidcErr                 error
@@ -391,22 +1906,85 @@ type (
 		listAttachedRolePoliciesError error
 		attachRolePolicyError         error
 		detachRolePolicyError         error
+		oidcNotFound                  bool
+		// deletedOIDCProviderArns are the ARNs of the deleted IAM OIDC providers.
+		deletedOIDCProviderArns []string
+		// clientIDList and thumbprintList are the ones of the IAM OIDC provider, which has the STS client ID when clientIDList is nil.
+		clientIDList   []string
+		thumbprintList []string
+		// assumeRolePolicy is the last trust policy set on a role.
+		assumeRolePolicy string
 	}
 	mockAwsS3API struct {
 		createBucketErr bool
 		deleteBucketErr bool
+		objects         map[string][]byte
+		objectACLs      map[string]s3types.ObjectCannedACL
+		bucketPolicy    string
+		bucketTags      []s3types.Tag
+		bucketDeleted   bool
+		etags           map[string]string
+		// noncurrentVersions is the number of the noncurrent versions of each object, which are kept when it is replaced.
+		noncurrentVersions map[string]int
+		// beforeConditionalPut is called once before the first conditional put, e.g. to simulate a concurrent update.
+		beforeConditionalPut func(*mockAwsS3API)
+	}
+	mockAwsStsAPI struct {
+		// account is the ID of the caller's account, which is "123456789012" when it is empty.
+		account string
+	}
+	mockAwsCloudFrontAPI struct {
+		distributions        map[string]*cftypes.Distribution
+		originAccessControls []cftypes.OriginAccessControlSummary
 	}
-	mockAwsStsAPI struct{}
 )
 
 func (m *mockAwsIamAPI) CreateOpenIDConnectProvider(ctx context.Context, params *iam.CreateOpenIDConnectProviderInput, optFns ...func(*iam.Options)) (*iam.CreateOpenIDConnectProviderOutput, error) {
+	if m.createOidcErr == nil {
+		m.clientIDList = params.ClientIDList
+		m.thumbprintList = params.ThumbprintList
+	}
 	return &iam.CreateOpenIDConnectProviderOutput{OpenIDConnectProviderArn: aws.String("arn::mock")}, m.createOidcErr
 }
 
 func (m *mockAwsIamAPI) DeleteOpenIDConnectProvider(ctx context.Context, params *iam.DeleteOpenIDConnectProviderInput, optFns ...func(*iam.Options)) (*iam.DeleteOpenIDConnectProviderOutput, error) {
+	if m.deleteOidcErr == nil {
+		m.deletedOIDCProviderArns = append(m.deletedOIDCProviderArns, *params.OpenIDConnectProviderArn)
+	}
 	return &iam.DeleteOpenIDConnectProviderOutput{}, m.deleteOidcErr
 }
 
+func (m *mockAwsIamAPI) GetOpenIDConnectProvider(ctx context.Context, params *iam.GetOpenIDConnectProviderInput, optFns ...func(*iam.Options)) (*iam.GetOpenIDConnectProviderOutput, error) {
+	if m.oidcNotFound {
+		return nil, &iamtypes.NoSuchEntityException{}
+	}
+	return &iam.GetOpenIDConnectProviderOutput{ClientIDList: m.clientIDs(), ThumbprintList: m.thumbprintList}, nil
+}
+
+func (m *mockAwsIamAPI) AddClientIDToOpenIDConnectProvider(ctx context.Context, params *iam.AddClientIDToOpenIDConnectProviderInput, optFns ...func(*iam.Options)) (*iam.AddClientIDToOpenIDConnectProviderOutput, error) {
+	m.clientIDList = append(m.clientIDs(), *params.ClientID)
+	return &iam.AddClientIDToOpenIDConnectProviderOutput{}, nil
+}
+
+func (m *mockAwsIamAPI) RemoveClientIDFromOpenIDConnectProvider(ctx context.Context, params *iam.RemoveClientIDFromOpenIDConnectProviderInput, optFns ...func(*iam.Options)) (*iam.RemoveClientIDFromOpenIDConnectProviderOutput, error) {
+	m.clientIDList = slices.DeleteFunc(slices.Clone(m.clientIDs()), func(clientID string) bool {
+		return clientID == *params.ClientID
+	})
+	return &iam.RemoveClientIDFromOpenIDConnectProviderOutput{}, nil
+}
+
+func (m *mockAwsIamAPI) UpdateOpenIDConnectProviderThumbprint(ctx context.Context, params *iam.UpdateOpenIDConnectProviderThumbprintInput, optFns ...func(*iam.Options)) (*iam.UpdateOpenIDConnectProviderThumbprintOutput, error) {
+	m.thumbprintList = params.ThumbprintList
+	return &iam.UpdateOpenIDConnectProviderThumbprintOutput{}, nil
+}
+
+func (m *mockAwsIamAPI) clientIDs() []string {
+	if m.clientIDList == nil {
+		return []string{"sts.amazonaws.com"}
+	}
+	return m.clientIDList
+}
+
 func (m *mockAwsIamAPI) CreateRole(ctx context.Context, params *iam.CreateRoleInput, optFns ...func(*iam.Options)) (*iam.CreateRoleOutput, error) {
 	return nil, m.createRoleErr
 }
@@ -416,6 +1994,7 @@ func (m *mockAwsIamAPI) ListAttachedRolePolicies(ctx context.Context, params *ia
 }
 
 func (m *mockAwsIamAPI) UpdateAssumeRolePolicy(ctx context.Context, params *iam.UpdateAssumeRolePolicyInput, optFns ...func(*iam.Options)) (*iam.UpdateAssumeRolePolicyOutput, error) {
+	m.assumeRolePolicy = aws.ToString(params.PolicyDocument)
 	return nil, m.updateAssumeRolePolicyError
 }
 
@@ -432,6 +2011,9 @@ func (m *mockAwsIamAPI) DetachRolePolicy(ctx context.Context, params *iam.Detach
 }
 
 func (m *mockAwsStsAPI) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	if m != nil && m.account != "" {
+		return &sts.GetCallerIdentityOutput{Account: aws.String(m.account)}, nil
+	}
 	return &sts.GetCallerIdentityOutput{Account: aws.String("123456789012")}, nil
 }
 
@@ -443,6 +2025,29 @@ func (m *mockAwsS3API) CreateBucket(ctx context.Context, params *s3.CreateBucket
 }
 
 func (m *mockAwsS3API) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	header, err := requestHeader(optFns...)
+	if err != nil {
+		return nil, err
+	}
+	ifMatch, ifNoneMatch := header.Get("If-Match"), header.Get("If-None-Match")
+	if (ifMatch != "" || ifNoneMatch != "") && m.beforeConditionalPut != nil {
+		f := m.beforeConditionalPut
+		m.beforeConditionalPut = nil
+		f(m)
+	}
+	_, exists := m.objects[*params.Key]
+	if (ifMatch != "" && ifMatch != m.etags[*params.Key]) || (ifNoneMatch == "*" && exists) {
+		return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+	}
+	m.putObject(*params.Key, body)
+	if m.objectACLs == nil {
+		m.objectACLs = map[string]s3types.ObjectCannedACL{}
+	}
+	m.objectACLs[*params.Key] = params.ACL
 	return nil, nil
 }
 
@@ -450,6 +2055,53 @@ func (m *mockAwsS3API) HeadObject(ctx context.Context, params *s3.HeadObjectInpu
 	return nil, &s3types.NotFound{}
 }
 
+func (m *mockAwsS3API) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	body, ok := m.objects[*params.Key]
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body)), ETag: aws.String(m.etags[*params.Key])}, nil
+}
+
+// putObject stores the object with a new ETag.
+func (m *mockAwsS3API) putObject(key string, body []byte) {
+	if m.objects == nil {
+		m.objects = map[string][]byte{}
+	}
+	if m.etags == nil {
+		m.etags = map[string]string{}
+	}
+	if _, ok := m.objects[key]; ok {
+		if m.noncurrentVersions == nil {
+			m.noncurrentVersions = map[string]int{}
+		}
+		m.noncurrentVersions[key]++
+	}
+	m.objects[key] = body
+	m.etags[key] = fmt.Sprintf(`"%x"`, sha256.Sum256(body))
+}
+
+// requestHeader returns the HTTP header set by the options of a request, e.g. the conditional headers.
+func requestHeader(optFns ...func(*s3.Options)) (http.Header, error) {
+	o := s3.Options{}
+	for _, fn := range optFns {
+		fn(&o)
+	}
+	stack := middleware.NewStack("mock", smithyhttp.NewStackRequest)
+	for _, fn := range o.APIOptions {
+		if err := fn(stack); err != nil {
+			return nil, err
+		}
+	}
+	header := http.Header{}
+	h := middleware.DecorateHandler(middleware.HandlerFunc(func(ctx context.Context, in interface{}) (interface{}, middleware.Metadata, error) {
+		header = in.(*smithyhttp.Request).Header
+		return nil, middleware.Metadata{}, nil
+	}), stack)
+	_, _, err := h.Handle(context.Background(), nil)
+	return header, err
+}
+
 func (m *mockAwsS3API) DeletePublicAccessBlock(ctx context.Context, params *s3.DeletePublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.DeletePublicAccessBlockOutput, error) {
 	return nil, nil
 }
@@ -458,13 +2110,174 @@ func (m *mockAwsS3API) PutBucketOwnershipControls(ctx context.Context, params *s
 	return nil, nil
 }
 
+func (m *mockAwsS3API) PutPublicAccessBlock(ctx context.Context, params *s3.PutPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error) {
+	return nil, nil
+}
+
+func (m *mockAwsS3API) PutBucketPolicy(ctx context.Context, params *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
+	m.bucketPolicy = *params.Policy
+	return nil, nil
+}
+
+func (m *mockAwsS3API) GetBucketPolicy(ctx context.Context, params *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+	if m.bucketPolicy == "" {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchBucketPolicy"}
+	}
+	return &s3.GetBucketPolicyOutput{Policy: aws.String(m.bucketPolicy)}, nil
+}
+
+func (m *mockAwsS3API) DeleteBucketPolicy(ctx context.Context, params *s3.DeleteBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketPolicyOutput, error) {
+	m.bucketPolicy = ""
+	return nil, nil
+}
+
+func (m *mockAwsS3API) PutBucketEncryption(ctx context.Context, params *s3.PutBucketEncryptionInput, optFns ...func(*s3.Options)) (*s3.PutBucketEncryptionOutput, error) {
+	return nil, nil
+}
+
+func (m *mockAwsS3API) PutBucketVersioning(ctx context.Context, params *s3.PutBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error) {
+	return nil, nil
+}
+
+func (m *mockAwsS3API) PutBucketTagging(ctx context.Context, params *s3.PutBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error) {
+	m.bucketTags = params.Tagging.TagSet
+	return nil, nil
+}
+
 func (m *mockAwsS3API) DeleteBucket(ctx context.Context, params *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
 	if m.deleteBucketErr {
 		return nil, fmt.Errorf("delete bucket error")
 	}
+	if len(m.objects) > 0 || len(m.noncurrentVersions) > 0 {
+		return nil, &smithy.GenericAPIError{Code: "BucketNotEmpty"}
+	}
+	m.bucketDeleted = true
 	return nil, nil
 }
 
+// DeleteObjects deletes the current object when no version or the "current" version is given,
+// and otherwise one of its noncurrent versions.
 func (m *mockAwsS3API) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
-	return nil, nil
+	for _, obj := range params.Delete.Objects {
+		if version := aws.ToString(obj.VersionId); version != "" && version != "current" {
+			m.noncurrentVersions[*obj.Key]--
+			if m.noncurrentVersions[*obj.Key] == 0 {
+				delete(m.noncurrentVersions, *obj.Key)
+			}
+			continue
+		}
+		delete(m.objects, *obj.Key)
+		delete(m.etags, *obj.Key)
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func (m *mockAwsS3API) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	out := &s3.ListObjectVersionsOutput{}
+	for key := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			out.Versions = append(out.Versions, s3types.ObjectVersion{Key: aws.String(key), VersionId: aws.String("current")})
+		}
+	}
+	for key, n := range m.noncurrentVersions {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			for i := range n {
+				out.Versions = append(out.Versions, s3types.ObjectVersion{Key: aws.String(key), VersionId: aws.String(fmt.Sprintf("v%d", i))})
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *mockAwsS3API) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	out := &s3.ListObjectsV2Output{}
+	for key := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			out.Contents = append(out.Contents, s3types.Object{Key: aws.String(key)})
+		}
+	}
+	return out, nil
+}
+
+func (m *mockAwsCloudFrontAPI) CreateDistribution(ctx context.Context, params *cloudfront.CreateDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateDistributionOutput, error) {
+	if m.distributions == nil {
+		m.distributions = map[string]*cftypes.Distribution{}
+	}
+	id := fmt.Sprintf("E%d", len(m.distributions)+1)
+	d := &cftypes.Distribution{
+		Id:                 aws.String(id),
+		ARN:                aws.String(fmt.Sprintf("arn:aws:cloudfront::123456789012:distribution/%s", id)),
+		DomainName:         aws.String(fmt.Sprintf("%s.cloudfront.net", strings.ToLower(id))),
+		Status:             aws.String("InProgress"),
+		DistributionConfig: params.DistributionConfig,
+	}
+	m.distributions[id] = d
+	return &cloudfront.CreateDistributionOutput{Distribution: d}, nil
+}
+
+func (m *mockAwsCloudFrontAPI) GetDistribution(ctx context.Context, params *cloudfront.GetDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetDistributionOutput, error) {
+	d, ok := m.distributions[*params.Id]
+	if !ok {
+		return nil, &cftypes.NoSuchDistribution{}
+	}
+	return &cloudfront.GetDistributionOutput{Distribution: d, ETag: aws.String("etag")}, nil
+}
+
+func (m *mockAwsCloudFrontAPI) ListDistributions(ctx context.Context, params *cloudfront.ListDistributionsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListDistributionsOutput, error) {
+	items := []cftypes.DistributionSummary{}
+	for _, d := range m.distributions {
+		items = append(items, cftypes.DistributionSummary{
+			Id:         d.Id,
+			ARN:        d.ARN,
+			DomainName: d.DomainName,
+			Comment:    d.DistributionConfig.Comment,
+		})
+	}
+	return &cloudfront.ListDistributionsOutput{DistributionList: &cftypes.DistributionList{Items: items}}, nil
+}
+
+func (m *mockAwsCloudFrontAPI) UpdateDistribution(ctx context.Context, params *cloudfront.UpdateDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.UpdateDistributionOutput, error) {
+	d := m.distributions[*params.Id]
+	d.DistributionConfig = params.DistributionConfig
+	// the change is deployed immediately
+	d.Status = aws.String("Deployed")
+	return &cloudfront.UpdateDistributionOutput{Distribution: d}, nil
+}
+
+func (m *mockAwsCloudFrontAPI) DeleteDistribution(ctx context.Context, params *cloudfront.DeleteDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.DeleteDistributionOutput, error) {
+	delete(m.distributions, *params.Id)
+	return &cloudfront.DeleteDistributionOutput{}, nil
+}
+
+func (m *mockAwsCloudFrontAPI) CreateOriginAccessControl(ctx context.Context, params *cloudfront.CreateOriginAccessControlInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateOriginAccessControlOutput, error) {
+	oac := cftypes.OriginAccessControlSummary{
+		Id:   aws.String(fmt.Sprintf("OAC%d", len(m.originAccessControls)+1)),
+		Name: params.OriginAccessControlConfig.Name,
+	}
+	m.originAccessControls = append(m.originAccessControls, oac)
+	return &cloudfront.CreateOriginAccessControlOutput{
+		OriginAccessControl: &cftypes.OriginAccessControl{Id: oac.Id, OriginAccessControlConfig: params.OriginAccessControlConfig},
+	}, nil
+}
+
+func (m *mockAwsCloudFrontAPI) GetOriginAccessControl(ctx context.Context, params *cloudfront.GetOriginAccessControlInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetOriginAccessControlOutput, error) {
+	for _, oac := range m.originAccessControls {
+		if *oac.Id == *params.Id {
+			return &cloudfront.GetOriginAccessControlOutput{ETag: aws.String("etag")}, nil
+		}
+	}
+	return nil, &cftypes.NoSuchOriginAccessControl{}
+}
+
+func (m *mockAwsCloudFrontAPI) ListOriginAccessControls(ctx context.Context, params *cloudfront.ListOriginAccessControlsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListOriginAccessControlsOutput, error) {
+	return &cloudfront.ListOriginAccessControlsOutput{
+		OriginAccessControlList: &cftypes.OriginAccessControlList{Items: m.originAccessControls},
+	}, nil
+}
+
+func (m *mockAwsCloudFrontAPI) DeleteOriginAccessControl(ctx context.Context, params *cloudfront.DeleteOriginAccessControlInput, optFns ...func(*cloudfront.Options)) (*cloudfront.DeleteOriginAccessControlOutput, error) {
+	m.originAccessControls = slices.DeleteFunc(m.originAccessControls, func(oac cftypes.OriginAccessControlSummary) bool {
+		return *oac.Id == *params.Id
+	})
+	return &cloudfront.DeleteOriginAccessControlOutput{}, nil
 }
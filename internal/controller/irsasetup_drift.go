@@ -0,0 +1,155 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
+	"github.com/kkb0318/irsa-manager/internal/issuer"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+)
+
+// driftReasons are the reasons of a Ready condition that was set to false by the drift detection.
+var driftReasons = []string{
+	string(irsav1alpha1.SelfHostedReasonDriftFailedSigningKey),
+	string(irsav1alpha1.SelfHostedReasonDriftFailedDiscovery),
+	string(irsav1alpha1.SelfHostedReasonDriftFailedOidc),
+	string(irsav1alpha1.SelfHostedReasonDriftFailedWebhook),
+}
+
+// hasDrifted reports whether the self-hosted resources were set up but the drift detection could not repair them.
+// Such resources are verified again instead of being set up from scratch, which would replace the signing key.
+func hasDrifted(obj irsav1alpha1.IRSASetup) bool {
+	return obj.Spec.DriftDetection != nil && irsav1alpha1.HasConditionReason(irsav1alpha1.ReadyStatus(obj), driftReasons...)
+}
+
+// reconcileDrift verifies that the self-hosted resources still match the expected state and repairs them.
+// The CloudFront distribution is looked up again, the bucket settings are applied again, the discovery documents are uploaded again when they differ from the ones
+// derived from the current signing keys, the IAM OIDC provider is recreated or updated, and the webhook resources are applied again.
+// If something cannot be repaired, the Ready condition is set to false with a reason specific to the failed resource.
+func reconcileDrift(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient, apiServer *selfhosted.APIServerDiscovery) error {
+	log := ctrllog.FromContext(ctx)
+
+	// e is set only when an error occurs in an external dependency process and is reflected in the CRs status
+	var e error
+	var reason irsav1alpha1.SelfhostedConditionReason
+	defer func() {
+		if e != nil {
+			*obj = irsav1alpha1.StatusNotReady(*obj, string(reason), e.Error())
+		}
+	}()
+
+	_, jwk, err := expectedKeys(ctx, obj, kubeClient, apiServer)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonDriftFailedSigningKey
+		return err
+	}
+	if err := reconcileCloudFront(ctx, obj, awsClient); err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonDriftFailedDiscovery
+		return err
+	}
+	factory, err := newOIDCIdpFactory(ctx, obj, jwk, awsClient, kubeClient)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonDriftFailedDiscovery
+		return err
+	}
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonFailedIssuer
+		return err
+	}
+
+	repaired := []string{}
+	discovery := factory.IdPDiscovery()
+	discoveryContents := factory.IdPDiscoveryContents(issuerMeta)
+	if err := discovery.CreateStorage(ctx); err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonDriftFailedDiscovery
+		return err
+	}
+	drifted, err := discovery.IsUpdate(ctx, discoveryContents)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonDriftFailedDiscovery
+		return err
+	}
+	if drifted {
+		if err := discovery.Upload(ctx, discoveryContents, true); err != nil {
+			e = err
+			reason = irsav1alpha1.SelfHostedReasonDriftFailedDiscovery
+			return err
+		}
+		repaired = append(repaired, "discovery")
+	}
+
+	idp, err := factory.IdP(issuerMeta)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonDriftFailedOidc
+		return err
+	}
+	drifted, err = idp.IsUpdate(ctx)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonDriftFailedOidc
+		return err
+	}
+	if drifted {
+		if err := idp.Update(ctx); err != nil {
+			e = err
+			reason = irsav1alpha1.SelfHostedReasonDriftFailedOidc
+			return err
+		}
+		repaired = append(repaired, "oidc provider")
+	}
+	recordOIDCProviderClientIDs(obj)
+
+	if err := applyWebhook(ctx, obj, kubeClient, time.Now(), true); err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonDriftFailedWebhook
+		return err
+	}
+
+	if len(repaired) > 0 {
+		log.Info("the drifted self-hosted resources have been repaired", "resources", repaired)
+	}
+	if !irsav1alpha1.IsReadyConditionTrue(*obj) {
+		*obj = irsav1alpha1.SetupStatusReady(*obj, string(irsav1alpha1.SelfHostedReasonReady), "successfully repaired resources for self-hosted")
+	}
+	return nil
+}
+
+// shortestRequeue returns the shortest non-zero period, or zero when all periods are zero.
+func shortestRequeue(periods ...time.Duration) time.Duration {
+	var shortest time.Duration
+	for _, period := range periods {
+		if period > 0 && (shortest == 0 || period < shortest) {
+			shortest = period
+		}
+	}
+	return shortest
+}
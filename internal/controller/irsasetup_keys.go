@@ -0,0 +1,500 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
+	"github.com/kkb0318/irsa-manager/internal/handler"
+	"github.com/kkb0318/irsa-manager/internal/issuer"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+	"github.com/kkb0318/irsa-manager/internal/manifests"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+)
+
+// apiServerResyncPeriod is the period after which the JWKS of the kube-apiserver is checked for changes.
+const apiServerResyncPeriod = 5 * time.Minute
+
+// reconcileKeyRotation rotates the service account signing key according to the KeyRotation policy.
+// A new key is first published in the JWKS together with the current keys and stored in the key Secret as the pending key,
+// so that STS and the other relying parties can fetch it before any token is signed with it.
+// It replaces the active key once the propagation delay has passed, and the replaced key stays in the JWKS
+// until the overlap window has passed, so that tokens signed with either key can be validated during the rotation.
+// A missing active key, or one with another algorithm than the configured one, is rotated as well.
+// It returns the time until the next scheduled rotation, activation or retirement.
+func reconcileKeyRotation(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient, now time.Time) (time.Duration, error) {
+	log := ctrllog.FromContext(ctx)
+	rotation := obj.Spec.KeyRotation
+
+	// e is set only when an error occurs in an external dependency process and is reflected in the CRs status
+	var e error
+	var reason irsav1alpha1.SelfhostedConditionReason
+	defer func() {
+		if e != nil {
+			*obj = irsav1alpha1.SetupStatusKeyRotation(*obj, metav1.ConditionFalse, string(reason), e.Error())
+		}
+	}()
+
+	keys, secret, err := loadSigningKeys(ctx, kubeClient)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonFailedKeyRotation
+		return 0, err
+	}
+	status := obj.Status.DeepCopy()
+	if err := syncGeneratedSigningKeyStatus(status, keys, secret.CreationTimestamp, now); err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonFailedKeyRotation
+		return 0, err
+	}
+
+	algorithm := obj.Spec.SigningKey.KeyAlgorithm()
+	active := status.ActiveSigningKey()
+	pending := status.PendingSigningKey()
+	prepared := pending == nil && (rotation.IsDue(*status, now) || active == nil || active.Algorithm != algorithm)
+	activated := pending != nil && rotation.IsPropagated(*pending, now)
+	if prepared {
+		keyPair, err := selfhosted.CreateKeyPair(algorithm)
+		if err != nil {
+			e = err
+			reason = irsav1alpha1.SelfHostedReasonFailedKeyRotation
+			return 0, err
+		}
+		keys.Prepare(*keyPair)
+		status.LastRotationTrigger = rotation.Trigger
+	}
+	if activated {
+		if err := keys.Activate(); err != nil {
+			e = err
+			reason = irsav1alpha1.SelfHostedReasonFailedKeyRotation
+			return 0, err
+		}
+	}
+	if prepared || activated {
+		if err := syncGeneratedSigningKeyStatus(status, keys, metav1.NewTime(now), now); err != nil {
+			e = err
+			reason = irsav1alpha1.SelfHostedReasonFailedKeyRotation
+			return 0, err
+		}
+	}
+
+	retired := []string{}
+	for _, key := range status.SigningKeys {
+		if rotation.IsExpired(key, now) {
+			if err := keys.Retire(key.KeyID); err != nil {
+				e = err
+				reason = irsav1alpha1.SelfHostedReasonFailedKeyRetirement
+				return 0, err
+			}
+			retired = append(retired, key.KeyID)
+		}
+	}
+	status.SigningKeys = slices.DeleteFunc(status.SigningKeys, func(key irsav1alpha1.SigningKeyStatus) bool {
+		return slices.Contains(retired, key.KeyID)
+	})
+
+	if prepared || activated || len(retired) > 0 {
+		if err := publishSigningKeys(ctx, obj, keys, awsClient, kubeClient); err != nil {
+			e = err
+			reason = irsav1alpha1.SelfHostedReasonFailedKeyRotation
+			if !prepared && !activated {
+				reason = irsav1alpha1.SelfHostedReasonFailedKeyRetirement
+			}
+			return 0, err
+		}
+		message := fmt.Sprintf("signing key generation %d is active", status.ActiveSigningKey().Generation)
+		if pending := status.PendingSigningKey(); pending != nil {
+			message = fmt.Sprintf("%s, generation %d is published and becomes active at %s", message, pending.Generation,
+				rotation.ActivationTime(*pending).Format(time.RFC3339))
+		}
+		if len(retired) > 0 {
+			message = fmt.Sprintf("%s, retired keys: %v", message, retired)
+		}
+		*obj = irsav1alpha1.SetupStatusKeyRotation(*obj, metav1.ConditionTrue, string(irsav1alpha1.SelfHostedReasonKeyRotated), message)
+		log.Info("the signing keys have been updated", "prepared", prepared, "activated", activated, "retired", retired)
+	}
+	obj.Status.SigningKeys = status.SigningKeys
+	obj.Status.LastRotationTrigger = status.LastRotationTrigger
+	return rotation.NextEvent(obj.Status, now), nil
+}
+
+// publishSigningKeys publishes the JWKS of all the signing keys and then stores them in the key Secret.
+func publishSigningKeys(ctx context.Context, obj *irsav1alpha1.IRSASetup, keys *selfhosted.SigningKeys, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient) error {
+	jwk, err := selfhosted.NewJWK(keys.PublicKeys())
+	if err != nil {
+		return err
+	}
+	factory, err := newOIDCIdpFactory(ctx, obj, jwk, awsClient, kubeClient)
+	if err != nil {
+		return err
+	}
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+	if err != nil {
+		return err
+	}
+	if err := selfhosted.Publish(ctx, factory, issuerMeta); err != nil {
+		return err
+	}
+	secret, err := manifests.NewSecretBuilder().WithSigningKeys(*keys).Build(manifests.SshKeyNamespacedName())
+	if err != nil {
+		return err
+	}
+	kubeHandler := handler.NewKubernetesHandler(kubeClient)
+	kubeHandler.Append(secret)
+	_, err = kubeHandler.ApplyAll(ctx)
+	return err
+}
+
+// loadSigningKeys reads the signing keys from the key Secret.
+func loadSigningKeys(ctx context.Context, kubeClient *kubernetes.KubernetesClient) (*selfhosted.SigningKeys, *corev1.Secret, error) {
+	secret, err := getSecret(ctx, kubeClient, manifests.SshKeyNamespacedName())
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := selfhosted.LoadSigningKeys(secret.Data[corev1.SSHAuthPrivateKey], secret.Data[manifests.PendingSigningKeyKey], secret.Data[manifests.SigningPublicKeysKey])
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, secret, nil
+}
+
+// ensureSigningKeys reads the signing keys from the key Secret.
+// The key Secret is generated only if it does not exist yet, so that retries never replace a key that may already be
+// published or configured in the kube-apiserver. It also returns the creation time of the key Secret.
+func ensureSigningKeys(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) (*selfhosted.SigningKeys, metav1.Time, error) {
+	keys, secret, err := loadSigningKeys(ctx, kubeClient)
+	if err == nil {
+		return keys, secret.CreationTimestamp, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, metav1.Time{}, err
+	}
+	keyPair, err := selfhosted.CreateKeyPair(obj.Spec.SigningKey.KeyAlgorithm())
+	if err != nil {
+		return nil, metav1.Time{}, err
+	}
+	secret, err = manifests.NewSecretBuilder().WithSSHKey(*keyPair).Build(manifests.SshKeyNamespacedName())
+	if err != nil {
+		return nil, metav1.Time{}, err
+	}
+	kubeHandler := handler.NewKubernetesHandler(kubeClient)
+	kubeHandler.Append(secret)
+	if err := kubeHandler.CreateAll(ctx); err != nil {
+		return nil, metav1.Time{}, err
+	}
+	return selfhosted.NewSigningKeys(*keyPair), metav1.Now(), nil
+}
+
+// expectedKeys returns the PEM encoded public keys that have to be published, and the JWKS publishing them.
+func expectedKeys(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient, apiServer *selfhosted.APIServerDiscovery) ([]byte, *selfhosted.JWK, error) {
+	if obj.Spec.SigningKey.IsExternal() {
+		return loadExternalKeys(ctx, obj, kubeClient, apiServer)
+	}
+	keys, _, err := loadSigningKeys(ctx, kubeClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	jwk, err := selfhosted.NewJWK(keys.PublicKeys())
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys.PublicKeys(), jwk, nil
+}
+
+// reconcileSigningKeySync sets the SigningKeySynced condition according to whether the key IDs of the published JWKS
+// match the signing keys.
+func reconcileSigningKeySync(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient, apiServer *selfhosted.APIServerDiscovery) error {
+	// e is set only when an error occurs in an external dependency process and is reflected in the CRs status
+	var e error
+	defer func() {
+		if e != nil {
+			*obj = irsav1alpha1.SetupStatusSigningKeySynced(*obj, metav1.ConditionFalse, string(irsav1alpha1.SelfHostedReasonFailedSigningKeyCheck), e.Error())
+		}
+	}()
+
+	pubs, _, err := expectedKeys(ctx, obj, kubeClient, apiServer)
+	if err != nil {
+		e = err
+		return err
+	}
+	expected, err := selfhosted.PublicKeyIDs(pubs)
+	if err != nil {
+		e = err
+		return err
+	}
+	factory, err := newOIDCIdpFactory(ctx, obj, nil, awsClient, kubeClient)
+	if err != nil {
+		e = err
+		return err
+	}
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+	if err != nil {
+		e = err
+		return err
+	}
+	jwk, err := factory.IdPDiscovery().PublishedJWK(ctx, factory.IdPDiscoveryContents(issuerMeta))
+	if err != nil {
+		e = err
+		return err
+	}
+	published := []string{}
+	if jwk != nil {
+		published = jwk.KeyIDs()
+	}
+	slices.Sort(expected)
+	slices.Sort(published)
+	if !slices.Equal(expected, published) {
+		*obj = irsav1alpha1.SetupStatusSigningKeySynced(*obj, metav1.ConditionFalse, string(irsav1alpha1.SelfHostedReasonSigningKeyMismatch),
+			fmt.Sprintf("the published JWKS has the key IDs %v, but the signing keys have the key IDs %v", published, expected))
+		return nil
+	}
+	*obj = irsav1alpha1.SetupStatusSigningKeySynced(*obj, metav1.ConditionTrue, string(irsav1alpha1.SelfHostedReasonSigningKeySynced), "the published JWKS matches the signing keys")
+	return nil
+}
+
+// loadExternalKeys reads the public keys of a signing key that is managed outside of irsa-manager, and returns the JWKS publishing them.
+// The key can be either a private key, a public key bundle or the JWKS served by the kube-apiserver, which is published unchanged.
+func loadExternalKeys(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient, apiServer *selfhosted.APIServerDiscovery) ([]byte, *selfhosted.JWK, error) {
+	signingKey := obj.Spec.SigningKey
+	var data []byte
+	var location string
+	switch signingKey.KeySource() {
+	case irsav1alpha1.SigningKeySourceAPIServer:
+		if apiServer == nil {
+			return nil, nil, errors.New("the kube-apiserver client is not configured")
+		}
+		issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		apiServerIssuer, err := apiServer.Issuer(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if apiServerIssuer != issuerMeta.IssuerUrl() {
+			return nil, nil, fmt.Errorf("the kube-apiserver issuer %q does not match %q, set it as the first --service-account-issuer", apiServerIssuer, issuerMeta.IssuerUrl())
+		}
+		jwk, err := apiServer.JWK(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		pubs, err := jwk.PublicKeys()
+		if err != nil {
+			return nil, nil, err
+		}
+		return pubs, jwk, nil
+	case irsav1alpha1.SigningKeySourceSecret:
+		ref := signingKey.SecretRef
+		if ref == nil {
+			return nil, nil, errors.New("signingKey.secretRef is required when the signing key source is Secret")
+		}
+		location = fmt.Sprintf("key %q of Secret %s/%s", ref.SecretKey(), ref.Namespace, ref.Name)
+		secret, err := getSecret(ctx, kubeClient, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read the signing key from %s: %w", location, err)
+		}
+		var ok bool
+		data, ok = secret.Data[ref.SecretKey()]
+		if !ok {
+			return nil, nil, fmt.Errorf("failed to read the signing key from %s: the key does not exist", location)
+		}
+	case irsav1alpha1.SigningKeySourceFile:
+		if signingKey.File == nil || signingKey.File.Path == "" {
+			return nil, nil, errors.New("signingKey.file.path is required when the signing key source is File")
+		}
+		location = fmt.Sprintf("file %s", signingKey.File.Path)
+		var err error
+		data, err = os.ReadFile(signingKey.File.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read the signing key from %s: %w", location, err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported signing key source: %s", signingKey.KeySource())
+	}
+	pubs, err := selfhosted.ParsePublicKeys(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the signing key from %s: %w", location, err)
+	}
+	jwk, err := selfhosted.NewJWK(pubs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pubs, jwk, nil
+}
+
+// reconcileExternalSigningKey republishes the JWKS when the signing key managed outside of irsa-manager has changed.
+func reconcileExternalSigningKey(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient, apiServer *selfhosted.APIServerDiscovery, now time.Time) error {
+	log := ctrllog.FromContext(ctx)
+
+	// e is set only when an error occurs in an external dependency process and is reflected in the CRs status
+	var e error
+	var reason irsav1alpha1.SelfhostedConditionReason
+	defer func() {
+		if e != nil {
+			*obj = irsav1alpha1.StatusNotReady(*obj, string(reason), e.Error())
+		}
+	}()
+
+	pubs, jwk, err := loadExternalKeys(ctx, obj, kubeClient, apiServer)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonFailedSigningKey
+		return err
+	}
+	kids, err := selfhosted.PublicKeyIDs(pubs)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonFailedSigningKey
+		return err
+	}
+	published := make([]string, 0, len(obj.Status.SigningKeys))
+	for _, key := range obj.Status.SigningKeys {
+		published = append(published, key.KeyID)
+	}
+	if slices.Equal(kids, published) {
+		return nil
+	}
+	factory, err := newOIDCIdpFactory(ctx, obj, jwk, awsClient, kubeClient)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonFailedOidc
+		return err
+	}
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+	if err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonFailedIssuer
+		return err
+	}
+	if err := selfhosted.Publish(ctx, factory, issuerMeta); err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonFailedOidc
+		return err
+	}
+	if err := syncSigningKeyStatus(&obj.Status, pubs, "", obj.Spec.SigningKey.KeySource(), metav1.NewTime(now), now); err != nil {
+		e = err
+		reason = irsav1alpha1.SelfHostedReasonFailedSigningKey
+		return err
+	}
+	log.Info("the external signing keys have been republished", "keyIDs", kids)
+	return nil
+}
+
+// externalSigningKeyResyncPeriod returns the period after which a signing key managed outside of irsa-manager is checked for changes.
+// Only the JWKS of the kube-apiserver is polled, as the other sources are picked up by the regular reconciliation.
+func externalSigningKeyResyncPeriod(signingKey irsav1alpha1.SigningKey) time.Duration {
+	if signingKey.KeySource() == irsav1alpha1.SigningKeySourceAPIServer {
+		return apiServerResyncPeriod
+	}
+	return 0
+}
+
+// getSecret reads the Secret with the given name.
+func getSecret(ctx context.Context, kubeClient *kubernetes.KubernetesClient, namespacedName types.NamespacedName) (*corev1.Secret, error) {
+	u, err := kubeClient.Get(ctx, &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namespacedName.Name,
+			Namespace: namespacedName.Namespace,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, secret); err != nil {
+		return nil, fmt.Errorf("error converting to Secret for %s: %v", u.GetName(), err)
+	}
+	return secret, nil
+}
+
+// syncGeneratedSigningKeyStatus aligns the signing key status with the signing keys generated by irsa-manager.
+func syncGeneratedSigningKeyStatus(status *irsav1alpha1.IRSASetupStatus, keys *selfhosted.SigningKeys, createdAt metav1.Time, now time.Time) error {
+	pendingKeyID, err := keys.PendingKeyID()
+	if err != nil {
+		return err
+	}
+	return syncSigningKeyStatus(status, keys.PublicKeys(), pendingKeyID, irsav1alpha1.SigningKeySourceGenerated, createdAt, now)
+}
+
+// syncSigningKeyStatus aligns the signing key status with the PEM encoded public keys of the given key source.
+// Keys that are not yet tracked are added using createdAt, except for the pending key, whose propagation starts now.
+// For a generated signing key, the first key is active, the key with pendingKeyID is pending and the others are retiring since now.
+// irsa-manager cannot tell which key of an external signing key is used for signing, so every such key is reported as active.
+func syncSigningKeyStatus(status *irsav1alpha1.IRSASetupStatus, pubs []byte, pendingKeyID string, source irsav1alpha1.SigningKeySource, createdAt metav1.Time, now time.Time) error {
+	kids, err := selfhosted.PublicKeyIDs(pubs)
+	if err != nil {
+		return err
+	}
+	algorithms, err := selfhosted.PublicKeyAlgorithms(pubs)
+	if err != nil {
+		return err
+	}
+	synced := make([]irsav1alpha1.SigningKeyStatus, 0, len(kids))
+	generation := status.LatestSigningKeyGeneration()
+	for i, kid := range kids {
+		index := slices.IndexFunc(status.SigningKeys, func(key irsav1alpha1.SigningKeyStatus) bool {
+			return key.KeyID == kid
+		})
+		var key irsav1alpha1.SigningKeyStatus
+		if index != -1 {
+			key = status.SigningKeys[index]
+		} else {
+			generation++
+			key = irsav1alpha1.SigningKeyStatus{
+				KeyID:      kid,
+				Generation: generation,
+				CreatedAt:  createdAt,
+			}
+			if kid == pendingKeyID {
+				key.CreatedAt = metav1.NewTime(now)
+			}
+		}
+		key.Algorithm = algorithms[i]
+		if i == 0 || source != irsav1alpha1.SigningKeySourceGenerated {
+			key.State = irsav1alpha1.SigningKeyActive
+			key.RetiredAt = nil
+		} else if kid == pendingKeyID {
+			key.State = irsav1alpha1.SigningKeyPending
+			key.RetiredAt = nil
+		} else if key.State != irsav1alpha1.SigningKeyRetiring || key.RetiredAt == nil {
+			retiredAt := metav1.NewTime(now)
+			key.State = irsav1alpha1.SigningKeyRetiring
+			key.RetiredAt = &retiredAt
+		}
+		synced = append(synced, key)
+	}
+	status.SigningKeys = synced
+	return nil
+}
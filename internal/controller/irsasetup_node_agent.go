@@ -0,0 +1,189 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/kkb0318/irsa-manager/internal/handler"
+	"github.com/kkb0318/irsa-manager/internal/issuer"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+	"github.com/kkb0318/irsa-manager/internal/manifests"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted/nodeagent"
+)
+
+// nodeAgentRequeueAfter is the period until the progress of the node agents is read again while they are not all configured.
+const nodeAgentRequeueAfter = 30 * time.Second
+
+// reconcileNodeAgent deploys the node agents when NodeAgent is set, removes them otherwise,
+// and reports their progress in the status.
+// It returns the period until the progress has to be read again.
+func (r *IRSASetupReconciler) reconcileNodeAgent(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) (time.Duration, error) {
+	spec := obj.Spec.NodeAgent
+	if spec == nil {
+		obj.Status.NodeAgents = nil
+		return 0, r.deleteNodeAgent(ctx, obj, kubeClient)
+	}
+	owner, err := r.nodeAgentOwner(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if owner != "" && owner != nodeAgentOwnerName(obj) {
+		obj.Status.NodeAgents = nil
+		return 0, fmt.Errorf("the node agents are already deployed by the IRSASetup %s, only one IRSASetup of the cluster can set nodeAgent", owner)
+	}
+	opts, err := r.nodeAgentOptions(obj)
+	if err != nil {
+		return 0, err
+	}
+	kubeHandler := handler.NewKubernetesHandler(kubeClient)
+	for _, r := range nodeagent.NewNodeAgentSetup(opts).Resources() {
+		kubeHandler.Append(r)
+	}
+	if _, err := kubeHandler.ApplyAll(ctx); err != nil {
+		return 0, err
+	}
+	statuses, err := r.nodeAgentStatuses(ctx)
+	if err != nil {
+		return 0, err
+	}
+	obj.Status.NodeAgents = statuses
+	if len(statuses) == 0 {
+		return nodeAgentRequeueAfter, nil
+	}
+	for _, status := range statuses {
+		if !status.Configured {
+			return nodeAgentRequeueAfter, nil
+		}
+	}
+	return 0, nil
+}
+
+func (r *IRSASetupReconciler) nodeAgentOptions(obj *irsav1alpha1.IRSASetup) (nodeagent.Options, error) {
+	spec := obj.Spec.NodeAgent
+	image := spec.Image
+	if image == "" {
+		image = r.NodeAgentImage
+	}
+	if image == "" {
+		return nodeagent.Options{}, fmt.Errorf("the image of the node agent is not set, set nodeAgent.image or the --node-agent-image flag of the controller")
+	}
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+	if err != nil {
+		return nodeagent.Options{}, err
+	}
+	withKeyFiles := !obj.Spec.SigningKey.IsExternal()
+	config := selfhosted.NewAPIServerConfig(issuerMeta.IssuerUrl(), obj.ClientIDs(), withKeyFiles)
+	opts := nodeagent.Options{
+		Image:          image,
+		KeyDir:         spec.GetKeyDir(),
+		ManifestPath:   spec.GetManifestPath(),
+		BackupDir:      spec.GetBackupDir(),
+		NodeSelector:   spec.GetNodeSelector(),
+		APIServerFlags: config.FlagsWithKeyDir(spec.GetKeyDir()),
+		Owner:          nodeAgentOwnerName(obj),
+	}
+	if withKeyFiles {
+		opts.KeySecretName = manifests.SshKeyNamespacedName().Name
+	}
+	return opts, nil
+}
+
+// nodeAgentStatuses reads the progress reported by the node agents in their status ConfigMap.
+// Only the nodes running a node agent Pod are reported, so that the removed nodes are left out.
+func (r *IRSASetupReconciler) nodeAgentStatuses(ctx context.Context) ([]irsav1alpha1.NodeAgentStatus, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(nodeagent.NODE_AGENT_NAMESPACE), client.MatchingLabels(nodeagent.PodLabel)); err != nil {
+		return nil, err
+	}
+	reports := &corev1.ConfigMap{}
+	if err := r.Get(ctx, nodeagent.StatusConfigMapName, reports); err != nil {
+		return nil, err
+	}
+	statuses := []irsav1alpha1.NodeAgentStatus{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		status := irsav1alpha1.NodeAgentStatus{NodeName: pod.Spec.NodeName}
+		report, err := nodeagent.ParseStatus(reports, pod.Spec.NodeName)
+		switch {
+		case err != nil:
+			status.Message = fmt.Sprintf("failed to parse the progress of the node agent: %s", err)
+		case report == nil:
+			status.Message = "waiting for the node agent to report its progress"
+		default:
+			status.Configured = report.Configured
+			status.Message = report.Message
+			status.LastUpdateTime = report.LastUpdateTime.DeepCopy()
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].NodeName < statuses[j].NodeName
+	})
+	return statuses, nil
+}
+
+// nodeAgentOwnerName returns the owner of the node agents deployed by the IRSASetup.
+func nodeAgentOwnerName(obj *irsav1alpha1.IRSASetup) string {
+	return client.ObjectKeyFromObject(obj).String()
+}
+
+// nodeAgentOwner returns the IRSASetup that deployed the node agents,
+// or an empty string when they are not deployed or have been deployed before the owner was recorded.
+func (r *IRSASetupReconciler) nodeAgentOwner(ctx context.Context) (string, error) {
+	ds := &appsv1.DaemonSet{}
+	err := r.Get(ctx, types.NamespacedName{Name: nodeagent.NODE_AGENT_NAME, Namespace: nodeagent.NODE_AGENT_NAMESPACE}, ds)
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return ds.Annotations[nodeagent.OwnerAnnotation], nil
+}
+
+// deleteNodeAgent removes the node agents when they have been deployed by the IRSASetup.
+// The key files and the flags set on the nodes are left as they are.
+func (r *IRSASetupReconciler) deleteNodeAgent(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) error {
+	owner, err := r.nodeAgentOwner(ctx)
+	if err != nil {
+		return err
+	}
+	if owner != nodeAgentOwnerName(obj) {
+		return nil
+	}
+	kubeHandler := handler.NewKubernetesHandler(kubeClient)
+	for _, r := range nodeagent.NewNodeAgentSetup(nodeagent.Options{}).Resources() {
+		kubeHandler.Append(r)
+	}
+	_, err = kubeHandler.DeleteAll(ctx)
+	return err
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
+	"github.com/kkb0318/irsa-manager/internal/issuer"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted/oidc"
+)
+
+// replicaRetryPeriod is the period after which the replicas that could not be synced are synced again.
+const replicaRetryPeriod = time.Minute
+
+// replicas returns the replica buckets of the discovery documents. Only documents stored in S3 are replicated.
+func replicas(obj *irsav1alpha1.IRSASetup) []irsav1alpha1.S3Replica {
+	if obj.Spec.Discovery.InCluster != nil {
+		return nil
+	}
+	return obj.Spec.Discovery.S3.Replicas
+}
+
+// reconcileReplicas replicates the discovery documents to the replica buckets and records their publish state in the status.
+// The documents are derived from the signing keys like the ones in the bucket, and are only uploaded when they differ.
+// A replica that cannot be synced, e.g. because its region is unavailable, does not fail the reconciliation,
+// but the period after which it is synced again is returned.
+func reconcileReplicas(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient, apiServer *selfhosted.APIServerDiscovery, now time.Time) time.Duration {
+	log := ctrllog.FromContext(ctx)
+	if len(replicas(obj)) == 0 {
+		obj.Status.Replicas = nil
+		return 0
+	}
+	contents, err := replicaContents(ctx, obj, kubeClient, apiServer)
+	statuses := []irsav1alpha1.ReplicaStatus{}
+	var requeueAfter time.Duration
+	for _, replica := range replicas(obj) {
+		status := irsav1alpha1.ReplicaStatus{
+			Region:         replica.Region,
+			BucketName:     replica.BucketName,
+			LastSyncedTime: lastReplicaSyncedTime(obj.Status.Replicas, replica),
+		}
+		syncErr := err
+		if syncErr == nil {
+			syncErr = syncReplica(ctx, obj, replica, contents, awsClient, kubeClient)
+		}
+		if syncErr != nil {
+			log.Error(syncErr, "failed to sync the replica", "region", replica.Region, "bucketName", replica.BucketName)
+			status.Message = syncErr.Error()
+			requeueAfter = replicaRetryPeriod
+		} else {
+			status.Synced = true
+			status.LastSyncedTime = &metav1.Time{Time: now}
+		}
+		statuses = append(statuses, status)
+	}
+	obj.Status.Replicas = statuses
+	return requeueAfter
+}
+
+// syncReplica sets up the replica bucket and uploads the discovery documents when they are missing or differ.
+func syncReplica(ctx context.Context, obj *irsav1alpha1.IRSASetup, replica irsav1alpha1.S3Replica, contents selfhosted.OIDCIdPDiscoveryContents, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient) error {
+	discovery, err := newReplicaDiscovery(ctx, obj, replica, awsClient, kubeClient)
+	if err != nil {
+		return err
+	}
+	if update, err := discovery.IsUpdate(ctx, contents); err == nil && !update {
+		return nil
+	}
+	// the bucket may not exist yet when the documents cannot be read, so it is set up again
+	if err := discovery.CreateStorage(ctx); err != nil {
+		return err
+	}
+	return discovery.Upload(ctx, contents, true)
+}
+
+// deleteReplicas deletes the discovery documents in the replica buckets, and the buckets once they are empty.
+func deleteReplicas(ctx context.Context, obj *irsav1alpha1.IRSASetup, issuerMeta issuer.OIDCIssuerMeta, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient) error {
+	contents := oidc.NewIdPDiscoveryContents(nil, issuerMeta, jwksFileName)
+	for _, replica := range replicas(obj) {
+		discovery, err := newReplicaDiscovery(ctx, obj, replica, awsClient, kubeClient)
+		if err != nil {
+			return err
+		}
+		if err := discovery.Delete(ctx, contents); err != nil {
+			return fmt.Errorf("unable to delete the replica in %s, %w", replica.Region, err)
+		}
+	}
+	return nil
+}
+
+// replicaContents returns the discovery documents to replicate, which are derived from the signing keys.
+func replicaContents(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient, apiServer *selfhosted.APIServerDiscovery) (selfhosted.OIDCIdPDiscoveryContents, error) {
+	_, jwk, err := expectedKeys(ctx, obj, kubeClient, apiServer)
+	if err != nil {
+		return nil, err
+	}
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+	if err != nil {
+		return nil, err
+	}
+	return oidc.NewIdPDiscoveryContents(jwk, issuerMeta, jwksFileName), nil
+}
+
+func newReplicaDiscovery(ctx context.Context, obj *irsav1alpha1.IRSASetup, replica irsav1alpha1.S3Replica, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient) (*oidc.S3IdPDiscovery, error) {
+	s3 := obj.Spec.Discovery.S3.ForReplica(replica)
+	s3Options, err := newS3Options(ctx, s3, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	return oidc.NewS3IdPDiscovery(awsClient, s3, nil, string(obj.UID), jwksFileName, s3Options), nil
+}
+
+// lastReplicaSyncedTime returns the time the replica was last synced, or nil if it has never been synced.
+func lastReplicaSyncedTime(statuses []irsav1alpha1.ReplicaStatus, replica irsav1alpha1.S3Replica) *metav1.Time {
+	for _, status := range statuses {
+		if status.Region == replica.Region && status.BucketName == replica.BucketName {
+			return status.LastSyncedTime
+		}
+	}
+	return nil
+}
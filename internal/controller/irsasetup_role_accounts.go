@@ -0,0 +1,115 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/kkb0318/irsa-manager/internal/issuer"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+)
+
+// reconcileRoleAccountOIDCProviders records the IAM OIDC providers the IRSAs bound to the IRSASetup use in the accounts of their deployer roles,
+// and deletes the recorded ones that no IRSA uses any longer when Cleanup is enabled.
+// The providers are created by the IRSAs, which is why only their accounts are recorded here.
+func (r *IRSASetupReconciler) reconcileRoleAccountOIDCProviders(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) error {
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+	if err != nil {
+		return err
+	}
+	irsas, err := boundIRSAs(ctx, obj, kubeClient)
+	if err != nil {
+		return err
+	}
+	used := []irsav1alpha1.RoleAccountOIDCProviderStatus{}
+	for _, irsa := range irsas {
+		role := irsa.Spec.IamRole
+		if role.DeployerRoleArn == "" || !irsa.DeletionTimestamp.IsZero() {
+			continue
+		}
+		roleArn, err := arn.Parse(role.DeployerRoleArn)
+		if err != nil {
+			return fmt.Errorf("invalid deployer role ARN %q of the IRSA %s/%s, %w", role.DeployerRoleArn, irsa.Namespace, irsa.Name, err)
+		}
+		provider := irsav1alpha1.RoleAccountOIDCProviderStatus{
+			AccountID:       roleArn.AccountID,
+			IssuerHostPath:  issuerMeta.IssuerHostPath(),
+			DeployerRoleArn: role.DeployerRoleArn,
+			ExternalID:      role.ExternalID,
+		}
+		if !slices.ContainsFunc(used, provider.SameProvider) {
+			used = append(used, provider)
+		}
+	}
+	stale := slices.DeleteFunc(slices.Clone(obj.Status.RoleAccountOIDCProviders), func(provider irsav1alpha1.RoleAccountOIDCProviderStatus) bool {
+		return slices.ContainsFunc(used, provider.SameProvider)
+	})
+	for i, provider := range stale {
+		if err := r.deleteRoleAccountOIDCProvider(ctx, obj, provider, kubeClient); err != nil {
+			// the providers that have not been deleted are kept in the status, so that their deletion is retried
+			obj.Status.RoleAccountOIDCProviders = sortedRoleAccountOIDCProviders(append(used, stale[i:]...))
+			return err
+		}
+	}
+	obj.Status.RoleAccountOIDCProviders = sortedRoleAccountOIDCProviders(used)
+	return nil
+}
+
+// deleteRoleAccountOIDCProviders deletes all the recorded IAM OIDC providers in the accounts of the deployer roles.
+func (r *IRSASetupReconciler) deleteRoleAccountOIDCProviders(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) error {
+	for len(obj.Status.RoleAccountOIDCProviders) > 0 {
+		if err := r.deleteRoleAccountOIDCProvider(ctx, obj, obj.Status.RoleAccountOIDCProviders[0], kubeClient); err != nil {
+			return err
+		}
+		obj.Status.RoleAccountOIDCProviders = obj.Status.RoleAccountOIDCProviders[1:]
+	}
+	return nil
+}
+
+// deleteRoleAccountOIDCProvider deletes an IAM OIDC provider with the deployer role it has been recorded with, when Cleanup is enabled.
+func (r *IRSASetupReconciler) deleteRoleAccountOIDCProvider(ctx context.Context, obj *irsav1alpha1.IRSASetup, provider irsav1alpha1.RoleAccountOIDCProviderStatus, kubeClient *kubernetes.KubernetesClient) error {
+	if !obj.Spec.Cleanup {
+		return nil
+	}
+	role := irsav1alpha1.IamRole{DeployerRoleArn: provider.DeployerRoleArn, ExternalID: provider.ExternalID}
+	awsClient, err := awsClientForRole(ctx, obj, role, r.AwsClient, r.AwsClientCache, kubeClient)
+	if err != nil {
+		return err
+	}
+	if err := awsClient.IamClient().DeleteOIDCProvider(ctx, provider.AccountID, provider.IssuerHostPath); err != nil {
+		return fmt.Errorf("unable to delete the IAM OIDC provider in the account %s, %w", provider.AccountID, err)
+	}
+	ctrllog.FromContext(ctx).Info("deleted the IAM OIDC provider no IRSA uses", "account", provider.AccountID, "issuer", provider.IssuerHostPath)
+	return nil
+}
+
+func sortedRoleAccountOIDCProviders(providers []irsav1alpha1.RoleAccountOIDCProviderStatus) []irsav1alpha1.RoleAccountOIDCProviderStatus {
+	slices.SortFunc(providers, func(a, b irsav1alpha1.RoleAccountOIDCProviderStatus) int {
+		if c := strings.Compare(a.AccountID, b.AccountID); c != 0 {
+			return c
+		}
+		return strings.Compare(a.IssuerHostPath, b.IssuerHostPath)
+	})
+	return providers
+}
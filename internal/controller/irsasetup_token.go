@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
+	"github.com/kkb0318/irsa-manager/internal/handler"
+	"github.com/kkb0318/irsa-manager/internal/issuer"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+	"github.com/kkb0318/irsa-manager/internal/manifests"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+)
+
+// tokenIssuanceRequeueAfter is the period until the token issuance is verified again while it fails,
+// e.g. until the kube-apiserver has been configured for the issuer.
+const tokenIssuanceRequeueAfter = time.Minute
+
+// tokenIssuanceVerifiedRequeueAfter is the period until the token issuance is verified again once it succeeds,
+// so that a kube-apiserver that is configured for another issuer or signing key afterwards is reported.
+const tokenIssuanceVerifiedRequeueAfter = 10 * time.Minute
+
+// tokenProbeExpirationSeconds is the lifetime of the probe tokens, the shortest one accepted by the TokenRequest API.
+const tokenProbeExpirationSeconds = int64(600)
+
+// tokenProbeServiceAccountName returns the ServiceAccount for which the probe tokens are requested.
+func tokenProbeServiceAccountName(obj *irsav1alpha1.IRSASetup) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      obj.Name + "-token-probe",
+		Namespace: obj.Namespace,
+	}
+}
+
+var tokenVerificationReasons = map[selfhosted.TokenVerificationFailure]irsav1alpha1.SelfhostedConditionReason{
+	selfhosted.TokenMalformed:      irsav1alpha1.SelfHostedReasonTokenMalformed,
+	selfhosted.TokenIssuerMismatch: irsav1alpha1.SelfHostedReasonTokenIssuerMismatch,
+	selfhosted.TokenUnknownKeyID:   irsav1alpha1.SelfHostedReasonTokenUnknownKeyID,
+	selfhosted.TokenBadSignature:   irsav1alpha1.SelfHostedReasonTokenBadSignature,
+}
+
+// reconcileTokenIssuance sets the TokenIssuanceVerified condition according to whether a token requested
+// from the kube-apiserver is issued for the issuer and signed with a key of the published JWKS.
+// It returns the period until the token issuance has to be verified again.
+func (r *IRSASetupReconciler) reconcileTokenIssuance(ctx context.Context, obj *irsav1alpha1.IRSASetup, awsClient awsclient.AwsClient, kubeClient *kubernetes.KubernetesClient) (time.Duration, error) {
+	// e is set only when an error occurs in an external dependency process and is reflected in the CRs status
+	var e error
+	defer func() {
+		if e != nil {
+			*obj = irsav1alpha1.SetupStatusTokenIssuanceVerified(*obj, metav1.ConditionFalse, string(irsav1alpha1.SelfHostedReasonFailedTokenIssuanceCheck), e.Error())
+		}
+	}()
+
+	issuerMeta, err := issuer.NewOIDCIssuerMeta(obj)
+	if err != nil {
+		e = err
+		return 0, err
+	}
+	factory, err := newOIDCIdpFactory(ctx, obj, nil, awsClient, kubeClient)
+	if err != nil {
+		e = err
+		return 0, err
+	}
+	jwk, err := factory.IdPDiscovery().PublishedJWK(ctx, factory.IdPDiscoveryContents(issuerMeta))
+	if err != nil {
+		e = err
+		return 0, err
+	}
+	token, err := r.requestProbeToken(ctx, obj, kubeClient)
+	if err != nil {
+		e = err
+		return 0, err
+	}
+	err = selfhosted.VerifyToken(token, issuerMeta.IssuerUrl(), jwk)
+	var verificationErr *selfhosted.TokenVerificationError
+	if errors.As(err, &verificationErr) {
+		*obj = irsav1alpha1.SetupStatusTokenIssuanceVerified(*obj, metav1.ConditionFalse, string(tokenVerificationReasons[verificationErr.Failure]), verificationErr.Message)
+		return tokenIssuanceRequeueAfter, nil
+	}
+	if err != nil {
+		e = err
+		return 0, err
+	}
+	*obj = irsav1alpha1.SetupStatusTokenIssuanceVerified(*obj, metav1.ConditionTrue, string(irsav1alpha1.SelfHostedReasonTokenIssuanceVerified),
+		"the kube-apiserver issues tokens that can be validated against the published discovery documents")
+	return tokenIssuanceVerifiedRequeueAfter, nil
+}
+
+// requestProbeToken requests a token for the probe ServiceAccount through the TokenRequest API.
+func (r *IRSASetupReconciler) requestProbeToken(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) (string, error) {
+	namespacedName := tokenProbeServiceAccountName(obj)
+	sa := manifests.NewServiceAccountBuilder().Build(namespacedName)
+	kubeHandler := handler.NewKubernetesHandler(kubeClient)
+	kubeHandler.Append(sa)
+	if _, err := kubeHandler.ApplyAll(ctx); err != nil {
+		return "", err
+	}
+	expirationSeconds := tokenProbeExpirationSeconds
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         []string{obj.DefaultAudience()},
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}
+	if err := r.SubResource("token").Create(ctx, sa, tokenRequest); err != nil {
+		return "", fmt.Errorf("failed to request a token for the ServiceAccount %s: %w", namespacedName, err)
+	}
+	return tokenRequest.Status.Token, nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/kkb0318/irsa-manager/internal/handler"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted/webhook"
+)
+
+// webhookOptions returns the options of the webhook Deployment configured by the IRSASetup.
+func webhookOptions(obj *irsav1alpha1.IRSASetup) webhook.Options {
+	options := webhook.Options{
+		Namespace:       obj.Spec.Webhook.GetNamespace(),
+		Image:           obj.Spec.Webhook.GetImage(),
+		ImagePullPolicy: obj.Spec.Webhook.GetImagePullPolicy(),
+		Replicas:        obj.Spec.Webhook.GetReplicas(),
+	}
+	if spec := obj.Spec.Webhook; spec != nil {
+		options.ImagePullSecrets = spec.ImagePullSecrets
+		options.Resources = spec.Resources
+		options.NodeSelector = spec.NodeSelector
+		options.Tolerations = spec.Tolerations
+		options.Affinity = spec.Affinity
+		options.PriorityClassName = spec.PriorityClassName
+	}
+	return options
+}
+
+// deployedWebhookNamespace returns the namespace the webhook has been deployed in, or an empty string when it has not been deployed.
+// Webhooks deployed before the namespace was recorded in the status live in the default namespace.
+func deployedWebhookNamespace(obj *irsav1alpha1.IRSASetup) string {
+	if obj.Status.Webhook == nil {
+		return ""
+	}
+	if obj.Status.Webhook.Namespace == "" {
+		return irsav1alpha1.DefaultWebhookNamespace
+	}
+	return obj.Status.Webhook.Namespace
+}
+
+// applyWebhook applies the webhook resources when force is set, when the IRSASetup has changed since they have last been applied,
+// or when the deployed webhook does not serve the certificate obtained from the configured source.
+// The Secret, the Deployment and the CA bundle of the MutatingWebhookConfiguration are applied together, and a replaced
+// self-signed certificate stays in the CA bundle, so that Pod admission keeps working while the webhook Pods are rolled.
+// The resources left in the previous namespace are removed once the webhook has been moved to another one,
+// and the webhook is removed when it is disabled.
+func applyWebhook(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient, now time.Time, force bool) error {
+	if !obj.Spec.Webhook.IsEnabled() {
+		return deleteDisabledWebhook(ctx, obj, kubeClient)
+	}
+	previousNamespace := deployedWebhookNamespace(obj)
+	previousCertManager := obj.Status.Webhook != nil && obj.Status.Webhook.CertificateSource == irsav1alpha1.WebhookCertificateCertManager
+	namespace := obj.Spec.Webhook.GetNamespace()
+	webhookSetup, changed, err := newWebhookSetup(ctx, obj, kubeClient, now)
+	if err != nil {
+		return err
+	}
+	changed = changed || force
+	if !changed {
+		changed, err = webhookCertificateNotServed(ctx, kubeClient, webhookSetup)
+		if err != nil {
+			return err
+		}
+	}
+	kubeHandler := handler.NewKubernetesHandler(kubeClient)
+	for _, r := range webhookSetup.Resources() {
+		// the cert-manager resources are always applied, so that a changed issuer is taken into account
+		if changed || r.GetObjectKind().GroupVersionKind().Group == webhook.CertificateGVK.Group {
+			kubeHandler.Append(r)
+		}
+	}
+	if _, err := kubeHandler.ApplyAll(ctx); err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	kubeHandler = handler.NewKubernetesHandler(kubeClient)
+	if obj.Spec.Webhook.GetReplicas() <= 1 {
+		kubeHandler.Append(webhook.PodDisruptionBudget(namespace))
+	}
+	if previousNamespace != "" && previousNamespace != namespace {
+		// the cluster-scoped resources have been applied again, so only the namespaced ones are removed
+		resources, err := webhook.NamespacedResources(previousNamespace)
+		if err != nil {
+			return err
+		}
+		for _, r := range resources {
+			kubeHandler.Append(r)
+		}
+		ctrllog.FromContext(ctx).Info("the webhook has been moved to another namespace", "from", previousNamespace, "to", namespace)
+	}
+	if _, err := kubeHandler.DeleteAll(ctx); err != nil {
+		return err
+	}
+	if previousNamespace != "" && previousNamespace != namespace && previousCertManager {
+		return deleteCertManagerResources(ctx, kubeClient, previousNamespace)
+	}
+	return nil
+}
+
+// reconcileWebhook applies the webhook resources when the webhook setup has changed or the certificate is not served yet.
+// It returns the period until the certificate has to be read again.
+func reconcileWebhook(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient, now time.Time) (time.Duration, error) {
+	if err := applyWebhook(ctx, obj, kubeClient, now, false); err != nil {
+		return 0, err
+	}
+	return webhookCertificateRequeueAfter(obj, now), nil
+}
+
+// deleteDisabledWebhook removes the webhook deployed before it was disabled, and clears its status.
+// Nothing is removed when the webhook has never been deployed, so that a webhook run next to irsa-manager is left untouched.
+func deleteDisabledWebhook(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient) error {
+	namespace := deployedWebhookNamespace(obj)
+	if namespace == "" {
+		return nil
+	}
+	certManager := obj.Status.Webhook.CertificateSource == irsav1alpha1.WebhookCertificateCertManager
+	if err := deleteWebhook(ctx, kubeClient, namespace, certManager); err != nil {
+		return err
+	}
+	ctrllog.FromContext(ctx).Info("the webhook has been disabled and removed", "namespace", namespace)
+	obj.Status.Webhook = nil
+	return nil
+}
+
+// deleteWebhook removes the webhook resources deployed in the namespace, including the cert-manager resources when certManager is set.
+func deleteWebhook(ctx context.Context, kubeClient *kubernetes.KubernetesClient, namespace string, certManager bool) error {
+	webhookSetup, err := webhook.NewWebHookSetup(webhook.ServingCertificate{}, webhook.Options{Namespace: namespace})
+	if err != nil {
+		return err
+	}
+	kubeHandler := handler.NewKubernetesHandler(kubeClient)
+	for _, r := range webhookSetup.Resources() {
+		kubeHandler.Append(r)
+	}
+	kubeHandler.Append(webhook.PodDisruptionBudget(namespace))
+	if _, err := kubeHandler.DeleteAll(ctx); err != nil {
+		return err
+	}
+	if certManager {
+		return deleteCertManagerResources(ctx, kubeClient, namespace)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	regv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/kkb0318/irsa-manager/internal/handler"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted/webhook"
+)
+
+// webhookCertificateRenewBefore is the period before its expiry from which the serving certificate of the webhook is renewed.
+// Certificates issued for less than 90 days are renewed once two thirds of their lifetime have passed.
+const webhookCertificateRenewBefore = 30 * 24 * time.Hour
+
+// webhookCertificatePendingRequeueAfter is the period until the serving certificate of the webhook is read again
+// while it is being issued by cert-manager or a signer.
+const webhookCertificatePendingRequeueAfter = 30 * time.Second
+
+// webhookCertificateState is the serving certificate of the webhook obtained from the configured source.
+type webhookCertificateState struct {
+	serving webhook.ServingCertificate
+	// notAfter and renewalTime are zero while no certificate has been issued
+	notAfter    time.Time
+	renewalTime time.Time
+	// message describes why the certificate has not been issued yet
+	message string
+	// renewed reports whether a certificate has been issued that is not stored in the webhook Secret yet
+	renewed bool
+}
+
+// webhookRenewalTime returns the time from which the certificate is renewed.
+func webhookRenewalTime(cred webhook.TlsCredential) time.Time {
+	renewBefore := webhookCertificateRenewBefore
+	if lifetime := cred.NotAfter().Sub(cred.NotBefore()); lifetime/3 < renewBefore {
+		renewBefore = lifetime / 3
+	}
+	return cred.NotAfter().Add(-renewBefore)
+}
+
+// issued returns the state of an issued certificate.
+func issued(serving webhook.ServingCertificate, renewed bool) webhookCertificateState {
+	return webhookCertificateState{
+		serving:     serving,
+		notAfter:    serving.Credential.NotAfter(),
+		renewalTime: webhookRenewalTime(serving.Credential),
+		renewed:     renewed,
+	}
+}
+
+// loadWebhookCredential reads the webhook Secret in the namespace and the serving certificate it holds.
+// The Secret is nil when it does not exist, and the certificate is nil when it cannot be parsed.
+func loadWebhookCredential(ctx context.Context, kubeClient *kubernetes.KubernetesClient, namespace string) (*corev1.Secret, *webhook.TlsCredential, error) {
+	secret, err := getSecret(ctx, kubeClient, webhook.SecretNamespacedName(namespace))
+	if apierrors.IsNotFound(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	cred, err := webhook.ParseTlsCredential(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		ctrllog.FromContext(ctx).Info("the serving certificate of the webhook cannot be parsed", "error", err.Error())
+		return secret, nil, nil
+	}
+	return secret, &cred, nil
+}
+
+// webhookCertificate obtains the serving certificate of the webhook from the configured source.
+// The certificate of the webhook Secret is kept until it is due for renewal, unless the source has changed.
+func webhookCertificate(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient, now time.Time) (webhookCertificateState, error) {
+	secret, current, err := loadWebhookCredential(ctx, kubeClient, obj.Spec.Webhook.GetNamespace())
+	if err != nil {
+		return webhookCertificateState{}, err
+	}
+	source := obj.Spec.Webhook.GetCertificate().GetSource()
+	if webhookCertificateSourceChanged(obj) {
+		ctrllog.FromContext(ctx).Info("the source of the serving certificate of the webhook has changed, a new certificate is issued", "source", source)
+		current = nil
+	}
+	switch source {
+	case irsav1alpha1.WebhookCertificateCertManager:
+		return certManagerWebhookCertificate(ctx, obj, kubeClient, current)
+	case irsav1alpha1.WebhookCertificateCertificateSigningRequest:
+		return csrWebhookCertificate(ctx, obj, kubeClient, secret, current, now)
+	default:
+		return selfSignedWebhookCertificate(ctx, obj.Spec.Webhook.GetNamespace(), secret, current, now)
+	}
+}
+
+// webhookCertificateSourceChanged reports whether the serving certificate has been obtained from another source.
+func webhookCertificateSourceChanged(obj *irsav1alpha1.IRSASetup) bool {
+	status := obj.Status.Webhook
+	return status != nil && status.CertificateSource != "" && status.CertificateSource != obj.Spec.Webhook.GetCertificate().GetSource()
+}
+
+// selfSignedWebhookCertificate returns the self-signed certificate of the webhook Secret, or a new one when it is missing or due for renewal.
+// When the certificate is renewed, the replaced one is kept in the CA bundle until it expires,
+// so that the kube-apiserver keeps trusting the webhook Pods that still serve it.
+func selfSignedWebhookCertificate(ctx context.Context, namespace string, secret *corev1.Secret, current *webhook.TlsCredential, now time.Time) (webhookCertificateState, error) {
+	var previous []byte
+	if secret != nil {
+		previous = secret.Data[webhook.PreviousCertificateKey]
+	}
+	if current != nil && now.Before(webhookRenewalTime(*current)) {
+		return issued(webhook.SelfSignedCertificate(*current, unexpiredCertificate(previous, now)), false), nil
+	}
+	cred, err := webhook.CreateTlsCredential(webhook.ServiceNamespacedName(namespace))
+	if err != nil {
+		return webhookCertificateState{}, err
+	}
+	if current == nil {
+		return issued(webhook.SelfSignedCertificate(cred, nil), true), nil
+	}
+	ctrllog.FromContext(ctx).Info("the serving certificate of the webhook has been renewed", "notAfter", cred.NotAfter())
+	return issued(webhook.SelfSignedCertificate(cred, unexpiredCertificate(current.Certificate(), now)), true), nil
+}
+
+// unexpiredCertificate returns the certificate, or nil when it has expired or cannot be parsed.
+func unexpiredCertificate(certificate []byte, now time.Time) []byte {
+	if len(certificate) == 0 {
+		return nil
+	}
+	notAfter, err := webhook.CertificateNotAfter(certificate)
+	if err != nil || !now.Before(notAfter) {
+		return nil
+	}
+	return certificate
+}
+
+// newWebhookSetup returns the webhook resources deployed as configured and serving the certificate obtained from the configured source,
+// and records the namespace of the webhook and the expiry of the certificate in the status.
+// The cert-manager resources are removed when the certificate is no longer issued by cert-manager.
+// It also reports whether a certificate has been issued that is not stored in the webhook Secret yet,
+// or whether the IRSASetup has changed since the webhook resources have last been applied.
+func newWebhookSetup(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient, now time.Time) (*webhook.WebhookSetup, bool, error) {
+	state, err := webhookCertificate(ctx, obj, kubeClient, now)
+	if err != nil {
+		return nil, false, err
+	}
+	webhookSetup, err := webhook.NewWebHookSetup(state.serving, webhookOptions(obj))
+	if err != nil {
+		return nil, false, err
+	}
+	source := obj.Spec.Webhook.GetCertificate().GetSource()
+	if obj.Status.Webhook != nil && obj.Status.Webhook.CertificateSource == irsav1alpha1.WebhookCertificateCertManager && source != irsav1alpha1.WebhookCertificateCertManager {
+		if err := deleteCertManagerResources(ctx, kubeClient, deployedWebhookNamespace(obj)); err != nil {
+			return nil, false, err
+		}
+	}
+	status := &irsav1alpha1.WebhookStatus{
+		Namespace:          obj.Spec.Webhook.GetNamespace(),
+		ObservedGeneration: obj.Generation,
+		CertificateSource:  source,
+		Message:            state.message,
+	}
+	if !state.notAfter.IsZero() {
+		notAfter := metav1.NewTime(state.notAfter)
+		renewalTime := metav1.NewTime(state.renewalTime)
+		status.CertificateNotAfter = &notAfter
+		status.CertificateRenewalTime = &renewalTime
+	}
+	changed := state.renewed || webhookCertificateSourceChanged(obj) ||
+		obj.Status.Webhook == nil || obj.Status.Webhook.ObservedGeneration != obj.Generation
+	obj.Status.Webhook = status
+	return webhookSetup, changed, nil
+}
+
+// webhookCertificateNotServed reports whether the deployed webhook does not serve the certificate of the webhook setup,
+// e.g. because cert-manager has renewed it, or whether the MutatingWebhookConfiguration does not trust it.
+func webhookCertificateNotServed(ctx context.Context, kubeClient *kubernetes.KubernetesClient, webhookSetup *webhook.WebhookSetup) (bool, error) {
+	for _, r := range webhookSetup.Resources() {
+		var expected string
+		var fields []string
+		switch o := r.(type) {
+		case *appsv1.Deployment:
+			expected = o.Spec.Template.Annotations[webhook.CertificateHashAnnotation]
+			fields = []string{"spec", "template", "metadata", "annotations", webhook.CertificateHashAnnotation}
+		case *regv1.MutatingWebhookConfiguration:
+			if o.Annotations[webhook.CertManagerInjectCAAnnotation] != "" {
+				// the CA bundle is injected by cert-manager
+				continue
+			}
+			expected = base64.StdEncoding.EncodeToString(o.Webhooks[0].ClientConfig.CABundle)
+		default:
+			continue
+		}
+		deployed, err := kubeClient.Get(ctx, r)
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		var actual string
+		if fields != nil {
+			actual, _, err = unstructured.NestedString(deployed.Object, fields...)
+		} else {
+			actual, err = deployedCABundle(deployed)
+		}
+		if err != nil {
+			return false, err
+		}
+		if actual != expected {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// deployedCABundle returns the base64 encoded CA bundle of a deployed MutatingWebhookConfiguration.
+func deployedCABundle(deployed *unstructured.Unstructured) (string, error) {
+	webhooks, _, err := unstructured.NestedSlice(deployed.Object, "webhooks")
+	if err != nil || len(webhooks) == 0 {
+		return "", err
+	}
+	first, ok := webhooks[0].(map[string]interface{})
+	if !ok {
+		return "", nil
+	}
+	caBundle, _, err := unstructured.NestedString(first, "clientConfig", "caBundle")
+	return caBundle, err
+}
+
+// webhookCertificateRequeueAfter returns the period until the serving certificate of the webhook has to be read again.
+func webhookCertificateRequeueAfter(obj *irsav1alpha1.IRSASetup, now time.Time) time.Duration {
+	if obj.Status.Webhook == nil {
+		return 0
+	}
+	if obj.Status.Webhook.CertificateRenewalTime == nil {
+		return webhookCertificatePendingRequeueAfter
+	}
+	requeueAfter := obj.Status.Webhook.CertificateRenewalTime.Sub(now)
+	if requeueAfter <= 0 {
+		return webhookCertificatePendingRequeueAfter
+	}
+	return requeueAfter
+}
+
+// deleteCertManagerResources removes the cert-manager Certificate and the self-signed Issuer of the webhook in the namespace.
+// Nothing is removed when cert-manager is not installed.
+func deleteCertManagerResources(ctx context.Context, kubeClient *kubernetes.KubernetesClient, namespace string) error {
+	kubeHandler := handler.NewKubernetesHandler(kubeClient)
+	for _, r := range webhook.CertManagerResources(namespace, webhook.CertManagerIssuer{}) {
+		kubeHandler.Append(r)
+	}
+	_, err := kubeHandler.DeleteAll(ctx)
+	if err != nil && !apimeta.IsNoMatchError(err) {
+		return fmt.Errorf("failed to delete the cert-manager resources of the webhook: %w", err)
+	}
+	return nil
+}
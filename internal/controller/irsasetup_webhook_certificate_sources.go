@@ -0,0 +1,217 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/kkb0318/irsa-manager/internal/handler"
+	"github.com/kkb0318/irsa-manager/internal/kubernetes"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted/webhook"
+)
+
+// certManagerWebhookCertificate returns the webhook resources for a certificate issued by cert-manager,
+// and reads the expiry of the certificate from the status of the cert-manager Certificate.
+// The current certificate of the webhook Secret is only used to roll the webhook once cert-manager has renewed it.
+func certManagerWebhookCertificate(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient, current *webhook.TlsCredential) (webhookCertificateState, error) {
+	issuer := webhook.CertManagerIssuer{}
+	if spec := obj.Spec.Webhook.GetCertificate().CertManager; spec != nil && spec.IssuerRef != nil {
+		issuer = webhook.CertManagerIssuer{
+			Name:  spec.IssuerRef.Name,
+			Kind:  spec.IssuerRef.Kind,
+			Group: spec.IssuerRef.Group,
+		}
+	}
+	state := webhookCertificateState{
+		serving: webhook.ServingCertificate{CertManager: &issuer},
+	}
+	if current != nil {
+		state.serving.Credential = *current
+	}
+	certificateNamespacedName := webhook.SecretNamespacedName(obj.Spec.Webhook.GetNamespace())
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(webhook.CertificateGVK)
+	certificate.SetNamespace(certificateNamespacedName.Namespace)
+	certificate.SetName(certificateNamespacedName.Name)
+	u, err := kubeClient.Get(ctx, certificate)
+	if apimeta.IsNoMatchError(err) {
+		return webhookCertificateState{}, fmt.Errorf("the webhook certificate source is CertManager, but cert-manager is not installed: %w", err)
+	}
+	if apierrors.IsNotFound(err) {
+		state.message = fmt.Sprintf("waiting for the cert-manager Certificate %s to be created", certificateNamespacedName)
+		return state, nil
+	}
+	if err != nil {
+		return webhookCertificateState{}, err
+	}
+	notAfter, err := unstructuredTime(u, "status", "notAfter")
+	if err != nil {
+		return webhookCertificateState{}, err
+	}
+	renewalTime, err := unstructuredTime(u, "status", "renewalTime")
+	if err != nil {
+		return webhookCertificateState{}, err
+	}
+	if current == nil || notAfter.IsZero() {
+		state.message = fmt.Sprintf("waiting for cert-manager to issue the Certificate %s", certificateNamespacedName)
+		conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if ok && condition["type"] == "Ready" && condition["status"] != "True" && condition["message"] != nil {
+				state.message = fmt.Sprintf("%s: %v", state.message, condition["message"])
+			}
+		}
+		return state, nil
+	}
+	state.notAfter = notAfter
+	state.renewalTime = renewalTime
+	return state, nil
+}
+
+// unstructuredTime reads an RFC 3339 time from a field of an unstructured object.
+// It returns the zero time when the field is not set.
+func unstructuredTime(u *unstructured.Unstructured, fields ...string) (time.Time, error) {
+	value, found, err := unstructured.NestedString(u.Object, fields...)
+	if err != nil || !found {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// csrWebhookCertificate returns the certificate of the webhook Secret until it is due for renewal.
+// A new certificate is then requested through a CertificateSigningRequest for the configured signer.
+// The private key of the request is stored in the webhook Secret before the request is created,
+// and the current certificate is served until the request has been approved and signed.
+// Without a configured CA bundle the kube-apiserver trusts the signed certificate itself, so the replaced one is kept
+// in the CA bundle until it expires, as for the self-signed source.
+func csrWebhookCertificate(ctx context.Context, obj *irsav1alpha1.IRSASetup, kubeClient *kubernetes.KubernetesClient, secret *corev1.Secret, current *webhook.TlsCredential, now time.Time) (webhookCertificateState, error) {
+	spec := obj.Spec.Webhook.GetCertificate().CertificateSigningRequest
+	if spec == nil || spec.SignerName == "" {
+		return webhookCertificateState{}, errors.New("webhook.certificate.certificateSigningRequest.signerName is required when the certificate source is CertificateSigningRequest")
+	}
+	serving := func(cred webhook.TlsCredential, previous []byte) webhook.ServingCertificate {
+		if spec.CABundle != "" {
+			return webhook.ServingCertificate{Credential: cred, CABundle: []byte(spec.CABundle)}
+		}
+		return webhook.SelfSignedCertificate(cred, previous)
+	}
+	var previous, pendingKey []byte
+	if secret != nil {
+		previous = unexpiredCertificate(secret.Data[webhook.PreviousCertificateKey], now)
+		pendingKey = secret.Data[webhook.PendingPrivateKeyKey]
+	}
+	if current != nil && now.Before(webhookRenewalTime(*current)) {
+		return issued(serving(*current, previous), false), nil
+	}
+
+	state := webhookCertificateState{serving: serving(webhook.TlsCredential{}, previous)}
+	if current != nil {
+		state = issued(serving(*current, previous), false)
+	}
+	if state.serving.SecretData == nil {
+		state.serving.SecretData = map[string][]byte{}
+	}
+	if len(pendingKey) == 0 {
+		var err error
+		pendingKey, err = webhook.CreatePrivateKey()
+		if err != nil {
+			return webhookCertificateState{}, err
+		}
+		state.serving.SecretData[webhook.PendingPrivateKeyKey] = pendingKey
+		// the private key is stored first, so that the certificate can be used once it is signed
+		pending, err := state.serving.Secret(obj.Spec.Webhook.GetNamespace())
+		if err != nil {
+			return webhookCertificateState{}, err
+		}
+		kubeHandler := handler.NewKubernetesHandler(kubeClient)
+		kubeHandler.Append(pending)
+		if _, err := kubeHandler.ApplyAll(ctx); err != nil {
+			return webhookCertificateState{}, err
+		}
+	}
+	state.serving.SecretData[webhook.PendingPrivateKeyKey] = pendingKey
+
+	request, err := webhook.NewCertificateSigningRequest(webhook.ServiceNamespacedName(obj.Spec.Webhook.GetNamespace()), pendingKey, spec.SignerName, spec.ExpirationSeconds)
+	if err != nil {
+		return webhookCertificateState{}, err
+	}
+	csr, err := getCertificateSigningRequest(ctx, kubeClient, request.Name)
+	if apierrors.IsNotFound(err) {
+		if err := kubeClient.Create(ctx, request); err != nil {
+			return webhookCertificateState{}, err
+		}
+		ctrllog.FromContext(ctx).Info("the serving certificate of the webhook has been requested", "certificateSigningRequest", request.Name, "signerName", spec.SignerName)
+		state.message = fmt.Sprintf("waiting for the CertificateSigningRequest %s to be approved and signed by %s", request.Name, spec.SignerName)
+		return state, nil
+	}
+	if err != nil {
+		return webhookCertificateState{}, err
+	}
+	for _, condition := range csr.Status.Conditions {
+		if condition.Type == certificatesv1.CertificateDenied || condition.Type == certificatesv1.CertificateFailed {
+			state.message = fmt.Sprintf("the CertificateSigningRequest %s is %s: %s, delete it to request the certificate again", request.Name, condition.Type, condition.Message)
+			return state, nil
+		}
+	}
+	if len(csr.Status.Certificate) == 0 {
+		state.message = fmt.Sprintf("waiting for the CertificateSigningRequest %s to be approved and signed by %s", request.Name, spec.SignerName)
+		return state, nil
+	}
+	cred, err := webhook.ParseTlsCredential(csr.Status.Certificate, pendingKey)
+	if err != nil {
+		return webhookCertificateState{}, fmt.Errorf("failed to read the certificate signed for the CertificateSigningRequest %s: %w", request.Name, err)
+	}
+	ctrllog.FromContext(ctx).Info("the serving certificate of the webhook has been signed", "certificateSigningRequest", request.Name, "notAfter", cred.NotAfter())
+	if current != nil {
+		previous = unexpiredCertificate(current.Certificate(), now)
+	}
+	return issued(serving(cred, previous), true), nil
+}
+
+// getCertificateSigningRequest reads a CertificateSigningRequest.
+func getCertificateSigningRequest(ctx context.Context, kubeClient *kubernetes.KubernetesClient, name string) (*certificatesv1.CertificateSigningRequest, error) {
+	u, err := kubeClient.Get(ctx, &certificatesv1.CertificateSigningRequest{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: certificatesv1.SchemeGroupVersion.String(),
+			Kind:       "CertificateSigningRequest",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	csr := &certificatesv1.CertificateSigningRequest{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, csr); err != nil {
+		return nil, fmt.Errorf("error converting to CertificateSigningRequest for %s: %v", u.GetName(), err)
+	}
+	return csr, nil
+}
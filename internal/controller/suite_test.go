@@ -28,6 +28,7 @@ import (
 
 	regv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -126,6 +127,14 @@ func newMutatingWebhookConfiguration() client.Object {
 	return &regv1.MutatingWebhookConfiguration{}
 }
 
+func newConfigMap() client.Object {
+	return &corev1.ConfigMap{}
+}
+
+func newLease() client.Object {
+	return &coordinationv1.Lease{}
+}
+
 func newService() client.Object {
 	return &corev1.Service{}
 }
@@ -134,6 +143,18 @@ func newDeployment() client.Object {
 	return &appsv1.Deployment{}
 }
 
+func newDaemonSet() client.Object {
+	return &appsv1.DaemonSet{}
+}
+
+func newRole() client.Object {
+	return &rbacv1.Role{}
+}
+
+func newRoleBinding() client.Object {
+	return &rbacv1.RoleBinding{}
+}
+
 func newServiceAccount() client.Object {
 	return &corev1.ServiceAccount{}
 }
@@ -2,6 +2,8 @@ package issuer
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 
 	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
 )
@@ -9,17 +11,33 @@ import (
 type OIDCIssuerMeta interface {
 	IssuerHostPath() string
 	IssuerUrl() string
+	// ServedByAWS reports whether the issuer is served by AWS with a certificate trusted by AWS IAM,
+	// so that the IAM OIDC provider does not need the thumbprint of its certificate.
+	ServedByAWS() bool
 }
 
 type s3IssuerMeta struct {
 	region     string
 	bucketName string
+	prefix     string
 }
 
 func NewOIDCIssuerMeta(i *irsav1alpha1.IRSASetup) (OIDCIssuerMeta, error) {
 	if i.Spec.Mode == irsav1alpha1.ModeEks {
 		return newIamOIDCProviderIssuerMeta(i.Spec.IamOIDCProvider)
 	}
+	if i.Spec.Issuer != nil {
+		return newCustomIssuerMeta(i.Spec.Issuer.URL)
+	}
+	if i.Spec.Discovery.InCluster != nil {
+		return newInClusterIssuerMeta(i.Spec.Discovery.InCluster)
+	}
+	if i.Spec.Discovery.S3.CloudFront != nil {
+		return newCloudFrontIssuerMeta(i.Spec.Discovery.S3.CloudFront, i.Spec.Discovery.S3.Prefix, i.Status.CloudFront)
+	}
+	if i.Spec.Discovery.S3.Endpoint != "" {
+		return newS3CompatibleIssuerMeta(&i.Spec.Discovery.S3)
+	}
 	return newS3IssuerMeta(&i.Spec.Discovery.S3)
 }
 
@@ -29,11 +47,11 @@ func newS3IssuerMeta(s3 *irsav1alpha1.S3Discovery) (*s3IssuerMeta, error) {
 	if region == "" || bucketName == "" {
 		return nil, fmt.Errorf("s3 region and bucket name must not be empty. region: %s, bucketName: %s", region, bucketName)
 	}
-	return &s3IssuerMeta{region, bucketName}, nil
+	return &s3IssuerMeta{region, bucketName, s3.Prefix}, nil
 }
 
 func (i *s3IssuerMeta) IssuerHostPath() string {
-	return fmt.Sprintf("s3-%s.amazonaws.com/%s", i.region, i.bucketName)
+	return withPrefix(fmt.Sprintf("s3-%s.amazonaws.com/%s", i.region, i.bucketName), i.prefix)
 }
 
 // IssuerUrl constructs the URL path for the OIDC issuer based on the provided AWS region and bucket name.
@@ -42,6 +60,10 @@ func (i *s3IssuerMeta) IssuerUrl() string {
 	return fmt.Sprintf("https://%s", i.IssuerHostPath())
 }
 
+func (i *s3IssuerMeta) ServedByAWS() bool {
+	return true
+}
+
 func newIamOIDCProviderIssuerMeta(providerName string) (*iamOIDCProviderIssuerMeta, error) {
 	if providerName == "" {
 		return nil, fmt.Errorf("IAM OIDC Provider Name must not be empty")
@@ -60,3 +82,107 @@ func (i *iamOIDCProviderIssuerMeta) IssuerHostPath() string {
 func (i *iamOIDCProviderIssuerMeta) IssuerUrl() string {
 	return fmt.Sprintf("https://%s", i.IssuerHostPath())
 }
+
+func (i *iamOIDCProviderIssuerMeta) ServedByAWS() bool {
+	return true
+}
+
+// cloudFrontIssuerMeta is the issuer of a CloudFront distribution in front of the S3 bucket.
+type cloudFrontIssuerMeta struct {
+	domainName string
+}
+
+// newCloudFrontIssuerMeta uses the alias as the issuer if it is set, or the domain name of the distribution otherwise.
+// The domain name is only known once the distribution has been reconciled.
+func newCloudFrontIssuerMeta(cloudFront *irsav1alpha1.CloudFrontDiscovery, prefix string, status *irsav1alpha1.CloudFrontStatus) (*cloudFrontIssuerMeta, error) {
+	if cloudFront.Alias != "" {
+		return &cloudFrontIssuerMeta{withPrefix(cloudFront.Alias, prefix)}, nil
+	}
+	if status == nil || status.DomainName == "" {
+		return nil, fmt.Errorf("the domain name of the CloudFront distribution is not known yet")
+	}
+	return &cloudFrontIssuerMeta{withPrefix(status.DomainName, prefix)}, nil
+}
+
+func (i *cloudFrontIssuerMeta) IssuerHostPath() string {
+	return i.domainName
+}
+
+func (i *cloudFrontIssuerMeta) IssuerUrl() string {
+	return fmt.Sprintf("https://%s", i.IssuerHostPath())
+}
+
+// ServedByAWS is true also for an alias, as its certificate is issued by ACM.
+func (i *cloudFrontIssuerMeta) ServedByAWS() bool {
+	return true
+}
+
+// customIssuerMeta is an issuer that is independent of the storage of the discovery documents.
+type customIssuerMeta struct {
+	hostPath string
+}
+
+func newCustomIssuerMeta(issuerUrl string) (*customIssuerMeta, error) {
+	u, err := url.Parse(issuerUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer URL %q, %w", issuerUrl, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid issuer URL %q, it must be an https URL", issuerUrl)
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return nil, fmt.Errorf("invalid issuer URL %q, it must not have a query, a fragment or user info", issuerUrl)
+	}
+	if strings.HasSuffix(u.Path, "/") {
+		return nil, fmt.Errorf("invalid issuer URL %q, it must not have a trailing slash", issuerUrl)
+	}
+	return &customIssuerMeta{u.Host + u.Path}, nil
+}
+
+func (i *customIssuerMeta) IssuerHostPath() string {
+	return i.hostPath
+}
+
+func (i *customIssuerMeta) IssuerUrl() string {
+	return fmt.Sprintf("https://%s", i.IssuerHostPath())
+}
+
+// ServedByAWS is false, as the certificate of the issuer is not known.
+func (i *customIssuerMeta) ServedByAWS() bool {
+	return false
+}
+
+// newS3CompatibleIssuerMeta derives the issuer from the custom endpoint of an S3-compatible storage.
+// The bucket is addressed as a path of the endpoint with path-style requests, or as a subdomain of it otherwise.
+func newS3CompatibleIssuerMeta(s3 *irsav1alpha1.S3Discovery) (*customIssuerMeta, error) {
+	if s3.BucketName == "" {
+		return nil, fmt.Errorf("s3 bucket name must not be empty")
+	}
+	u, err := url.Parse(s3.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint %q, %w", s3.Endpoint, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q, it must be an https URL to be used as the issuer", s3.Endpoint)
+	}
+	if s3.UsePathStyle {
+		return &customIssuerMeta{withPrefix(fmt.Sprintf("%s%s/%s", u.Host, strings.TrimSuffix(u.Path, "/"), s3.BucketName), s3.Prefix)}, nil
+	}
+	return &customIssuerMeta{withPrefix(fmt.Sprintf("%s.%s", s3.BucketName, u.Host), s3.Prefix)}, nil
+}
+
+// withPrefix appends the prefix of the objects in the bucket to the host path of the issuer.
+func withPrefix(hostPath, prefix string) string {
+	if prefix == "" {
+		return hostPath
+	}
+	return fmt.Sprintf("%s/%s", hostPath, prefix)
+}
+
+// newInClusterIssuerMeta uses the host of the Ingress in front of the in-cluster discovery server as the issuer.
+func newInClusterIssuerMeta(inCluster *irsav1alpha1.InClusterDiscovery) (*customIssuerMeta, error) {
+	if inCluster.Host == "" {
+		return nil, fmt.Errorf("the host of the in-cluster discovery must not be empty")
+	}
+	return &customIssuerMeta{inCluster.Host}, nil
+}
@@ -0,0 +1,195 @@
+package issuer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+)
+
+func TestNewOIDCIssuerMeta(t *testing.T) {
+	s3 := irsav1alpha1.S3Discovery{Region: "ap-northeast-1", BucketName: "irsa-manager"}
+	withCloudFront := func(cloudFront irsav1alpha1.CloudFrontDiscovery) irsav1alpha1.S3Discovery {
+		s := s3
+		s.CloudFront = &cloudFront
+		return s
+	}
+	tests := []struct {
+		name             string
+		s3               irsav1alpha1.S3Discovery
+		inCluster        *irsav1alpha1.InClusterDiscovery
+		issuer           *irsav1alpha1.Issuer
+		status           *irsav1alpha1.CloudFrontStatus
+		expectedHostPath string
+		expectedErr      bool
+	}{
+		{
+			name:             "s3",
+			s3:               s3,
+			expectedHostPath: "s3-ap-northeast-1.amazonaws.com/irsa-manager",
+		},
+		{
+			name:             "s3 with a prefix",
+			s3:               irsav1alpha1.S3Discovery{Region: "ap-northeast-1", BucketName: "irsa-manager", Prefix: "clusters/a"},
+			expectedHostPath: "s3-ap-northeast-1.amazonaws.com/irsa-manager/clusters/a",
+		},
+		{
+			name: "cloudfront with a prefix",
+			s3: irsav1alpha1.S3Discovery{
+				Region:     "ap-northeast-1",
+				BucketName: "irsa-manager",
+				Prefix:     "cluster-a",
+				CloudFront: &irsav1alpha1.CloudFrontDiscovery{},
+			},
+			status:           &irsav1alpha1.CloudFrontStatus{DomainName: "d111111abcdef8.cloudfront.net"},
+			expectedHostPath: "d111111abcdef8.cloudfront.net/cluster-a",
+		},
+		{
+			name:             "cloudfront domain name",
+			s3:               withCloudFront(irsav1alpha1.CloudFrontDiscovery{}),
+			status:           &irsav1alpha1.CloudFrontStatus{DomainName: "d111111abcdef8.cloudfront.net"},
+			expectedHostPath: "d111111abcdef8.cloudfront.net",
+		},
+		{
+			name:             "cloudfront alias",
+			s3:               withCloudFront(irsav1alpha1.CloudFrontDiscovery{Alias: "oidc.example.com"}),
+			status:           &irsav1alpha1.CloudFrontStatus{DomainName: "d111111abcdef8.cloudfront.net"},
+			expectedHostPath: "oidc.example.com",
+		},
+		{
+			name:             "custom issuer",
+			s3:               s3,
+			issuer:           &irsav1alpha1.Issuer{URL: "https://oidc.example.com/cluster-a"},
+			expectedHostPath: "oidc.example.com/cluster-a",
+		},
+		{
+			name:             "custom issuer overrides cloudfront",
+			s3:               withCloudFront(irsav1alpha1.CloudFrontDiscovery{Alias: "oidc.example.com"}),
+			issuer:           &irsav1alpha1.Issuer{URL: "https://oidc.example.net"},
+			expectedHostPath: "oidc.example.net",
+		},
+		{
+			name:        "custom issuer without https",
+			s3:          s3,
+			issuer:      &irsav1alpha1.Issuer{URL: "http://oidc.example.com"},
+			expectedErr: true,
+		},
+		{
+			name:        "custom issuer with a trailing slash",
+			s3:          s3,
+			issuer:      &irsav1alpha1.Issuer{URL: "https://oidc.example.com/"},
+			expectedErr: true,
+		},
+		{
+			name:             "in-cluster",
+			inCluster:        &irsav1alpha1.InClusterDiscovery{Host: "oidc.example.com"},
+			expectedHostPath: "oidc.example.com",
+		},
+		{
+			name:             "custom issuer overrides in-cluster",
+			inCluster:        &irsav1alpha1.InClusterDiscovery{Host: "oidc.example.com"},
+			issuer:           &irsav1alpha1.Issuer{URL: "https://oidc.example.net/cluster-a"},
+			expectedHostPath: "oidc.example.net/cluster-a",
+		},
+		{
+			name:             "s3 compatible endpoint with path-style requests",
+			s3:               irsav1alpha1.S3Discovery{BucketName: "irsa-manager", Endpoint: "https://minio.example.com:9000/", UsePathStyle: true},
+			expectedHostPath: "minio.example.com:9000/irsa-manager",
+		},
+		{
+			name:             "s3 compatible endpoint with virtual-hosted-style requests",
+			s3:               irsav1alpha1.S3Discovery{BucketName: "irsa-manager", Endpoint: "https://s3.wasabisys.com"},
+			expectedHostPath: "irsa-manager.s3.wasabisys.com",
+		},
+		{
+			name:             "s3 compatible endpoint with a prefix",
+			s3:               irsav1alpha1.S3Discovery{BucketName: "irsa-manager", Endpoint: "https://minio.example.com", UsePathStyle: true, Prefix: "cluster-a"},
+			expectedHostPath: "minio.example.com/irsa-manager/cluster-a",
+		},
+		{
+			name:        "s3 compatible endpoint without https",
+			s3:          irsav1alpha1.S3Discovery{BucketName: "irsa-manager", Endpoint: "http://minio:9000", UsePathStyle: true},
+			expectedErr: true,
+		},
+		{
+			name:        "cloudfront distribution not set up yet",
+			s3:          withCloudFront(irsav1alpha1.CloudFrontDiscovery{}),
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &irsav1alpha1.IRSASetup{
+				Spec: irsav1alpha1.IRSASetupSpec{
+					Discovery: irsav1alpha1.Discovery{S3: tt.s3, InCluster: tt.inCluster},
+					Issuer:    tt.issuer,
+				},
+				Status: irsav1alpha1.IRSASetupStatus{CloudFront: tt.status},
+			}
+			meta, err := NewOIDCIssuerMeta(obj)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedHostPath, meta.IssuerHostPath())
+			assert.Equal(t, "https://"+tt.expectedHostPath, meta.IssuerUrl())
+		})
+	}
+}
+
+func TestServedByAWS(t *testing.T) {
+	s3 := irsav1alpha1.S3Discovery{Region: "ap-northeast-1", BucketName: "irsa-manager"}
+	tests := []struct {
+		name      string
+		spec      irsav1alpha1.IRSASetupSpec
+		status    *irsav1alpha1.CloudFrontStatus
+		servedAWS bool
+	}{
+		{
+			name:      "s3",
+			spec:      irsav1alpha1.IRSASetupSpec{Discovery: irsav1alpha1.Discovery{S3: s3}},
+			servedAWS: true,
+		},
+		{
+			name: "cloudfront alias",
+			spec: irsav1alpha1.IRSASetupSpec{Discovery: irsav1alpha1.Discovery{S3: irsav1alpha1.S3Discovery{
+				Region:     "ap-northeast-1",
+				BucketName: "irsa-manager",
+				CloudFront: &irsav1alpha1.CloudFrontDiscovery{Alias: "oidc.example.com"},
+			}}},
+			servedAWS: true,
+		},
+		{
+			name:      "eks",
+			spec:      irsav1alpha1.IRSASetupSpec{Mode: irsav1alpha1.ModeEks, IamOIDCProvider: "oidc.eks.ap-northeast-1.amazonaws.com/id/EXAMPLE"},
+			servedAWS: true,
+		},
+		{
+			name:      "custom issuer",
+			spec:      irsav1alpha1.IRSASetupSpec{Discovery: irsav1alpha1.Discovery{S3: s3}, Issuer: &irsav1alpha1.Issuer{URL: "https://oidc.example.com"}},
+			servedAWS: false,
+		},
+		{
+			name:      "in-cluster",
+			spec:      irsav1alpha1.IRSASetupSpec{Discovery: irsav1alpha1.Discovery{InCluster: &irsav1alpha1.InClusterDiscovery{Host: "oidc.example.com"}}},
+			servedAWS: false,
+		},
+		{
+			name: "s3-compatible storage",
+			spec: irsav1alpha1.IRSASetupSpec{Discovery: irsav1alpha1.Discovery{S3: irsav1alpha1.S3Discovery{
+				BucketName: "irsa-manager",
+				Endpoint:   "https://minio.example.com",
+			}}},
+			servedAWS: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := NewOIDCIssuerMeta(&irsav1alpha1.IRSASetup{Spec: tt.spec})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.servedAWS, meta.ServedByAWS())
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package manifests
+
+import (
+	"strings"
+
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The keys of the ConfigMap holding the configuration of the kube-apiserver.
+const (
+	APIServerFlagsKey   = "flags"
+	APIServerKubeadmKey = "kubeadm.yaml"
+	APIServerK3sKey     = "k3s.yaml"
+	APIServerRKE2Key    = "rke2.yaml"
+	APIServerTalosKey   = "talos.yaml"
+)
+
+type ConfigMapBuilder struct {
+	data map[string]string
+}
+
+func NewConfigMapBuilder() *ConfigMapBuilder {
+	return &ConfigMapBuilder{}
+}
+
+// WithAPIServerConfig stores the flags of the kube-apiserver, one per line, and the configuration snippets of each distribution.
+func (b *ConfigMapBuilder) WithAPIServerConfig(c *selfhosted.APIServerConfig) (*ConfigMapBuilder, error) {
+	b.data = map[string]string{
+		APIServerFlagsKey: strings.Join(c.Flags(), "\n") + "\n",
+	}
+	for key, render := range map[string]func() ([]byte, error){
+		APIServerKubeadmKey: c.Kubeadm,
+		APIServerK3sKey:     c.K3s,
+		APIServerRKE2Key:    c.RKE2,
+		APIServerTalosKey:   c.Talos,
+	} {
+		snippet, err := render()
+		if err != nil {
+			return nil, err
+		}
+		b.data[key] = string(snippet)
+	}
+	return b, nil
+}
+
+func (b *ConfigMapBuilder) Build(namespacedName types.NamespacedName) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namespacedName.Name,
+			Namespace: namespacedName.Namespace,
+		},
+		Data: b.data,
+	}
+}
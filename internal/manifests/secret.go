@@ -14,6 +14,13 @@ func SshKeyNamespacedName() types.NamespacedName {
 	}
 }
 
+// SigningPublicKeysKey is the key of the key Secret holding the PEM encoded public keys published in the JWKS.
+const SigningPublicKeysKey = "ssh-publickey"
+
+// PendingSigningKeyKey is the key of the key Secret holding the PEM encoded private key that is published in the JWKS
+// but not used for signing yet.
+const PendingSigningKeyKey = "pending-privatekey"
+
 type SecretBuilder struct {
 	data       map[string][]byte
 	secretType corev1.SecretType
@@ -32,13 +39,26 @@ func NewSecretBuilder() *SecretBuilder {
 
 func (b *SecretBuilder) WithSSHKey(keyPair selfhosted.KeyPair) *SecretBuilder {
 	b.data = map[string][]byte{
-		"ssh-publickey":          keyPair.PublicKey(),
+		SigningPublicKeysKey:     keyPair.PublicKey(),
 		corev1.SSHAuthPrivateKey: keyPair.PrivateKey(),
 	}
 	b.secretType = corev1.SecretTypeSSHAuth
 	return b
 }
 
+// WithSigningKeys stores the active signing key, the pending signing key if any, and the public keys of all published signing keys.
+func (b *SecretBuilder) WithSigningKeys(keys selfhosted.SigningKeys) *SecretBuilder {
+	b.data = map[string][]byte{
+		SigningPublicKeysKey:     keys.PublicKeys(),
+		corev1.SSHAuthPrivateKey: keys.Active().PrivateKey(),
+	}
+	if pending := keys.Pending(); pending != nil {
+		b.data[PendingSigningKeyKey] = pending.PrivateKey()
+	}
+	b.secretType = corev1.SecretTypeSSHAuth
+	return b
+}
+
 func (b *SecretBuilder) WithCertificate(t TlsCredential) *SecretBuilder {
 	b.data = map[string][]byte{
 		"tls.crt": t.Certificate(),
@@ -21,6 +21,10 @@ func (b *ServiceAccountBuilder) WithIRSAAnnotation(role awsclient.RoleManager) *
 	b.annotation = map[string]string{
 		"eks.amazonaws.com/role-arn": fmt.Sprintf("arn:aws:iam::%s:role/%s", role.AccountId, role.RoleName),
 	}
+	if role.Audience != "" {
+		// the webhook projects tokens with this audience, which must match the trust policy of the role
+		b.annotation["eks.amazonaws.com/audience"] = role.Audience
+	}
 	return b
 }
 
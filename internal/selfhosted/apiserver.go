@@ -0,0 +1,77 @@
+package selfhosted
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/client-go/rest"
+)
+
+const (
+	apiServerDiscoveryPath = "/.well-known/openid-configuration"
+	apiServerJWKSPath      = "/openid/v1/jwks"
+)
+
+// APIServerDiscovery reads the service account issuer discovery documents served by the kube-apiserver.
+type APIServerDiscovery struct {
+	client rest.Interface
+}
+
+func NewAPIServerDiscovery(client rest.Interface) *APIServerDiscovery {
+	return &APIServerDiscovery{client}
+}
+
+// Issuer returns the issuer advertised in the kube-apiserver's OpenID configuration.
+func (d *APIServerDiscovery) Issuer(ctx context.Context) (string, error) {
+	data, err := d.client.Get().AbsPath(apiServerDiscoveryPath).DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s from the kube-apiserver: %w", apiServerDiscoveryPath, err)
+	}
+	var discovery struct {
+		Issuer string `json:"issuer"`
+	}
+	if err := json.Unmarshal(data, &discovery); err != nil {
+		return "", fmt.Errorf("failed to parse %s from the kube-apiserver: %w", apiServerDiscoveryPath, err)
+	}
+	return discovery.Issuer, nil
+}
+
+// JWK returns the kube-apiserver's JWKS, which is published unchanged when it is mirrored.
+func (d *APIServerDiscovery) JWK(ctx context.Context) (*JWK, error) {
+	data, err := d.client.Get().AbsPath(apiServerJWKSPath).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from the kube-apiserver: %w", apiServerJWKSPath, err)
+	}
+	jwk, err := ParseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s from the kube-apiserver: %w", apiServerJWKSPath, err)
+	}
+	return jwk, nil
+}
+
+// ParseJWKS parses a JWKS served by a kube-apiserver.
+// Every key must be of a type supported as service account signing key, and must have a key ID and an algorithm
+// as the kube-apiserver sets them, since the tokens are verified against the key of their "kid" header.
+func ParseJWKS(data []byte) (*JWK, error) {
+	jwk := &JWK{}
+	if err := json.Unmarshal(data, jwk); err != nil {
+		return nil, err
+	}
+	if len(jwk.Keys) == 0 {
+		return nil, fmt.Errorf("the JWKS does not contain any key")
+	}
+	for _, key := range jwk.Keys {
+		if key.KeyID == "" || key.Algorithm == "" {
+			return nil, fmt.Errorf("every key of the JWKS must have a key ID and an algorithm")
+		}
+		pub, err := encodePublicKey(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := KeyAlgorithm(pub); err != nil {
+			return nil, err
+		}
+	}
+	return jwk, nil
+}
@@ -0,0 +1,159 @@
+package selfhosted
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultServiceAccountIssuer is the issuer of the kube-apiserver set up by kubeadm, k3s and RKE2,
+// which is kept as an accepted issuer so that the tokens issued before the migration stay valid.
+const DefaultServiceAccountIssuer = "https://kubernetes.default.svc.cluster.local"
+
+// The names of the key files of the kube-system/irsa-manager-key Secret saved on the control plane nodes.
+const (
+	SigningKeyFileName = "irsa-manager.key"
+	PublicKeyFileName  = "irsa-manager.pub"
+)
+
+// APIServerConfig is the configuration of the kube-apiserver required to issue service account tokens for the issuer.
+type APIServerConfig struct {
+	issuerUrl string
+	audiences []string
+	// withKeyFiles is set when the kube-apiserver signs the tokens with the key of the irsa-manager-key Secret,
+	// i.e. when the signing key is not managed outside of irsa-manager.
+	withKeyFiles bool
+}
+
+// NewAPIServerConfig returns the configuration for the issuer and the audiences accepted by the kube-apiserver.
+func NewAPIServerConfig(issuerUrl string, audiences []string, withKeyFiles bool) *APIServerConfig {
+	return &APIServerConfig{
+		issuerUrl:    issuerUrl,
+		audiences:    audiences,
+		withKeyFiles: withKeyFiles,
+	}
+}
+
+// distribution describes where a Kubernetes distribution keeps the service account keys of the kube-apiserver.
+type distribution struct {
+	// keyDir is the directory in which the key files of the irsa-manager-key Secret are saved.
+	keyDir string
+	// legacyKeyFile is the key file of the distribution, kept so that the tokens it has signed stay valid.
+	legacyKeyFile string
+}
+
+var (
+	kubeadm = distribution{keyDir: "/etc/kubernetes/pki", legacyKeyFile: "/etc/kubernetes/pki/sa.pub"}
+	k3s     = distribution{keyDir: "/var/lib/rancher/k3s/server/tls", legacyKeyFile: "/var/lib/rancher/k3s/server/tls/service.key"}
+	rke2    = distribution{keyDir: "/var/lib/rancher/rke2/server/tls", legacyKeyFile: "/var/lib/rancher/rke2/server/tls/service.key"}
+)
+
+// Flags returns the flags of the kube-apiserver with the key files saved in /etc/kubernetes/pki as recommended for kubeadm.
+func (c *APIServerConfig) Flags() []string {
+	return c.FlagsWithKeyDir(kubeadm.keyDir)
+}
+
+// FlagsWithKeyDir returns the flags of the kube-apiserver of a kubeadm cluster with the key files saved in keyDir.
+func (c *APIServerConfig) FlagsWithKeyDir(keyDir string) []string {
+	d := kubeadm
+	d.keyDir = keyDir
+	args := c.args(d)
+	flags := make([]string, len(args))
+	for i, arg := range args {
+		flags[i] = fmt.Sprintf("--%s=%s", arg[0], arg[1])
+	}
+	return flags
+}
+
+// args returns the names and the values of the flags of the kube-apiserver of the distribution.
+// The issuer comes first, as the first one is used to issue the tokens.
+func (c *APIServerConfig) args(d distribution) [][2]string {
+	args := [][2]string{
+		{"service-account-issuer", c.issuerUrl},
+	}
+	if c.issuerUrl != DefaultServiceAccountIssuer {
+		args = append(args, [2]string{"service-account-issuer", DefaultServiceAccountIssuer})
+	}
+	args = append(args, [2]string{"api-audiences", strings.Join(c.apiAudiences(), ",")})
+	if c.withKeyFiles {
+		args = append(args,
+			[2]string{"service-account-key-file", path.Join(d.keyDir, PublicKeyFileName)},
+			[2]string{"service-account-key-file", d.legacyKeyFile},
+			[2]string{"service-account-signing-key-file", path.Join(d.keyDir, SigningKeyFileName)},
+		)
+	}
+	return args
+}
+
+// apiAudiences returns the audiences with the default issuer, which is the audience of the in-cluster tokens.
+func (c *APIServerConfig) apiAudiences() []string {
+	audiences := append([]string{}, c.audiences...)
+	return append(audiences, DefaultServiceAccountIssuer)
+}
+
+// Kubeadm returns the ClusterConfiguration of kubeadm setting the flags.
+// The v1beta4 API is used, as it is the first one that allows a flag to be set several times.
+func (c *APIServerConfig) Kubeadm() ([]byte, error) {
+	type arg struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	extraArgs := []arg{}
+	for _, a := range c.args(kubeadm) {
+		extraArgs = append(extraArgs, arg{Name: a[0], Value: a[1]})
+	}
+	return yaml.Marshal(map[string]interface{}{
+		"apiVersion": "kubeadm.k8s.io/v1beta4",
+		"kind":       "ClusterConfiguration",
+		"apiServer": map[string]interface{}{
+			"extraArgs": extraArgs,
+		},
+	})
+}
+
+// K3s returns the k3s configuration file, i.e. /etc/rancher/k3s/config.yaml, setting the flags.
+func (c *APIServerConfig) K3s() ([]byte, error) {
+	return c.rancherConfig(k3s)
+}
+
+// RKE2 returns the RKE2 configuration file, i.e. /etc/rancher/rke2/config.yaml, setting the flags.
+func (c *APIServerConfig) RKE2() ([]byte, error) {
+	return c.rancherConfig(rke2)
+}
+
+func (c *APIServerConfig) rancherConfig(d distribution) ([]byte, error) {
+	args := []string{}
+	for _, a := range c.args(d) {
+		args = append(args, fmt.Sprintf("%s=%s", a[0], a[1]))
+	}
+	return yaml.Marshal(map[string]interface{}{
+		"kube-apiserver-arg": args,
+	})
+}
+
+// TalosKeyPlaceholder is set in the Talos machine config in place of the signing key,
+// which is not copied out of the irsa-manager-key Secret.
+const TalosKeyPlaceholder = "<base64 encoded ssh-privatekey of the kube-system/irsa-manager-key Secret>"
+
+// Talos returns the patch of the Talos machine config of the control plane nodes.
+// Talos accepts a single value per flag and a single service account key, so the tokens issued before are not accepted anymore.
+func (c *APIServerConfig) Talos() ([]byte, error) {
+	cluster := map[string]interface{}{
+		"apiServer": map[string]interface{}{
+			"extraArgs": map[string]string{
+				"service-account-issuer": c.issuerUrl,
+				"api-audiences":          strings.Join(c.apiAudiences(), ","),
+			},
+		},
+	}
+	if c.withKeyFiles {
+		cluster["serviceAccount"] = map[string]string{
+			"key": TalosKeyPlaceholder,
+		}
+	}
+	return yaml.Marshal(map[string]interface{}{
+		"cluster": cluster,
+	})
+}
@@ -0,0 +1,109 @@
+package selfhosted
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIServerConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          *APIServerConfig
+		expectedFlags   []string
+		expectedK3s     string
+		expectedTalos   string
+		expectedKubeadm string
+	}{
+		{
+			name:   "generated signing key",
+			config: NewAPIServerConfig("https://s3-ap-northeast-1.amazonaws.com/bucket", []string{"sts.amazonaws.com"}, true),
+			expectedFlags: []string{
+				"--service-account-issuer=https://s3-ap-northeast-1.amazonaws.com/bucket",
+				"--service-account-issuer=https://kubernetes.default.svc.cluster.local",
+				"--api-audiences=sts.amazonaws.com,https://kubernetes.default.svc.cluster.local",
+				"--service-account-key-file=/etc/kubernetes/pki/irsa-manager.pub",
+				"--service-account-key-file=/etc/kubernetes/pki/sa.pub",
+				"--service-account-signing-key-file=/etc/kubernetes/pki/irsa-manager.key",
+			},
+			expectedK3s: `kube-apiserver-arg:
+- service-account-issuer=https://s3-ap-northeast-1.amazonaws.com/bucket
+- service-account-issuer=https://kubernetes.default.svc.cluster.local
+- api-audiences=sts.amazonaws.com,https://kubernetes.default.svc.cluster.local
+- service-account-key-file=/var/lib/rancher/k3s/server/tls/irsa-manager.pub
+- service-account-key-file=/var/lib/rancher/k3s/server/tls/service.key
+- service-account-signing-key-file=/var/lib/rancher/k3s/server/tls/irsa-manager.key
+`,
+			expectedTalos: `cluster:
+  apiServer:
+    extraArgs:
+      api-audiences: sts.amazonaws.com,https://kubernetes.default.svc.cluster.local
+      service-account-issuer: https://s3-ap-northeast-1.amazonaws.com/bucket
+  serviceAccount:
+    key: <base64 encoded ssh-privatekey of the kube-system/irsa-manager-key Secret>
+`,
+			expectedKubeadm: `apiServer:
+  extraArgs:
+  - name: service-account-issuer
+    value: https://s3-ap-northeast-1.amazonaws.com/bucket
+  - name: service-account-issuer
+    value: https://kubernetes.default.svc.cluster.local
+  - name: api-audiences
+    value: sts.amazonaws.com,https://kubernetes.default.svc.cluster.local
+  - name: service-account-key-file
+    value: /etc/kubernetes/pki/irsa-manager.pub
+  - name: service-account-key-file
+    value: /etc/kubernetes/pki/sa.pub
+  - name: service-account-signing-key-file
+    value: /etc/kubernetes/pki/irsa-manager.key
+apiVersion: kubeadm.k8s.io/v1beta4
+kind: ClusterConfiguration
+`,
+		},
+		{
+			name:   "external signing key",
+			config: NewAPIServerConfig("https://oidc.example.com", []string{"sts.amazonaws.com", "app.example.com"}, false),
+			expectedFlags: []string{
+				"--service-account-issuer=https://oidc.example.com",
+				"--service-account-issuer=https://kubernetes.default.svc.cluster.local",
+				"--api-audiences=sts.amazonaws.com,app.example.com,https://kubernetes.default.svc.cluster.local",
+			},
+			expectedK3s: `kube-apiserver-arg:
+- service-account-issuer=https://oidc.example.com
+- service-account-issuer=https://kubernetes.default.svc.cluster.local
+- api-audiences=sts.amazonaws.com,app.example.com,https://kubernetes.default.svc.cluster.local
+`,
+			expectedTalos: `cluster:
+  apiServer:
+    extraArgs:
+      api-audiences: sts.amazonaws.com,app.example.com,https://kubernetes.default.svc.cluster.local
+      service-account-issuer: https://oidc.example.com
+`,
+			expectedKubeadm: `apiServer:
+  extraArgs:
+  - name: service-account-issuer
+    value: https://oidc.example.com
+  - name: service-account-issuer
+    value: https://kubernetes.default.svc.cluster.local
+  - name: api-audiences
+    value: sts.amazonaws.com,app.example.com,https://kubernetes.default.svc.cluster.local
+apiVersion: kubeadm.k8s.io/v1beta4
+kind: ClusterConfiguration
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedFlags, tt.config.Flags())
+			k3sConfig, err := tt.config.K3s()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedK3s, string(k3sConfig))
+			talosConfig, err := tt.config.Talos()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedTalos, string(talosConfig))
+			kubeadmConfig, err := tt.config.Kubeadm()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedKubeadm, string(kubeadmConfig))
+		})
+	}
+}
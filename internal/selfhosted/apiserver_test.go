@@ -0,0 +1,145 @@
+package selfhosted
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/keyutil"
+)
+
+func TestAPIServerDiscovery(t *testing.T) {
+	rsaPub, err := os.ReadFile("testdata/rsa.pub")
+	assert.NoError(t, err)
+	ecdsaPub, err := os.ReadFile("testdata/ecdsa.pub")
+	assert.NoError(t, err)
+	rsaKID, err := KeyID(rsaPub)
+	assert.NoError(t, err)
+	ecdsaKID, err := KeyID(ecdsaPub)
+	assert.NoError(t, err)
+	jwks := jose.JSONWebKeySet{}
+	for _, pub := range [][]byte{rsaPub, ecdsaPub} {
+		keys, err := keyutil.ParsePublicKeysPEM(pub)
+		assert.NoError(t, err)
+		kid, err := KeyID(pub)
+		assert.NoError(t, err)
+		alg, err := signatureAlgorithm(keys[0])
+		assert.NoError(t, err)
+		jwks.Keys = append(jwks.Keys, jose.JSONWebKey{Key: keys[0], KeyID: kid, Algorithm: string(alg), Use: "sig"})
+	}
+	jwksJSON, err := json.Marshal(jwks)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		expectedIssuer string
+		expectedKIDs   []string
+		expectedErr    bool
+	}{
+		{
+			name: "discovery documents",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/.well-known/openid-configuration":
+					_, _ = w.Write([]byte(`{"issuer":"https://s3-ap-northeast-1.amazonaws.com/irsa-manager","jwks_uri":"https://s3-ap-northeast-1.amazonaws.com/irsa-manager/openid/v1/jwks"}`))
+				case "/openid/v1/jwks":
+					_, _ = w.Write(jwksJSON)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			},
+			expectedIssuer: "https://s3-ap-northeast-1.amazonaws.com/irsa-manager",
+			expectedKIDs:   []string{rsaKID, ecdsaKID},
+		},
+		{
+			name: "issuer discovery disabled",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			client, err := discovery.NewDiscoveryClientForConfig(&rest.Config{Host: server.URL})
+			assert.NoError(t, err)
+			d := NewAPIServerDiscovery(client.RESTClient())
+
+			issuer, err := d.Issuer(context.Background())
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedIssuer, issuer)
+			}
+			jwk, err := d.JWK(context.Background())
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			// the JWKS is kept as it is served, without a key added for tokens without a "kid" header
+			assert.Equal(t, tt.expectedKIDs, jwk.KeyIDs())
+			assert.Len(t, jwk.Keys, len(tt.expectedKIDs))
+			assert.Equal(t, []string{"RS256", "ES256"}, jwk.SigningAlgorithms())
+			pubs, err := jwk.PublicKeys()
+			assert.NoError(t, err)
+			kids, err := PublicKeyIDs(pubs)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedKIDs, kids)
+		})
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		expectedErr bool
+	}{
+		{
+			name:        "empty JWKS",
+			data:        `{"keys":[]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "invalid JSON",
+			data:        `not a JWKS`,
+			expectedErr: true,
+		},
+		{
+			name:        "key without a key ID",
+			data:        `{"keys":[{"use":"sig","kty":"EC","crv":"P-256","alg":"ES256","x":"f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU","y":"x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0"}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "key without an algorithm",
+			data:        `{"keys":[{"use":"sig","kty":"EC","kid":"key-1","crv":"P-256","x":"f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU","y":"x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0"}]}`,
+			expectedErr: true,
+		},
+		{
+			name: "kube-apiserver JWKS",
+			data: `{"keys":[{"use":"sig","kty":"EC","kid":"key-1","crv":"P-256","alg":"ES256","x":"f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU","y":"x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJWKS([]byte(tt.data))
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
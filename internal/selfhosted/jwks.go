@@ -2,13 +2,13 @@ package selfhosted
 
 import (
 	"crypto"
-	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
-	"errors"
 	"fmt"
+	"slices"
 
 	jose "github.com/go-jose/go-jose/v4"
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
 	"k8s.io/client-go/util/keyutil"
 )
 
@@ -42,37 +42,118 @@ type JWK struct {
 	Keys []jose.JSONWebKey `json:"keys"`
 }
 
-func NewJWK(pub []byte) (*JWK, error) {
-	pubKeys, err := keyutil.ParsePublicKeysPEM(pub)
+// SigningAlgorithms returns the signature algorithms of the keys, in the order they appear.
+func (j *JWK) SigningAlgorithms() []string {
+	algs := []string{}
+	for _, key := range j.Keys {
+		if !slices.Contains(algs, key.Algorithm) {
+			algs = append(algs, key.Algorithm)
+		}
+	}
+	return algs
+}
+
+// KeyIDs returns the unique non-empty key IDs of the keys, in the order they appear.
+func (j *JWK) KeyIDs() []string {
+	kids := []string{}
+	for _, key := range j.Keys {
+		if key.KeyID != "" && !slices.Contains(kids, key.KeyID) {
+			kids = append(kids, key.KeyID)
+		}
+	}
+	return kids
+}
+
+// PublicKeys returns the PEM encoded public keys of the JWKS, each one only once.
+func (j *JWK) PublicKeys() ([]byte, error) {
+	var pubs []byte
+	seen := []string{}
+	for _, key := range j.Keys {
+		pub, err := encodePublicKey(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(seen, string(pub)) {
+			continue
+		}
+		seen = append(seen, string(pub))
+		pubs = append(pubs, pub...)
+	}
+	return pubs, nil
+}
+
+// MergeJWKs merges the keys of several JWKSs, e.g. the ones of the clusters sharing an issuer.
+// Keys without a key ID cannot be told apart between the clusters, so they are left out,
+// and a key ID that appears more than once is only kept the first time.
+func MergeJWKs(jwks ...*JWK) *JWK {
+	merged := &JWK{Keys: []jose.JSONWebKey{}}
+	kids := []string{}
+	for _, jwk := range jwks {
+		for _, key := range jwk.Keys {
+			if key.KeyID == "" || slices.Contains(kids, key.KeyID) {
+				continue
+			}
+			kids = append(kids, key.KeyID)
+			merged.Keys = append(merged.Keys, key)
+		}
+	}
+	return merged
+}
+
+// signatureAlgorithm returns the JWS algorithm the kube-apiserver uses to sign tokens with the given key.
+// This follows
+// https://github.com/kubernetes/kubernetes/blob/v1.29.3/pkg/serviceaccount/jwt.go
+func signatureAlgorithm(pubKey interface{}) (jose.SignatureAlgorithm, error) {
+	algorithm, err := publicKeyAlgorithm(pubKey)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	pubKey := pubKeys[0]
-	var alg jose.SignatureAlgorithm
-	switch pubKey.(type) {
-	case *rsa.PublicKey:
-		alg = jose.RS256
+	switch algorithm {
+	case irsav1alpha1.SigningKeyECDSAP256:
+		return jose.ES256, nil
+	case irsav1alpha1.SigningKeyECDSAP384:
+		return jose.ES384, nil
+	case irsav1alpha1.SigningKeyECDSAP521:
+		return jose.ES512, nil
 	default:
-		return nil, errors.New("public key is not RSA")
+		return jose.RS256, nil
 	}
+}
 
-	kid, err := keyIDFromPublicKey(pubKey)
+// NewJWK builds a JWKS from PEM encoded public keys.
+// The first key is the active signing key and is additionally published without a key ID,
+// for tokens that were issued without a "kid" header.
+func NewJWK(pub []byte) (*JWK, error) {
+	pubKeys, err := keyutil.ParsePublicKeysPEM(pub)
 	if err != nil {
 		return nil, err
 	}
-
 	var keys []jose.JSONWebKey
-	keys = append(keys, jose.JSONWebKey{
-		Key:       pubKey,
-		KeyID:     kid,
-		Algorithm: string(alg),
-		Use:       "sig",
-	})
-	keys = append(keys, jose.JSONWebKey{
-		Key:       pubKey,
-		KeyID:     "",
-		Algorithm: string(alg),
-		Use:       "sig",
-	})
+	for i, pubKey := range pubKeys {
+		alg, err := signatureAlgorithm(pubKey)
+		if err != nil {
+			return nil, err
+		}
+
+		kid, err := keyIDFromPublicKey(pubKey)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, jose.JSONWebKey{
+			Key:       pubKey,
+			KeyID:     kid,
+			Algorithm: string(alg),
+			Use:       "sig",
+		})
+		if i == 0 {
+			keys = append(keys, jose.JSONWebKey{
+				Key:       pubKey,
+				KeyID:     "",
+				Algorithm: string(alg),
+				Use:       "sig",
+			})
+		}
+	}
 	return &JWK{Keys: keys}, nil
 }
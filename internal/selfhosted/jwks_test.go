@@ -7,7 +7,10 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-const rsaKeyID = "JHJehTTTZlsspKHT-GaJxK7Kd1NQgZJu3fyK6K_QDYU"
+const (
+	rsaKeyID   = "JHJehTTTZlsspKHT-GaJxK7Kd1NQgZJu3fyK6K_QDYU"
+	ecdsaKeyID = "SoABiieYuNx4UdqYvZRVeuC6SihxgLrhLy9peHMHpTc"
+)
 
 func TestJWK(t *testing.T) {
 	tests := []struct {
@@ -22,8 +25,13 @@ func TestJWK(t *testing.T) {
 			expected: rsaKeyID,
 		},
 		{
-			name:      "no rsa",
-			filename:  "testdata/ecdsa.pub",
+			name:     "ecdsa",
+			filename: "testdata/ecdsa.pub",
+			expected: ecdsaKeyID,
+		},
+		{
+			name:      "no public key",
+			filename:  "testdata/invalid.pub",
 			expectErr: true,
 		},
 	}
@@ -41,3 +49,58 @@ func TestJWK(t *testing.T) {
 		})
 	}
 }
+
+func TestJWKKeyIDs(t *testing.T) {
+	rsaPub, err := os.ReadFile("testdata/rsa.pub")
+	assert.NoError(t, err)
+	ecdsaPub, err := os.ReadFile("testdata/ecdsa.pub")
+	assert.NoError(t, err)
+	jwk, err := NewJWK(append(append([]byte{}, rsaPub...), ecdsaPub...))
+	assert.NoError(t, err)
+	expected, err := PublicKeyIDs(append(append([]byte{}, rsaPub...), ecdsaPub...))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, jwk.KeyIDs())
+}
+
+func TestMergeJWKs(t *testing.T) {
+	rsaPub, err := os.ReadFile("testdata/rsa.pub")
+	assert.NoError(t, err)
+	ecdsaPub, err := os.ReadFile("testdata/ecdsa.pub")
+	assert.NoError(t, err)
+	rsaJWK, err := NewJWK(rsaPub)
+	assert.NoError(t, err)
+	ecdsaJWK, err := NewJWK(ecdsaPub)
+	assert.NoError(t, err)
+	tests := []struct {
+		name     string
+		jwks     []*JWK
+		expected []string
+	}{
+		{
+			name:     "no JWKS",
+			expected: []string{},
+		},
+		{
+			name:     "keys without a key ID are left out",
+			jwks:     []*JWK{rsaJWK},
+			expected: []string{rsaKeyID},
+		},
+		{
+			name:     "keys of several clusters",
+			jwks:     []*JWK{rsaJWK, ecdsaJWK},
+			expected: []string{rsaKeyID, ecdsaKeyID},
+		},
+		{
+			name:     "duplicated keys",
+			jwks:     []*JWK{rsaJWK, ecdsaJWK, rsaJWK},
+			expected: []string{rsaKeyID, ecdsaKeyID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := MergeJWKs(tt.jwks...)
+			assert.Len(t, merged.Keys, len(tt.expected))
+			assert.Equal(t, tt.expected, merged.KeyIDs())
+		})
+	}
+}
@@ -1,10 +1,18 @@
 package selfhosted
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
+	"fmt"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"k8s.io/client-go/util/keyutil"
 )
 
 type KeyPair struct {
@@ -12,31 +20,139 @@ type KeyPair struct {
 	privateKey []byte
 }
 
-func CreateKeyPair() (*KeyPair, error) {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+// CreateKeyPair generates a new signing key pair with the given algorithm.
+// RSA private keys are encoded as PKCS #1 and ECDSA private keys as SEC 1, both of which the kube-apiserver accepts.
+func CreateKeyPair(algorithm irsav1alpha1.SigningKeyAlgorithm) (*KeyPair, error) {
+	var privateKey crypto.Signer
+	var privPem *pem.Block
+	var err error
+	switch algorithm {
+	case irsav1alpha1.SigningKeyRSA2048, "":
+		privateKey, privPem, err = generateRSAKey(2048)
+	case irsav1alpha1.SigningKeyRSA3072:
+		privateKey, privPem, err = generateRSAKey(3072)
+	case irsav1alpha1.SigningKeyRSA4096:
+		privateKey, privPem, err = generateRSAKey(4096)
+	case irsav1alpha1.SigningKeyECDSAP256:
+		privateKey, privPem, err = generateECDSAKey(elliptic.P256())
+	case irsav1alpha1.SigningKeyECDSAP384:
+		privateKey, privPem, err = generateECDSAKey(elliptic.P384())
+	case irsav1alpha1.SigningKeyECDSAP521:
+		privateKey, privPem, err = generateECDSAKey(elliptic.P521())
+	default:
+		return nil, fmt.Errorf("unsupported signing key algorithm: %s", algorithm)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// convert private key to PEM
-	privPem := pem.Block{
+	privPemBytes := pem.EncodeToMemory(privPem)
+
+	// convert public key to PKIX, ASN.1 DER
+	pubPemBytes, err := encodePublicKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{pubPemBytes, privPemBytes}, nil
+}
+
+func generateRSAKey(bits int) (crypto.Signer, *pem.Block, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, &pem.Block{
 		Type:  "RSA PRIVATE KEY",
 		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}, nil
+}
+
+func generateECDSAKey(curve elliptic.Curve) (crypto.Signer, *pem.Block, error) {
+	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
 	}
-	privPemBytes := pem.EncodeToMemory(&privPem)
+	return privateKey, &pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: der,
+	}, nil
+}
 
-	// convert public key to PKIX, ASN.1 DER
-	pubASN1, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+// KeyAlgorithm returns the algorithm of the first public key in the PEM encoded data.
+func KeyAlgorithm(pub []byte) (irsav1alpha1.SigningKeyAlgorithm, error) {
+	pubKeys, err := keyutil.ParsePublicKeysPEM(pub)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	return publicKeyAlgorithm(pubKeys[0])
+}
+
+// publicKeyAlgorithm returns the algorithm of a public key.
+// It is the single list of the supported key types, which the JWS algorithms of the JWKS are derived from.
+func publicKeyAlgorithm(pubKey interface{}) (irsav1alpha1.SigningKeyAlgorithm, error) {
+	switch k := pubKey.(type) {
+	case *rsa.PublicKey:
+		switch k.N.BitLen() {
+		case 2048:
+			return irsav1alpha1.SigningKeyRSA2048, nil
+		case 3072:
+			return irsav1alpha1.SigningKeyRSA3072, nil
+		case 4096:
+			return irsav1alpha1.SigningKeyRSA4096, nil
+		}
+		return "", fmt.Errorf("unsupported RSA key size: %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return irsav1alpha1.SigningKeyECDSAP256, nil
+		case elliptic.P384():
+			return irsav1alpha1.SigningKeyECDSAP384, nil
+		case elliptic.P521():
+			return irsav1alpha1.SigningKeyECDSAP521, nil
+		}
+		return "", fmt.Errorf("unsupported ECDSA curve: %s", k.Curve.Params().Name)
 	}
+	return "", fmt.Errorf("unsupported public key type: %T", pubKey)
+}
 
-	pubPem := pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: pubASN1,
+// ParsePublicKeys returns the PEM encoded public keys of a PEM encoded private key or public key bundle.
+// Every key must be of a type supported as service account signing key.
+func ParsePublicKeys(data []byte) ([]byte, error) {
+	if key, err := keyutil.ParsePrivateKeyPEM(data); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("the private key does not provide a public key")
+		}
+		pub, err := encodePublicKey(signer.Public())
+		if err != nil {
+			return nil, err
+		}
+		if _, err := KeyAlgorithm(pub); err != nil {
+			return nil, err
+		}
+		return pub, nil
 	}
-	pubPemBytes := pem.EncodeToMemory(&pubPem)
-	return &KeyPair{pubPemBytes, privPemBytes}, nil
+	pubKeys, err := keyutil.ParsePublicKeysPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("the data contains neither a valid private key nor valid public keys: %w", err)
+	}
+	var pubs []byte
+	for _, pubKey := range pubKeys {
+		pub, err := encodePublicKey(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := KeyAlgorithm(pub); err != nil {
+			return nil, err
+		}
+		pubs = append(pubs, pub...)
+	}
+	return pubs, nil
 }
 
 func (k *KeyPair) PublicKey() []byte {
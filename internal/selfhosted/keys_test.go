@@ -7,14 +7,16 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
+	"os"
 	"testing"
 
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestReadKey(t *testing.T) {
 	t.Run("key pair check", func(t *testing.T) {
-		keyPair, err := CreateKeyPair()
+		keyPair, err := CreateKeyPair(irsav1alpha1.SigningKeyRSA2048)
 		assert.NoError(t, err)
 
 		message := []byte("test message")
@@ -42,3 +44,126 @@ func TestReadKey(t *testing.T) {
 		assert.NoError(t, err, "failed to check signature")
 	})
 }
+
+func TestCreateKeyPairAlgorithms(t *testing.T) {
+	tests := []struct {
+		name        string
+		algorithm   irsav1alpha1.SigningKeyAlgorithm
+		expected    irsav1alpha1.SigningKeyAlgorithm
+		expectedAlg string
+	}{
+		{
+			name:        "default",
+			algorithm:   "",
+			expected:    irsav1alpha1.SigningKeyRSA2048,
+			expectedAlg: "RS256",
+		},
+		{
+			name:        "rsa 3072",
+			algorithm:   irsav1alpha1.SigningKeyRSA3072,
+			expected:    irsav1alpha1.SigningKeyRSA3072,
+			expectedAlg: "RS256",
+		},
+		{
+			name:        "rsa 4096",
+			algorithm:   irsav1alpha1.SigningKeyRSA4096,
+			expected:    irsav1alpha1.SigningKeyRSA4096,
+			expectedAlg: "RS256",
+		},
+		{
+			name:        "ecdsa p-256",
+			algorithm:   irsav1alpha1.SigningKeyECDSAP256,
+			expected:    irsav1alpha1.SigningKeyECDSAP256,
+			expectedAlg: "ES256",
+		},
+		{
+			name:        "ecdsa p-384",
+			algorithm:   irsav1alpha1.SigningKeyECDSAP384,
+			expected:    irsav1alpha1.SigningKeyECDSAP384,
+			expectedAlg: "ES384",
+		},
+		{
+			name:        "ecdsa p-521",
+			algorithm:   irsav1alpha1.SigningKeyECDSAP521,
+			expected:    irsav1alpha1.SigningKeyECDSAP521,
+			expectedAlg: "ES512",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyPair, err := CreateKeyPair(tt.algorithm)
+			assert.NoError(t, err)
+			actual, err := KeyAlgorithm(keyPair.PublicKey())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+
+			keys, err := LoadSigningKeys(keyPair.PrivateKey(), nil, keyPair.PublicKey())
+			assert.NoError(t, err, "the private key must be readable")
+			jwk, err := NewJWK(keys.PublicKeys())
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tt.expectedAlg}, jwk.SigningAlgorithms())
+		})
+	}
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := CreateKeyPair("DSA")
+		assert.Error(t, err)
+	})
+}
+
+func TestParsePublicKeys(t *testing.T) {
+	keyPair, err := CreateKeyPair(irsav1alpha1.SigningKeyECDSAP256)
+	assert.NoError(t, err)
+	rsaPub, err := os.ReadFile("testdata/rsa.pub")
+	assert.NoError(t, err)
+	ecdsaPub, err := os.ReadFile("testdata/ecdsa.pub")
+	assert.NoError(t, err)
+	invalid, err := os.ReadFile("testdata/invalid.pub")
+	assert.NoError(t, err)
+	rsaKID, err := KeyID(rsaPub)
+	assert.NoError(t, err)
+	ecdsaKID, err := KeyID(ecdsaPub)
+	assert.NoError(t, err)
+	privateKID, err := KeyID(keyPair.PublicKey())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:     "private key",
+			data:     keyPair.PrivateKey(),
+			expected: []string{privateKID},
+		},
+		{
+			name:     "public key",
+			data:     rsaPub,
+			expected: []string{rsaKID},
+		},
+		{
+			name:     "public key bundle",
+			data:     append(append([]byte{}, rsaPub...), ecdsaPub...),
+			expected: []string{rsaKID, ecdsaKID},
+		},
+		{
+			name:        "invalid key",
+			data:        invalid,
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pubs, err := ParsePublicKeys(tt.data)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			kids, err := PublicKeyIDs(pubs)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, kids)
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package nodeagent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kkb0318/irsa-manager/internal/manifests"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Agent saves the key files and sets the flags of the kube-apiserver on a control plane node.
+type Agent struct {
+	// KeyDir is where the key files are saved.
+	KeyDir string
+	// SecretDir is where the kube-system/irsa-manager-key Secret is mounted.
+	// The key files are not saved when it is empty.
+	SecretDir string
+	// ManifestPath is the static Pod manifest of the kube-apiserver.
+	ManifestPath string
+	// BackupDir is where the manifest is copied before it is patched.
+	BackupDir string
+	// APIServerFlags are the flags set in the manifest.
+	APIServerFlags []string
+	// Now returns the current time, used to name the backups and to wait for the restart of the kube-apiserver.
+	Now func() time.Time
+	// Lock serializes the restarts of the kube-apiserver across the control plane nodes.
+	// The manifest is patched without waiting for the other nodes when it is nil.
+	Lock RolloutLock
+	// Ready reports whether the kube-apiserver serves the readiness endpoint at url.
+	// The restart of the kube-apiserver is not waited for when it is nil.
+	Ready func(ctx context.Context, url string) bool
+	// RestartTimeout is how long the kube-apiserver may take to be restarted and ready again after the manifest has been patched.
+	RestartTimeout time.Duration
+	// PollInterval is the period between two readiness checks while waiting for the restart.
+	PollInterval time.Duration
+}
+
+// RolloutLock is held by the control plane node whose kube-apiserver is being restarted.
+type RolloutLock interface {
+	// Acquire takes or renews the lock for the node, and reports false while another node holds it.
+	Acquire(ctx context.Context) (bool, error)
+	// Held reports whether the node holds the lock.
+	Held(ctx context.Context) (bool, error)
+	// Release releases the lock if the node holds it.
+	Release(ctx context.Context) error
+}
+
+// ErrRolloutLocked is returned when the manifest has to be patched while another node is restarting its kube-apiserver.
+var ErrRolloutLocked = errors.New("waiting for another control plane node to restart its kube-apiserver")
+
+// Sync saves the key files and patches the manifest when they are not up to date.
+// The manifest is only patched while holding the Lock, which is released once the kube-apiserver is ready again,
+// so that the kube-apiservers of the control plane nodes are restarted one at a time.
+// It reports whether the manifest has been patched.
+func (a *Agent) Sync(ctx context.Context) (bool, error) {
+	keyHash, err := a.syncKeyFiles()
+	if err != nil {
+		return false, err
+	}
+	data, patched, err := a.patchManifest(keyHash)
+	if err != nil {
+		return false, err
+	}
+	if patched == nil {
+		// the lock is still held when the agent has been restarted while waiting for the kube-apiserver
+		return false, a.release(ctx, readyzURL(patched, data))
+	}
+	if a.Lock != nil {
+		acquired, err := a.Lock.Acquire(ctx)
+		if err != nil {
+			return false, err
+		}
+		if !acquired {
+			return false, ErrRolloutLocked
+		}
+	}
+	if err := a.writeManifest(data, patched); err != nil {
+		return false, err
+	}
+	if err := a.waitRestarted(ctx, readyzURL(patched, nil)); err != nil {
+		return true, err
+	}
+	if a.Lock != nil {
+		return true, a.Lock.Release(ctx)
+	}
+	return true, nil
+}
+
+// release releases the lock held by the node once its kube-apiserver is ready,
+// and renews it otherwise, so that the other nodes are not restarted while the kube-apiserver of the node is failing.
+func (a *Agent) release(ctx context.Context, url string) error {
+	if a.Lock == nil {
+		return nil
+	}
+	held, err := a.Lock.Held(ctx)
+	if err != nil || !held {
+		return err
+	}
+	if a.Ready != nil && !a.Ready(ctx, url) {
+		if _, err := a.Lock.Acquire(ctx); err != nil {
+			return err
+		}
+		return fmt.Errorf("the kube-apiserver is not ready at %s", url)
+	}
+	return a.Lock.Release(ctx)
+}
+
+// waitRestarted waits until the kube-apiserver has been restarted by the kubelet and is ready again.
+// If it stays ready during RestartTimeout, e.g. because the kubelet has restarted it between two checks, it is considered restarted.
+func (a *Agent) waitRestarted(ctx context.Context, url string) error {
+	if a.Ready == nil {
+		return nil
+	}
+	deadline := a.Now().Add(a.RestartTimeout)
+	restarting := false
+	for {
+		ready := a.Ready(ctx, url)
+		if !ready {
+			restarting = true
+		}
+		if ready && (restarting || a.Now().After(deadline)) {
+			return nil
+		}
+		if !ready && a.Now().After(deadline) {
+			return fmt.Errorf("the kube-apiserver is not ready at %s %s after its manifest has been patched", url, a.RestartTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(a.PollInterval):
+		}
+	}
+}
+
+// syncKeyFiles copies the keys of the Secret into KeyDir and returns their hash.
+func (a *Agent) syncKeyFiles() (string, error) {
+	if a.SecretDir == "" {
+		return "", nil
+	}
+	privateKey, err := os.ReadFile(path.Join(a.SecretDir, corev1.SSHAuthPrivateKey))
+	if err != nil {
+		return "", err
+	}
+	publicKeys, err := os.ReadFile(path.Join(a.SecretDir, manifests.SigningPublicKeysKey))
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileIfChanged(path.Join(a.KeyDir, selfhosted.SigningKeyFileName), privateKey, 0600); err != nil {
+		return "", err
+	}
+	if err := writeFileIfChanged(path.Join(a.KeyDir, selfhosted.PublicKeyFileName), publicKeys, 0644); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append(privateKey, publicKeys...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// patchManifest returns the manifest and the patched Pod, or a nil Pod when the manifest is up to date.
+func (a *Agent) patchManifest(keyHash string) ([]byte, *corev1.Pod, error) {
+	data, err := os.ReadFile(a.ManifestPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	pod := &corev1.Pod{}
+	if err := yaml.Unmarshal(data, pod); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", a.ManifestPath, err)
+	}
+	patched := pod.DeepCopy()
+	i := apiServerContainer(patched)
+	if i < 0 {
+		return nil, nil, fmt.Errorf("the kube-apiserver container is not found in %s", a.ManifestPath)
+	}
+	patched.Spec.Containers[i].Command = MergeFlags(patched.Spec.Containers[i].Command, a.APIServerFlags)
+	if keyHash != "" {
+		if patched.Annotations == nil {
+			patched.Annotations = map[string]string{}
+		}
+		patched.Annotations[KeyHashAnnotation] = keyHash
+	}
+	if equality.Semantic.DeepEqual(pod, patched) {
+		return data, nil, nil
+	}
+	return data, patched, nil
+}
+
+// writeManifest backs up the manifest and replaces it with the patched Pod.
+func (a *Agent) writeManifest(data []byte, patched *corev1.Pod) error {
+	out, err := yaml.Marshal(patched)
+	if err != nil {
+		return err
+	}
+	backup := path.Join(a.BackupDir, fmt.Sprintf("%s.%s", path.Base(a.ManifestPath), a.Now().UTC().Format("20060102T150405Z")))
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", a.ManifestPath, err)
+	}
+	// the kubelet ignores the hidden files of the manifest directory, so the temporary file is never run
+	tmp := path.Join(path.Dir(a.ManifestPath), "."+path.Base(a.ManifestPath)+".irsa-manager")
+	if err := os.WriteFile(tmp, out, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.ManifestPath)
+}
+
+func apiServerContainer(pod *corev1.Pod) int {
+	return slices.IndexFunc(pod.Spec.Containers, func(c corev1.Container) bool {
+		return c.Name == "kube-apiserver"
+	})
+}
+
+// readyzURL returns the readiness endpoint of the kube-apiserver run by the manifest,
+// taken from the readiness probe set by kubeadm, or the local secure port otherwise.
+// The manifest is parsed from data when pod is nil.
+func readyzURL(pod *corev1.Pod, data []byte) string {
+	if pod == nil {
+		pod = &corev1.Pod{}
+		_ = yaml.Unmarshal(data, pod)
+	}
+	host, port, scheme, readyzPath := "127.0.0.1", "6443", "https", "/readyz"
+	if i := apiServerContainer(pod); i >= 0 {
+		container := pod.Spec.Containers[i]
+		for _, arg := range container.Command {
+			if name, value, ok := splitFlag(arg); ok && name == "secure-port" {
+				port = value
+			}
+		}
+		if probe := container.ReadinessProbe; probe != nil && probe.HTTPGet != nil {
+			get := probe.HTTPGet
+			if get.Host != "" {
+				host = get.Host
+			}
+			if get.Port.Type == intstr.Int {
+				port = get.Port.String()
+			}
+			if get.Scheme != "" {
+				scheme = strings.ToLower(string(get.Scheme))
+			}
+			if get.Path != "" {
+				readyzPath = get.Path
+			}
+		}
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, port), readyzPath)
+}
+
+func writeFileIfChanged(name string, data []byte, perm os.FileMode) error {
+	current, err := os.ReadFile(name)
+	if err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	tmp := path.Join(path.Dir(name), "."+path.Base(name)+".irsa-manager")
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// singleValueFlags replace the value set in the manifest.
+var singleValueFlags = []string{"service-account-signing-key-file"}
+
+// listFlags hold comma separated values, merged with the values set in the manifest.
+var listFlags = []string{"api-audiences"}
+
+// MergeFlags sets the flags in the arguments of the kube-apiserver.
+// The values of a flag that can be repeated, e.g. --service-account-issuer, come first,
+// followed by the values already set that are not part of them, so that the tokens issued before stay valid.
+// Merging the result again gives the same arguments.
+func MergeFlags(args []string, flags []string) []string {
+	names := []string{}
+	values := map[string][]string{}
+	for _, flag := range flags {
+		name, value, ok := splitFlag(flag)
+		if !ok {
+			continue
+		}
+		if _, found := values[name]; !found {
+			names = append(names, name)
+		}
+		values[name] = append(values[name], value)
+	}
+	merged := []string{}
+	existing := map[string][]string{}
+	for _, arg := range args {
+		name, value, ok := splitFlag(arg)
+		if _, managed := values[name]; ok && managed {
+			existing[name] = append(existing[name], value)
+			continue
+		}
+		merged = append(merged, arg)
+	}
+	for _, name := range names {
+		v := values[name]
+		switch {
+		case slices.Contains(singleValueFlags, name):
+			v = v[len(v)-1:]
+		case slices.Contains(listFlags, name):
+			v = []string{strings.Join(union(splitLists(v), splitLists(existing[name])), ",")}
+		default:
+			v = union(v, existing[name])
+		}
+		for _, value := range v {
+			merged = append(merged, fmt.Sprintf("--%s=%s", name, value))
+		}
+	}
+	return merged
+}
+
+func splitFlag(arg string) (string, string, bool) {
+	if !strings.HasPrefix(arg, "--") {
+		return "", "", false
+	}
+	return strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+}
+
+func splitLists(lists []string) []string {
+	values := []string{}
+	for _, list := range lists {
+		for _, v := range strings.Split(list, ",") {
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+func union(a, b []string) []string {
+	values := []string{}
+	for _, v := range append(append([]string{}, a...), b...) {
+		if !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// ReportStatus records the progress of the node agent of a node in the status ConfigMap of the node agents.
+func ReportStatus(ctx context.Context, c client.Client, nodeName string, report Report) error {
+	obj := &corev1.ConfigMap{}
+	if err := c.Get(ctx, StatusConfigMapName, obj); err != nil {
+		return err
+	}
+	// the ConfigMap is only patched when the progress changes, so LastUpdateTime is the time of the last change
+	if current, err := ParseStatus(obj, nodeName); err == nil && current != nil &&
+		current.Configured == report.Configured && current.Message == report.Message {
+		return nil
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	patch := client.MergeFrom(obj.DeepCopy())
+	if obj.Data == nil {
+		obj.Data = map[string]string{}
+	}
+	obj.Data[nodeName] = string(data)
+	return c.Patch(ctx, obj, patch)
+}
+
+// ParseStatus returns the progress reported by the node agent of a node in the status ConfigMap, or nil when it has not reported yet.
+func ParseStatus(obj *corev1.ConfigMap, nodeName string) (*Report, error) {
+	data, ok := obj.Data[nodeName]
+	if !ok {
+		return nil, nil
+	}
+	report := &Report{}
+	if err := json.Unmarshal([]byte(data), report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ReadyzProbe reports whether the readiness endpoint of the kube-apiserver at url answers with 200 OK.
+// The serving certificate is not verified, as only the readiness of the kube-apiserver of the node is read.
+func ReadyzProbe(ctx context.Context, url string) bool {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := readyzClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+var readyzClient = &http.Client{
+	Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+}
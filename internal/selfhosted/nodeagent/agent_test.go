@@ -0,0 +1,286 @@
+package nodeagent
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestMergeFlags(t *testing.T) {
+	flags := []string{
+		"--service-account-issuer=https://oidc.example.com",
+		"--service-account-issuer=https://kubernetes.default.svc.cluster.local",
+		"--api-audiences=sts.amazonaws.com,https://kubernetes.default.svc.cluster.local",
+		"--service-account-key-file=/etc/kubernetes/pki/irsa-manager.pub",
+		"--service-account-key-file=/etc/kubernetes/pki/sa.pub",
+		"--service-account-signing-key-file=/etc/kubernetes/pki/irsa-manager.key",
+	}
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{
+			name: "kubeadm defaults",
+			args: []string{
+				"kube-apiserver",
+				"--advertise-address=192.168.0.10",
+				"--service-account-issuer=https://kubernetes.default.svc.cluster.local",
+				"--service-account-key-file=/etc/kubernetes/pki/sa.pub",
+				"--service-account-signing-key-file=/etc/kubernetes/pki/sa.key",
+			},
+			expected: []string{
+				"kube-apiserver",
+				"--advertise-address=192.168.0.10",
+				"--service-account-issuer=https://oidc.example.com",
+				"--service-account-issuer=https://kubernetes.default.svc.cluster.local",
+				"--api-audiences=sts.amazonaws.com,https://kubernetes.default.svc.cluster.local",
+				"--service-account-key-file=/etc/kubernetes/pki/irsa-manager.pub",
+				"--service-account-key-file=/etc/kubernetes/pki/sa.pub",
+				"--service-account-signing-key-file=/etc/kubernetes/pki/irsa-manager.key",
+			},
+		},
+		{
+			name: "values set outside of irsa-manager are kept",
+			args: []string{
+				"kube-apiserver",
+				"--api-audiences=vault,https://kubernetes.default.svc.cluster.local",
+				"--service-account-issuer=https://old.example.com",
+				"--service-account-key-file=/etc/kubernetes/pki/old.pub",
+			},
+			expected: []string{
+				"kube-apiserver",
+				"--service-account-issuer=https://oidc.example.com",
+				"--service-account-issuer=https://kubernetes.default.svc.cluster.local",
+				"--service-account-issuer=https://old.example.com",
+				"--api-audiences=sts.amazonaws.com,https://kubernetes.default.svc.cluster.local,vault",
+				"--service-account-key-file=/etc/kubernetes/pki/irsa-manager.pub",
+				"--service-account-key-file=/etc/kubernetes/pki/sa.pub",
+				"--service-account-key-file=/etc/kubernetes/pki/old.pub",
+				"--service-account-signing-key-file=/etc/kubernetes/pki/irsa-manager.key",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := MergeFlags(tt.args, flags)
+			assert.Equal(t, tt.expected, merged)
+			assert.Equal(t, merged, MergeFlags(merged, flags))
+		})
+	}
+}
+
+func TestAgentSync(t *testing.T) {
+	dir := t.TempDir()
+	keyDir := path.Join(dir, "pki")
+	secretDir := path.Join(dir, "secret")
+	manifestDir := path.Join(dir, "manifests")
+	backupDir := path.Join(dir, "backup")
+	for _, d := range []string{keyDir, secretDir, manifestDir, backupDir} {
+		assert.NoError(t, os.Mkdir(d, 0755))
+	}
+	manifest, err := os.ReadFile("testdata/kube-apiserver.yaml")
+	assert.NoError(t, err)
+	manifestPath := path.Join(manifestDir, "kube-apiserver.yaml")
+	assert.NoError(t, os.WriteFile(manifestPath, manifest, 0600))
+	writeKeys := func(key string) {
+		assert.NoError(t, os.WriteFile(path.Join(secretDir, "ssh-privatekey"), []byte(key+"-private"), 0600))
+		assert.NoError(t, os.WriteFile(path.Join(secretDir, "ssh-publickey"), []byte(key+"-public"), 0600))
+	}
+	now := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	agent := &Agent{
+		KeyDir:       keyDir,
+		SecretDir:    secretDir,
+		ManifestPath: manifestPath,
+		BackupDir:    backupDir,
+		APIServerFlags: []string{
+			"--service-account-issuer=https://oidc.example.com",
+			"--service-account-key-file=" + path.Join(keyDir, "irsa-manager.pub"),
+			"--service-account-signing-key-file=" + path.Join(keyDir, "irsa-manager.key"),
+		},
+		Now: func() time.Time { return now },
+	}
+	readManifest := func() *corev1.Pod {
+		data, err := os.ReadFile(manifestPath)
+		assert.NoError(t, err)
+		pod := &corev1.Pod{}
+		assert.NoError(t, yaml.Unmarshal(data, pod))
+		return pod
+	}
+
+	writeKeys("first")
+	patched, err := agent.Sync(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, patched)
+	key, err := os.ReadFile(path.Join(keyDir, "irsa-manager.key"))
+	assert.NoError(t, err)
+	assert.Equal(t, "first-private", string(key))
+	backup, err := os.ReadFile(path.Join(backupDir, "kube-apiserver.yaml.20240801T000000Z"))
+	assert.NoError(t, err)
+	assert.Equal(t, manifest, backup)
+	pod := readManifest()
+	assert.Equal(t, []string{
+		"kube-apiserver",
+		"--advertise-address=192.168.0.10",
+		"--api-audiences=https://kubernetes.default.svc.cluster.local",
+		"--tls-cert-file=/etc/kubernetes/pki/apiserver.crt",
+		"--service-account-issuer=https://oidc.example.com",
+		"--service-account-issuer=https://kubernetes.default.svc.cluster.local",
+		"--service-account-key-file=" + path.Join(keyDir, "irsa-manager.pub"),
+		"--service-account-key-file=/etc/kubernetes/pki/sa.pub",
+		"--service-account-signing-key-file=" + path.Join(keyDir, "irsa-manager.key"),
+	}, pod.Spec.Containers[0].Command)
+	firstHash := pod.Annotations[KeyHashAnnotation]
+	assert.NotEmpty(t, firstHash)
+
+	// nothing changes as long as the keys and the flags are the same
+	patched, err = agent.Sync(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, patched)
+
+	// the kube-apiserver is restarted when the keys are rotated
+	writeKeys("second")
+	now = now.Add(time.Hour)
+	patched, err = agent.Sync(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, patched)
+	assert.NotEqual(t, firstHash, readManifest().Annotations[KeyHashAnnotation])
+	_, err = os.Stat(path.Join(backupDir, "kube-apiserver.yaml.20240801T010000Z"))
+	assert.NoError(t, err)
+	entries, err := os.ReadDir(manifestDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestAgentSyncRollout(t *testing.T) {
+	dir := t.TempDir()
+	manifest, err := os.ReadFile("testdata/kube-apiserver.yaml")
+	assert.NoError(t, err)
+	manifestPath := path.Join(dir, "kube-apiserver.yaml")
+	assert.NoError(t, os.WriteFile(manifestPath, manifest, 0600))
+	lock := &fakeLock{node: "control-plane-1", holder: "control-plane-2"}
+	// the kube-apiserver is down on the first check after the manifest has been patched
+	probes := []bool{false, true}
+	urls := []string{}
+	agent := &Agent{
+		ManifestPath:   manifestPath,
+		BackupDir:      dir,
+		APIServerFlags: []string{"--service-account-issuer=https://oidc.example.com"},
+		Now:            time.Now,
+		Lock:           lock,
+		Ready: func(_ context.Context, url string) bool {
+			urls = append(urls, url)
+			ready := probes[0]
+			if len(probes) > 1 {
+				probes = probes[1:]
+			}
+			return ready
+		},
+		RestartTimeout: time.Minute,
+		PollInterval:   time.Millisecond,
+	}
+
+	// the manifest is left as it is while another node is restarting its kube-apiserver
+	patched, err := agent.Sync(context.Background())
+	assert.ErrorIs(t, err, ErrRolloutLocked)
+	assert.False(t, patched)
+	current, err := os.ReadFile(manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, manifest, current)
+
+	// the lock is released once the kube-apiserver has been restarted and is ready again
+	lock.holder = ""
+	patched, err = agent.Sync(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, patched)
+	assert.Equal(t, []string{"https://192.168.0.10:6443/readyz", "https://192.168.0.10:6443/readyz"}, urls)
+	assert.Equal(t, "", lock.holder)
+	assert.Equal(t, 1, lock.acquired)
+
+	// the lock is kept while the kube-apiserver is not ready after its restart
+	assert.NoError(t, os.WriteFile(manifestPath, manifest, 0600))
+	probes = []bool{false}
+	agent.RestartTimeout = 10 * time.Millisecond
+	patched, err = agent.Sync(context.Background())
+	assert.Error(t, err)
+	assert.True(t, patched)
+	assert.Equal(t, "control-plane-1", lock.holder)
+	patched, err = agent.Sync(context.Background())
+	assert.Error(t, err)
+	assert.False(t, patched)
+	assert.Equal(t, "control-plane-1", lock.holder)
+	probes = []bool{true}
+	patched, err = agent.Sync(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, patched)
+	assert.Equal(t, "", lock.holder)
+}
+
+func TestReadyzURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		expected string
+	}{
+		{
+			name:     "readiness probe",
+			manifest: "testdata/kube-apiserver.yaml",
+			expected: "https://192.168.0.10:6443/readyz",
+		},
+		{
+			name: "secure port",
+			manifest: `spec:
+  containers:
+  - name: kube-apiserver
+    command:
+    - kube-apiserver
+    - --secure-port=8443
+`,
+			expected: "https://127.0.0.1:8443/readyz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(tt.manifest)
+			if strings.HasPrefix(tt.manifest, "testdata/") {
+				var err error
+				data, err = os.ReadFile(tt.manifest)
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, readyzURL(nil, data))
+		})
+	}
+}
+
+type fakeLock struct {
+	node     string
+	holder   string
+	acquired int
+}
+
+func (l *fakeLock) Acquire(_ context.Context) (bool, error) {
+	if l.holder != "" && l.holder != l.node {
+		return false, nil
+	}
+	l.holder = l.node
+	l.acquired++
+	return true, nil
+}
+
+func (l *fakeLock) Held(_ context.Context) (bool, error) {
+	return l.holder == l.node, nil
+}
+
+func (l *fakeLock) Release(_ context.Context) error {
+	if l.holder == l.node {
+		l.holder = ""
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package nodeagent
+
+import (
+	"github.com/kkb0318/irsa-manager/internal/manifests"
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type baseManifestFactory struct {
+	daemonSetMeta      types.NamespacedName
+	serviceAccountMeta types.NamespacedName
+	roleMeta           types.NamespacedName
+	statusMeta         types.NamespacedName
+	leaseMeta          types.NamespacedName
+	podLabel           map[string]string
+}
+
+const (
+	NODE_AGENT_NAME        = "irsa-manager-node-agent"
+	NODE_AGENT_STATUS_NAME = "irsa-manager-node-agent-status"
+	NODE_AGENT_NAMESPACE   = "kube-system"
+)
+
+// PodLabel is the label of the node agent Pods.
+var PodLabel = map[string]string{"app": "irsa-manager-node-agent"}
+
+func newBaseManifestFactory() *baseManifestFactory {
+	return &baseManifestFactory{
+		daemonSetMeta: types.NamespacedName{
+			Name:      NODE_AGENT_NAME,
+			Namespace: NODE_AGENT_NAMESPACE,
+		},
+		serviceAccountMeta: types.NamespacedName{
+			Name:      NODE_AGENT_NAME,
+			Namespace: NODE_AGENT_NAMESPACE,
+		},
+		roleMeta: types.NamespacedName{
+			Name:      NODE_AGENT_NAME,
+			Namespace: NODE_AGENT_NAMESPACE,
+		},
+		statusMeta: StatusConfigMapName,
+		leaseMeta:  LeaseName,
+		podLabel:   PodLabel,
+	}
+}
+
+func (b *baseManifestFactory) daemonSet() *appsv1.DaemonSet {
+	privileged := true
+	runAsUser := int64(0)
+	return &appsv1.DaemonSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "DaemonSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.daemonSetMeta.Name,
+			Namespace: b.daemonSetMeta.Namespace,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: b.podLabel,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: b.podLabel,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: b.serviceAccountMeta.Name,
+					PriorityClassName:  "system-node-critical",
+					// the readiness of the kube-apiserver is checked on the addresses of the node
+					HostNetwork: true,
+					DNSPolicy:   corev1.DNSClusterFirstWithHostNet,
+					// NodeSelector: map[string]string{}, // NodeSelector must be patched
+					Tolerations: []corev1.Toleration{
+						{
+							Key:      "node-role.kubernetes.io/control-plane",
+							Operator: corev1.TolerationOpExists,
+							Effect:   corev1.TaintEffectNoSchedule,
+						},
+						{
+							Key:      "node-role.kubernetes.io/master",
+							Operator: corev1.TolerationOpExists,
+							Effect:   corev1.TaintEffectNoSchedule,
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name: "node-agent",
+							// Image: "", // Image must be patched
+							Command: []string{"/nodeagent"},
+							// Args: []string{}, // Args must be patched
+							Env: []corev1.EnvVar{
+								{
+									Name: "NODE_NAME",
+									ValueFrom: &corev1.EnvVarSource{
+										FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+									},
+								},
+							},
+							SecurityContext: &corev1.SecurityContext{
+								Privileged: &privileged,
+								RunAsUser:  &runAsUser,
+							},
+							// VolumeMounts: []corev1.VolumeMount{}, // VolumeMounts must be patched
+						},
+					},
+					// Volumes: []corev1.Volume{}, // Volumes must be patched
+				},
+			},
+		},
+	}
+}
+
+func (b *baseManifestFactory) serviceAccount() *corev1.ServiceAccount {
+	return manifests.NewServiceAccountBuilder().Build(b.serviceAccountMeta)
+}
+
+// role allows the node agents to report their progress in the status ConfigMap and to hold the Lease of the rollout.
+func (b *baseManifestFactory) role() *rbacv1.Role {
+	return &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind:       "Role",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.roleMeta.Name,
+			Namespace: b.roleMeta.Namespace,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{""},
+				Resources:     []string{"configmaps"},
+				ResourceNames: []string{b.statusMeta.Name},
+				Verbs:         []string{"get", "patch"},
+			},
+			{
+				APIGroups:     []string{coordinationv1.GroupName},
+				Resources:     []string{"leases"},
+				ResourceNames: []string{b.leaseMeta.Name},
+				Verbs:         []string{"get", "update"},
+			},
+		},
+	}
+}
+
+// statusConfigMap is where the node agents report their progress, under the names of their nodes.
+func (b *baseManifestFactory) statusConfigMap() *corev1.ConfigMap {
+	return manifests.NewConfigMapBuilder().Build(b.statusMeta)
+}
+
+// lease is held by the node agent whose node is restarting its kube-apiserver.
+// It is created empty, and its holder is only set by the node agents.
+func (b *baseManifestFactory) lease() *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: coordinationv1.SchemeGroupVersion.String(),
+			Kind:       "Lease",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.leaseMeta.Name,
+			Namespace: b.leaseMeta.Namespace,
+		},
+	}
+}
+
+func (b *baseManifestFactory) roleBinding() *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind:       "RoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.roleMeta.Name,
+			Namespace: b.roleMeta.Namespace,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.SchemeGroupVersion.Group,
+			Kind:     "Role",
+			Name:     b.roleMeta.Name,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      b.serviceAccountMeta.Name,
+				Namespace: b.serviceAccountMeta.Namespace,
+			},
+		},
+	}
+}
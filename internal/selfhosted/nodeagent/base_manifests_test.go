@@ -0,0 +1,108 @@
+package nodeagent
+
+import (
+	"os"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestBaseManifests(t *testing.T) {
+	b := newBaseManifestFactory()
+	tests := []struct {
+		name         string
+		runFunc      func() client.Object
+		expected     string
+		expectedFunc func() client.Object
+	}{
+		{
+			name: "daemonset",
+			runFunc: func() client.Object {
+				return b.daemonSet()
+			},
+			expected:     "testdata/daemonset.yaml",
+			expectedFunc: testDaemonSet,
+		},
+		{
+			name: "serviceaccount",
+			runFunc: func() client.Object {
+				return b.serviceAccount()
+			},
+			expected:     "testdata/serviceaccount.yaml",
+			expectedFunc: testServiceAccount,
+		},
+		{
+			name: "role",
+			runFunc: func() client.Object {
+				return b.role()
+			},
+			expected:     "testdata/role.yaml",
+			expectedFunc: testRole,
+		},
+		{
+			name: "rolebinding",
+			runFunc: func() client.Object {
+				return b.roleBinding()
+			},
+			expected:     "testdata/rolebinding.yaml",
+			expectedFunc: testRoleBinding,
+		},
+		{
+			name: "statusconfigmap",
+			runFunc: func() client.Object {
+				return b.statusConfigMap()
+			},
+			expected:     "testdata/statusconfigmap.yaml",
+			expectedFunc: testConfigMap,
+		},
+		{
+			name: "lease",
+			runFunc: func() client.Object {
+				return b.lease()
+			},
+			expected:     "testdata/lease.yaml",
+			expectedFunc: testLease,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := tt.runFunc()
+			data, err := os.ReadFile(tt.expected)
+			assert.NoError(t, err)
+			expected := tt.expectedFunc()
+			err = yaml.UnmarshalWithOptions(data, expected, yaml.UseJSONUnmarshaler())
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func testDaemonSet() client.Object {
+	return &appsv1.DaemonSet{}
+}
+
+func testServiceAccount() client.Object {
+	return &corev1.ServiceAccount{}
+}
+
+func testRole() client.Object {
+	return &rbacv1.Role{}
+}
+
+func testRoleBinding() client.Object {
+	return &rbacv1.RoleBinding{}
+}
+
+func testConfigMap() client.Object {
+	return &corev1.ConfigMap{}
+}
+
+func testLease() client.Object {
+	return &coordinationv1.Lease{}
+}
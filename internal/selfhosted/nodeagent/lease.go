@@ -0,0 +1,78 @@
+package nodeagent
+
+import (
+	"context"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LeaseLock is a RolloutLock backed by the Lease created by the controller.
+// A Lease that has not been renewed for Duration is taken over, e.g. when the node agent holding it has been deleted.
+type LeaseLock struct {
+	Client client.Client
+	Lease  types.NamespacedName
+	// Identity is the holder of the Lease, i.e. the name of the node.
+	Identity string
+	Duration time.Duration
+	Now      func() time.Time
+}
+
+func (l *LeaseLock) Acquire(ctx context.Context) (bool, error) {
+	lease := &coordinationv1.Lease{}
+	if err := l.Client.Get(ctx, l.Lease, lease); err != nil {
+		return false, err
+	}
+	if holder := lease.Spec.HolderIdentity; holder != nil && *holder != "" && *holder != l.Identity && !l.expired(lease) {
+		return false, nil
+	}
+	now := metav1.NewMicroTime(l.Now())
+	duration := int32(l.Duration.Seconds())
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.Identity {
+		lease.Spec.AcquireTime = &now
+	}
+	lease.Spec.HolderIdentity = &l.Identity
+	lease.Spec.LeaseDurationSeconds = &duration
+	lease.Spec.RenewTime = &now
+	// the update fails with a conflict when another node has taken the Lease in the meantime
+	if err := l.Client.Update(ctx, lease); err != nil {
+		if apierrors.IsConflict(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *LeaseLock) Held(ctx context.Context) (bool, error) {
+	lease := &coordinationv1.Lease{}
+	if err := l.Client.Get(ctx, l.Lease, lease); err != nil {
+		return false, err
+	}
+	return lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == l.Identity, nil
+}
+
+func (l *LeaseLock) Release(ctx context.Context) error {
+	lease := &coordinationv1.Lease{}
+	if err := l.Client.Get(ctx, l.Lease, lease); err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.Identity {
+		return nil
+	}
+	lease.Spec.HolderIdentity = nil
+	lease.Spec.AcquireTime = nil
+	lease.Spec.RenewTime = nil
+	return l.Client.Update(ctx, lease)
+}
+
+func (l *LeaseLock) expired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return l.Now().After(lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second))
+}
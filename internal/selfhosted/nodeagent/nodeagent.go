@@ -0,0 +1,135 @@
+package nodeagent
+
+import (
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StatusConfigMapName is the ConfigMap in which each node agent reports its progress as a JSON encoded Report,
+// under the name of its node.
+var StatusConfigMapName = types.NamespacedName{Name: NODE_AGENT_STATUS_NAME, Namespace: NODE_AGENT_NAMESPACE}
+
+// LeaseName is the Lease held by the node agent whose node is restarting its kube-apiserver.
+var LeaseName = types.NamespacedName{Name: NODE_AGENT_NAME, Namespace: NODE_AGENT_NAMESPACE}
+
+// KeyHashAnnotation is set in the static Pod manifest of the kube-apiserver to the hash of the key files,
+// so that the kube-apiserver is restarted when the keys are rotated.
+const KeyHashAnnotation = "irsa-manager.kkb0318.github.io/key-hash"
+
+// OwnerAnnotation is set on the node agent resources to the namespaced name of the IRSASetup that deployed them,
+// since the node agents of a cluster are deployed by a single IRSASetup.
+const OwnerAnnotation = "irsa-manager.kkb0318.github.io/node-agent-owner"
+
+// SecretDir is where the kube-system/irsa-manager-key Secret is mounted in the node agent Pods.
+const SecretDir = "/var/run/irsa-manager/key"
+
+// Report is the progress of a node agent.
+type Report struct {
+	Configured     bool        `json:"configured"`
+	Message        string      `json:"message,omitempty"`
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+// Options configures the node agents.
+type Options struct {
+	Image        string
+	KeyDir       string
+	ManifestPath string
+	BackupDir    string
+	NodeSelector map[string]string
+	// APIServerFlags are the flags set in the static Pod manifest of the kube-apiserver.
+	APIServerFlags []string
+	// KeySecretName is the Secret holding the key files saved in KeyDir.
+	// The key files are not saved when it is empty.
+	KeySecretName string
+	// Owner is the namespaced name of the IRSASetup deploying the node agents, set in OwnerAnnotation.
+	Owner string
+}
+
+type NodeAgentSetup struct {
+	resources []client.Object
+}
+
+func (n *NodeAgentSetup) Resources() []client.Object {
+	return n.resources
+}
+
+func NewNodeAgentSetup(opts Options) *NodeAgentSetup {
+	base := newBaseManifestFactory()
+	ds := base.daemonSet()
+	podSpec := &ds.Spec.Template.Spec
+	podSpec.NodeSelector = opts.NodeSelector
+	container := &podSpec.Containers[0]
+	container.Image = opts.Image
+	container.Args = []string{
+		fmt.Sprintf("--manifest-path=%s", opts.ManifestPath),
+		fmt.Sprintf("--backup-dir=%s", opts.BackupDir),
+	}
+	hostPathDirectoryOrCreate := corev1.HostPathDirectoryOrCreate
+	hostPathDirectory := corev1.HostPathDirectory
+	manifestDir := path.Dir(opts.ManifestPath)
+	podSpec.Volumes = []corev1.Volume{
+		{
+			Name: "manifest-dir",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: manifestDir, Type: &hostPathDirectory},
+			},
+		},
+		{
+			Name: "backup-dir",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: opts.BackupDir, Type: &hostPathDirectoryOrCreate},
+			},
+		},
+	}
+	container.VolumeMounts = []corev1.VolumeMount{
+		{Name: "manifest-dir", MountPath: manifestDir},
+		{Name: "backup-dir", MountPath: opts.BackupDir},
+	}
+	if opts.KeySecretName != "" {
+		container.Args = append(container.Args,
+			fmt.Sprintf("--key-dir=%s", opts.KeyDir),
+			fmt.Sprintf("--secret-dir=%s", SecretDir),
+		)
+		podSpec.Volumes = append(podSpec.Volumes,
+			corev1.Volume{
+				Name: "key-dir",
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{Path: opts.KeyDir, Type: &hostPathDirectoryOrCreate},
+				},
+			},
+			corev1.Volume{
+				Name: "key",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: opts.KeySecretName},
+				},
+			},
+		)
+		container.VolumeMounts = append(container.VolumeMounts,
+			corev1.VolumeMount{Name: "key-dir", MountPath: opts.KeyDir},
+			corev1.VolumeMount{Name: "key", MountPath: SecretDir, ReadOnly: true},
+		)
+	}
+	for _, flag := range opts.APIServerFlags {
+		container.Args = append(container.Args, fmt.Sprintf("--apiserver-flag=%s", flag))
+	}
+	resources := []client.Object{
+		base.serviceAccount(),
+		base.role(),
+		base.roleBinding(),
+		base.statusConfigMap(),
+		base.lease(),
+		ds,
+	}
+	if opts.Owner != "" {
+		for _, r := range resources {
+			r.SetAnnotations(map[string]string{OwnerAnnotation: opts.Owner})
+		}
+	}
+	return &NodeAgentSetup{resources: resources}
+}
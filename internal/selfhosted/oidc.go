@@ -8,7 +8,8 @@ import (
 
 type OIDCIdP interface {
 	Create(ctx context.Context) error
-	IsUpdate() (bool, error)
+	// IsUpdate reports whether the IdP differs from the expected state and needs to be updated.
+	IsUpdate(ctx context.Context) (bool, error)
 	Update(ctx context.Context) error
 	Delete(ctx context.Context) error
 }
@@ -22,6 +23,10 @@ type OIDCIdPDiscoveryContents interface {
 type OIDCIdPDiscovery interface {
 	CreateStorage(ctx context.Context) error
 	Upload(ctx context.Context, o OIDCIdPDiscoveryContents, forceUpdate bool) error
+	// IsUpdate reports whether the uploaded contents differ from o and need to be uploaded again.
+	IsUpdate(ctx context.Context, o OIDCIdPDiscoveryContents) (bool, error)
+	// PublishedJWK returns the JWKS that is currently published, or nil if it has not been uploaded.
+	PublishedJWK(ctx context.Context, o OIDCIdPDiscoveryContents) (*JWK, error)
 	Delete(ctx context.Context, o OIDCIdPDiscoveryContents) error
 }
 
@@ -0,0 +1,115 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
+)
+
+// CloudFrontDistribution serves the discovery documents of a private S3 bucket through a CloudFront distribution.
+type CloudFrontDistribution struct {
+	cloudFrontClient *awsclient.AwsCloudFrontClient
+	s3               irsav1alpha1.S3Discovery
+	// name is the namespaced name of the IRSASetup, which makes the distribution recognizable.
+	name string
+	// uid is the UID of the IRSASetup, which identifies the distribution and the Origin Access Control created by irsa-manager
+	// even across clusters sharing an AWS account.
+	uid string
+}
+
+// NewCloudFrontDistribution initializes a new instance of CloudFrontDistribution for the specified S3 bucket.
+// name is the namespaced name and uid the UID of the IRSASetup.
+func NewCloudFrontDistribution(awsConfig awsclient.AwsClient, s3 irsav1alpha1.S3Discovery, name, uid string) *CloudFrontDistribution {
+	return &CloudFrontDistribution{
+		cloudFrontClient: awsConfig.CloudFrontClient(),
+		s3:               s3,
+		name:             name,
+		uid:              uid,
+	}
+}
+
+// Ensure returns the distribution serving the S3 bucket.
+// An existing distribution specified by its ID is used as is, and so is the distribution recorded in the status.
+// Otherwise, the distribution and its Origin Access Control are created unless they have already been created for this IRSASetup.
+func (c *CloudFrontDistribution) Ensure(ctx context.Context, recorded *irsav1alpha1.CloudFrontStatus) (*irsav1alpha1.CloudFrontStatus, error) {
+	cloudFront := c.s3.CloudFront
+	if cloudFront.DistributionID != "" {
+		d, err := c.cloudFrontClient.GetDistribution(ctx, cloudFront.DistributionID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get distribution %s, %w", cloudFront.DistributionID, err)
+		}
+		return newCloudFrontStatus(d, ""), nil
+	}
+	if recorded.IsManaged() {
+		d, err := c.cloudFrontClient.GetDistribution(ctx, recorded.DistributionID)
+		if err == nil {
+			return newCloudFrontStatus(d, recorded.OriginAccessControlID), nil
+		}
+		if !awsclient.IsNoSuchDistribution(err) {
+			return nil, fmt.Errorf("unable to get distribution %s, %w", recorded.DistributionID, err)
+		}
+	}
+	if cloudFront.Alias != "" && cloudFront.CertificateArn == "" {
+		return nil, fmt.Errorf("certificateArn must not be empty when alias is set")
+	}
+	oacID, err := c.cloudFrontClient.CreateOriginAccessControl(ctx, c.originAccessControlName())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create origin access control, %w", err)
+	}
+	d, err := c.cloudFrontClient.FindDistribution(ctx, c.comment())
+	if err != nil {
+		return nil, fmt.Errorf("unable to list distributions, %w", err)
+	}
+	if d == nil {
+		d, err = c.cloudFrontClient.CreateDistribution(ctx, awsclient.DistributionInput{
+			Comment:               c.comment(),
+			OriginDomainName:      awsclient.S3OriginDomainName(c.s3.Region, c.s3.BucketName),
+			OriginAccessControlID: oacID,
+			Alias:                 cloudFront.Alias,
+			CertificateArn:        cloudFront.CertificateArn,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to create distribution, %w", err)
+		}
+	}
+	return newCloudFrontStatus(d, oacID), nil
+}
+
+// Delete deletes the distribution and the Origin Access Control if they were created by irsa-manager.
+// It returns awsclient.ErrDistributionInProgress until the distribution has been disabled.
+func (c *CloudFrontDistribution) Delete(ctx context.Context, status *irsav1alpha1.CloudFrontStatus) error {
+	if !status.IsManaged() {
+		return nil
+	}
+	if err := c.cloudFrontClient.DeleteDistribution(ctx, status.DistributionID); err != nil {
+		return err
+	}
+	return c.cloudFrontClient.DeleteOriginAccessControl(ctx, status.OriginAccessControlID)
+}
+
+// comment returns the comment the distribution is found by, which is limited to 128 characters.
+// It starts with the UID, so that it stays unique when the namespaced name is truncated.
+func (c *CloudFrontDistribution) comment() string {
+	comment := fmt.Sprintf("irsa-manager %s %s", c.uid, c.name)
+	if len(comment) > 128 {
+		comment = comment[:128]
+	}
+	return comment
+}
+
+// originAccessControlName returns the name of the Origin Access Control.
+// Every IRSASetup has its own one, so that it can be deleted together with the distribution.
+func (c *CloudFrontDistribution) originAccessControlName() string {
+	return fmt.Sprintf("irsa-manager-%s", c.uid)
+}
+
+func newCloudFrontStatus(d *awsclient.Distribution, oacID string) *irsav1alpha1.CloudFrontStatus {
+	return &irsav1alpha1.CloudFrontStatus{
+		DistributionID:        d.ID,
+		DistributionArn:       d.ARN,
+		DomainName:            d.DomainName,
+		OriginAccessControlID: oacID,
+	}
+}
@@ -3,44 +3,109 @@ package oidc
 import (
 	"context"
 
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
 	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
+	"github.com/kkb0318/irsa-manager/internal/handler"
 	"github.com/kkb0318/irsa-manager/internal/issuer"
 	"github.com/kkb0318/irsa-manager/internal/selfhosted"
 )
 
 type AwsS3IdPFactory struct {
-	region       string
-	bucketName   string
+	s3           irsav1alpha1.S3Discovery
+	cloudFront   *irsav1alpha1.CloudFrontStatus
+	memberID     string
+	idpOptions   AwsIdPOptions
 	awsClient    awsclient.AwsClient
 	jwk          *selfhosted.JWK
 	jwksFileName string
+	s3Options    []func(*awss3.Options)
 }
 
 func NewAwsS3IdpFactory(
 	ctx context.Context,
-	region,
-	bucketName string,
+	s3 irsav1alpha1.S3Discovery,
+	cloudFront *irsav1alpha1.CloudFrontStatus,
+	memberID string,
 	jwk *selfhosted.JWK,
 	jwksFileName string,
+	idpOptions AwsIdPOptions,
 	awsClient awsclient.AwsClient,
+	s3Options ...func(*awss3.Options),
 ) (*AwsS3IdPFactory, error) {
 	return &AwsS3IdPFactory{
-		region:       region,
-		bucketName:   bucketName,
+		s3:           s3,
+		cloudFront:   cloudFront,
+		memberID:     memberID,
+		idpOptions:   idpOptions,
 		awsClient:    awsClient,
 		jwk:          jwk,
 		jwksFileName: jwksFileName,
+		s3Options:    s3Options,
 	}, nil
 }
 
 func (f *AwsS3IdPFactory) IdP(i issuer.OIDCIssuerMeta) (selfhosted.OIDCIdP, error) {
-	return NewAwsIdP(f.awsClient, i)
+	idp, err := NewAwsIdP(f.awsClient, i, f.idpOptions)
+	if err != nil {
+		return nil, err
+	}
+	if f.s3.SharedJWKS {
+		return &sharedAwsIdP{idp, f.s3IdPDiscovery()}, nil
+	}
+	return idp, nil
 }
 
 func (f *AwsS3IdPFactory) IdPDiscovery() selfhosted.OIDCIdPDiscovery {
-	return NewS3IdPDiscovery(f.awsClient, f.region, f.bucketName)
+	return f.s3IdPDiscovery()
+}
+
+func (f *AwsS3IdPFactory) s3IdPDiscovery() *S3IdPDiscovery {
+	return NewS3IdPDiscovery(f.awsClient, f.s3, f.cloudFront, f.memberID, f.jwksFileName, f.s3Options...)
 }
 
 func (f *AwsS3IdPFactory) IdPDiscoveryContents(i issuer.OIDCIssuerMeta) selfhosted.OIDCIdPDiscoveryContents {
 	return NewIdPDiscoveryContents(f.jwk, i, f.jwksFileName)
 }
+
+// InClusterIdPFactory serves the discovery documents from a server in the cluster,
+// while the IAM OIDC provider is still created in AWS.
+type InClusterIdPFactory struct {
+	inCluster    irsav1alpha1.InClusterDiscovery
+	kubeClient   handler.KubernetesClient
+	idpOptions   AwsIdPOptions
+	awsClient    awsclient.AwsClient
+	jwk          *selfhosted.JWK
+	jwksFileName string
+}
+
+func NewInClusterIdPFactory(
+	inCluster irsav1alpha1.InClusterDiscovery,
+	jwk *selfhosted.JWK,
+	jwksFileName string,
+	idpOptions AwsIdPOptions,
+	awsClient awsclient.AwsClient,
+	kubeClient handler.KubernetesClient,
+) *InClusterIdPFactory {
+	return &InClusterIdPFactory{
+		inCluster:    inCluster,
+		kubeClient:   kubeClient,
+		idpOptions:   idpOptions,
+		awsClient:    awsClient,
+		jwk:          jwk,
+		jwksFileName: jwksFileName,
+	}
+}
+
+func (f *InClusterIdPFactory) IdP(i issuer.OIDCIssuerMeta) (selfhosted.OIDCIdP, error) {
+	return NewAwsIdP(f.awsClient, i, f.idpOptions)
+}
+
+func (f *InClusterIdPFactory) IdPDiscovery() selfhosted.OIDCIdPDiscovery {
+	return NewInClusterIdPDiscovery(f.kubeClient, f.inCluster, f.jwksFileName)
+}
+
+func (f *InClusterIdPFactory) IdPDiscoveryContents(i issuer.OIDCIssuerMeta) selfhosted.OIDCIdPDiscoveryContents {
+	return NewIdPDiscoveryContents(f.jwk, i, f.jwksFileName)
+}
@@ -2,6 +2,9 @@ package oidc
 
 import (
 	"context"
+	"log"
+	"slices"
+	"strings"
 
 	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
 	"github.com/kkb0318/irsa-manager/internal/issuer"
@@ -11,28 +14,153 @@ type AwsIdP struct {
 	iamClient  *awsclient.AwsIamClient
 	stsClient  *awsclient.AwsStsClient
 	issuerMeta issuer.OIDCIssuerMeta
+	options    AwsIdPOptions
 }
 
-func NewAwsIdP(awsConfig awsclient.AwsClient, issuerMeta issuer.OIDCIssuerMeta) (*AwsIdP, error) {
+// AwsIdPOptions configures the client IDs and the thumbprints of the IAM OIDC provider.
+type AwsIdPOptions struct {
+	// Audiences are the client IDs registered in addition to the STS client ID.
+	Audiences []string
+	// RegisteredClientIDs are the client IDs registered by irsa-manager before.
+	// The ones that are no longer in Audiences are removed from the provider.
+	RegisteredClientIDs []string
+	// InUseClientIDs are the client IDs used by the IRSAs bound to the IRSASetup, which are never removed from the provider.
+	InUseClientIDs []string
+	// Thumbprints are registered as they are when they are set.
+	Thumbprints []string
+	// Thumbprint computes the thumbprint of an issuer that is not served by AWS when Thumbprints are not set.
+	Thumbprint func(ctx context.Context, issuerUrl string) (string, error)
+}
+
+func NewAwsIdP(awsConfig awsclient.AwsClient, issuerMeta issuer.OIDCIssuerMeta, options AwsIdPOptions) (*AwsIdP, error) {
 	iamClient := awsConfig.IamClient()
 	stsClient := awsConfig.StsClient()
-	return &AwsIdP{iamClient, stsClient, issuerMeta}, nil
+	return &AwsIdP{iamClient, stsClient, issuerMeta, options}, nil
 }
 
+// Create creates the IAM OIDC provider, or reconciles its client IDs and thumbprints if it already exists.
 func (a *AwsIdP) Create(ctx context.Context) error {
-	err := a.iamClient.CreateOIDCProvider(ctx, a.issuerMeta.IssuerUrl())
+	return a.Update(ctx)
+}
+
+// Update creates the IAM OIDC provider if it does not exist, adds the missing client IDs,
+// removes the client IDs that irsa-manager registered but are no longer wanted and replaces the thumbprints if they differ.
+func (a *AwsIdP) Update(ctx context.Context) error {
+	accountId, err := a.stsClient.GetAccountId()
 	if err != nil {
 		return err
 	}
+	provider, err := a.iamClient.GetOIDCProvider(ctx, accountId, a.issuerMeta.IssuerHostPath())
+	if err != nil {
+		return err
+	}
+	thumbprints, err := a.thumbprints(ctx)
+	if err != nil {
+		return err
+	}
+	if provider == nil {
+		return a.iamClient.CreateOIDCProvider(ctx, a.issuerMeta.IssuerUrl(), a.clientIDs(), thumbprints)
+	}
+	for _, clientID := range a.clientIDs() {
+		if slices.Contains(provider.ClientIDList, clientID) {
+			continue
+		}
+		if err := a.iamClient.AddOIDCProviderClientID(ctx, accountId, a.issuerMeta.IssuerHostPath(), clientID); err != nil {
+			return err
+		}
+	}
+	for _, clientID := range a.staleClientIDs(provider.ClientIDList) {
+		if err := a.iamClient.RemoveOIDCProviderClientID(ctx, accountId, a.issuerMeta.IssuerHostPath(), clientID); err != nil {
+			return err
+		}
+	}
+	if len(thumbprints) > 0 && !sameThumbprints(provider.ThumbprintList, thumbprints) {
+		return a.iamClient.UpdateOIDCProviderThumbprints(ctx, accountId, a.issuerMeta.IssuerHostPath(), thumbprints)
+	}
 	return nil
 }
 
-func (a *AwsIdP) Update(ctx context.Context) error {
-	return nil
+// IsUpdate reports whether the IAM OIDC provider does not exist, is missing some of the client IDs,
+// still has client IDs that are no longer wanted or has other thumbprints.
+func (a *AwsIdP) IsUpdate(ctx context.Context) (bool, error) {
+	accountId, err := a.stsClient.GetAccountId()
+	if err != nil {
+		return false, err
+	}
+	provider, err := a.iamClient.GetOIDCProvider(ctx, accountId, a.issuerMeta.IssuerHostPath())
+	if err != nil {
+		return false, err
+	}
+	if provider == nil {
+		return true, nil
+	}
+	for _, clientID := range a.clientIDs() {
+		if !slices.Contains(provider.ClientIDList, clientID) {
+			return true, nil
+		}
+	}
+	if len(a.staleClientIDs(provider.ClientIDList)) > 0 {
+		return true, nil
+	}
+	thumbprints, err := a.thumbprints(ctx)
+	if err != nil {
+		return false, err
+	}
+	return len(thumbprints) > 0 && !sameThumbprints(provider.ThumbprintList, thumbprints), nil
+}
+
+// clientIDs returns the STS client ID followed by the audiences, without duplicates.
+func (a *AwsIdP) clientIDs() []string {
+	clientIDs := []string{awsclient.STSAudience}
+	for _, audience := range a.options.Audiences {
+		if !slices.Contains(clientIDs, audience) {
+			clientIDs = append(clientIDs, audience)
+		}
+	}
+	return clientIDs
+}
+
+// staleClientIDs returns the client IDs of the provider that irsa-manager registered but are no longer wanted.
+// The STS client ID and the client IDs in use by IRSAs are always kept.
+func (a *AwsIdP) staleClientIDs(providerClientIDs []string) []string {
+	clientIDs := a.clientIDs()
+	stale := []string{}
+	for _, clientID := range a.options.RegisteredClientIDs {
+		if slices.Contains(clientIDs, clientID) || slices.Contains(a.options.InUseClientIDs, clientID) ||
+			!slices.Contains(providerClientIDs, clientID) || slices.Contains(stale, clientID) {
+			continue
+		}
+		stale = append(stale, clientID)
+	}
+	return stale
+}
+
+// thumbprints returns the thumbprints to register, or nil if the issuer is served by AWS and needs none.
+func (a *AwsIdP) thumbprints(ctx context.Context) ([]string, error) {
+	if len(a.options.Thumbprints) > 0 {
+		return a.options.Thumbprints, nil
+	}
+	if a.issuerMeta.ServedByAWS() || a.options.Thumbprint == nil {
+		return nil, nil
+	}
+	thumbprint, err := a.options.Thumbprint(ctx, a.issuerMeta.IssuerUrl())
+	if err != nil {
+		return nil, err
+	}
+	return []string{thumbprint}, nil
 }
 
-func (a *AwsIdP) IsUpdate() (bool, error) {
-	return false, nil
+// sameThumbprints reports whether both lists have the same thumbprints, ignoring their order and case.
+func sameThumbprints(a, b []string) bool {
+	normalize := func(thumbprints []string) []string {
+		n := make([]string, 0, len(thumbprints))
+		for _, t := range thumbprints {
+			n = append(n, strings.ToLower(t))
+		}
+		slices.Sort(n)
+		return slices.Compact(n)
+	}
+	return slices.Equal(normalize(a), normalize(b))
 }
 
 func (a *AwsIdP) Delete(ctx context.Context) error {
@@ -42,3 +170,22 @@ func (a *AwsIdP) Delete(ctx context.Context) error {
 	}
 	return a.iamClient.DeleteOIDCProvider(ctx, accountId, a.issuerMeta.IssuerHostPath())
 }
+
+// sharedAwsIdP is the IAM OIDC provider of an issuer shared by several clusters.
+// It is kept as long as other clusters are sharing the JWKS.
+type sharedAwsIdP struct {
+	*AwsIdP
+	discovery *S3IdPDiscovery
+}
+
+func (a *sharedAwsIdP) Delete(ctx context.Context) error {
+	members, err := a.discovery.members(ctx)
+	if err != nil {
+		return err
+	}
+	if len(members) > 0 {
+		log.Printf("Deletion skipped: the IAM OIDC provider is still used by %d clusters sharing the JWKS \n", len(members))
+		return nil
+	}
+	return a.AwsIdP.Delete(ctx)
+}
@@ -1,9 +1,15 @@
 package oidc
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"path"
 
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
 	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
 	"github.com/kkb0318/irsa-manager/internal/selfhosted"
 )
@@ -11,19 +17,53 @@ import (
 const CONFIGURATION_PATH = ".well-known/openid-configuration"
 
 type S3IdPDiscovery struct {
-	s3Client *awsclient.AwsS3Client
+	s3Client      *awsclient.AwsS3Client
+	prefix        string
+	access        irsav1alpha1.S3Access
+	tags          map[string]string
+	viaCloudFront bool
+	cloudFront    *irsav1alpha1.CloudFrontStatus
+	sharedJWKS    bool
+	memberID      string
+	jwksFileName  string
 }
 
-// NewS3IdPDiscovery initializes a new instance of S3IdPCreator with the specified AWS region and bucket name.
-// This function attempts to create an AWS client configured for the specified region.
-func NewS3IdPDiscovery(awsConfig awsclient.AwsClient, region, bucketName string) *S3IdPDiscovery {
-	s3Client := awsConfig.S3Client(region, bucketName)
-	return &S3IdPDiscovery{s3Client}
+// NewS3IdPDiscovery initializes a new instance of S3IdPCreator with the specified S3 bucket settings.
+// This function attempts to create an AWS client configured for the bucket's region.
+// cloudFront is the distribution serving the bucket, and is only used when S3Discovery.CloudFront is set.
+// memberID identifies the keys of this cluster in a JWKS shared with other clusters, and is only used when S3Discovery.SharedJWKS is set.
+// s3Options are applied to the S3 client, e.g. to use an S3-compatible storage.
+func NewS3IdPDiscovery(awsConfig awsclient.AwsClient, s3 irsav1alpha1.S3Discovery, cloudFront *irsav1alpha1.CloudFrontStatus, memberID, jwksFileName string, s3Options ...func(*awss3.Options)) *S3IdPDiscovery {
+	s3Client := awsConfig.S3Client(s3.Region, s3.BucketName, s3Options...)
+	return &S3IdPDiscovery{
+		s3Client:      s3Client,
+		prefix:        s3.Prefix,
+		access:        s3.Access,
+		tags:          s3.Tags,
+		viaCloudFront: s3.CloudFront != nil,
+		cloudFront:    cloudFront,
+		sharedJWKS:    s3.SharedJWKS,
+		memberID:      memberID,
+		jwksFileName:  jwksFileName,
+	}
 }
 
-// CreateStorage creates an S3 bucket
+// CreateStorage creates an S3 bucket.
+// With the BucketPolicy access, ACLs are disabled and only the discovery documents are readable by anyone.
+// With CloudFront, all public access is blocked and only the distribution can read the discovery documents.
+// With CloudFront and a prefix, the statements of the distributions of the other clusters sharing the bucket are kept in the bucket policy.
 func (s *S3IdPDiscovery) CreateStorage(ctx context.Context) error {
-	err := s.s3Client.CreateBucketPublic(ctx)
+	var err error
+	if s.viaCloudFront {
+		if s.cloudFront == nil {
+			return fmt.Errorf("unable to create bucket, the CloudFront distribution has not been set up yet")
+		}
+		err = s.s3Client.CreateBucketForCloudFront(ctx, s.policyKeys(), s.cloudFront.DistributionArn, s.prefix != "", s.tags)
+	} else if s.usesBucketPolicy() {
+		err = s.s3Client.CreateBucketWithPolicy(ctx, s.policyKeys(), s.tags)
+	} else {
+		err = s.s3Client.CreateBucketPublic(ctx, s.tags)
+	}
 	if err != nil {
 		return fmt.Errorf("unable to create bucket, %w", err)
 	}
@@ -32,7 +72,11 @@ func (s *S3IdPDiscovery) CreateStorage(ctx context.Context) error {
 
 // Upload uploads the OIDC provider's discovery configuration and JSON Web Key Set (JWKS) to the specified AWS S3 bucket.
 // This method is responsible for uploading the necessary OIDC configuration files to S3, making them accessible for OIDC clients.
+// With a shared JWKS, the keys of this cluster are merged into the JWKS instead.
 func (s *S3IdPDiscovery) Upload(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents, forceUpdate bool) error {
+	if s.sharedJWKS {
+		return s.uploadShared(ctx, o)
+	}
 	discovery, err := o.Discovery()
 	if err != nil {
 		return nil
@@ -43,17 +87,28 @@ func (s *S3IdPDiscovery) Upload(ctx context.Context, o selfhosted.OIDCIdPDiscove
 	}
 	inputs := []awsclient.ObjectInput{
 		{
-			Key:  CONFIGURATION_PATH,
+			Key:  s.key(CONFIGURATION_PATH),
 			Body: discovery,
 		},
 		{
-			Key:  o.JWKsFileName(),
+			Key:  s.key(o.JWKsFileName()),
 			Body: jwk,
 		},
 	}
-	if forceUpdate {
+	return s.uploadObjects(ctx, inputs, forceUpdate)
+}
+
+// uploadObjects uploads the objects readable by anyone. Unless forceUpdate is set, existing objects are left as they are.
+func (s *S3IdPDiscovery) uploadObjects(ctx context.Context, inputs []awsclient.ObjectInput, forceUpdate bool) error {
+	var err error
+	switch {
+	case s.usesBucketPolicy() && forceUpdate:
+		err = s.s3Client.PutObjects(ctx, inputs)
+	case s.usesBucketPolicy():
+		err = s.s3Client.CreateObjects(ctx, inputs)
+	case forceUpdate:
 		err = s.s3Client.PutObjectsPublic(ctx, inputs)
-	} else {
+	default:
 		err = s.s3Client.CreateObjectsPublic(ctx, inputs)
 	}
 	if err != nil {
@@ -62,18 +117,102 @@ func (s *S3IdPDiscovery) Upload(ctx context.Context, o selfhosted.OIDCIdPDiscove
 	return nil
 }
 
-// Delete delete an S3 bucket and objects
+// IsUpdate reports whether the OIDC provider's discovery configuration or JWKS in the S3 bucket
+// is missing or differs from the expected contents.
+func (s *S3IdPDiscovery) IsUpdate(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents) (bool, error) {
+	if s.sharedJWKS {
+		return s.isUpdateShared(ctx, o)
+	}
+	discovery, err := o.Discovery()
+	if err != nil {
+		return false, err
+	}
+	jwk, err := o.JWK()
+	if err != nil {
+		return false, err
+	}
+	expected := map[string][]byte{
+		s.key(CONFIGURATION_PATH): discovery,
+		s.key(o.JWKsFileName()):   jwk,
+	}
+	for key, body := range expected {
+		actual, err := s.s3Client.GetObject(ctx, key)
+		if err != nil {
+			return false, fmt.Errorf("unable to get object %s, %w", key, err)
+		}
+		if !bytes.Equal(actual, body) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PublishedJWK returns the JWKS in the S3 bucket, or nil if it does not exist.
+// With a shared JWKS, only the keys of this cluster are returned.
+func (s *S3IdPDiscovery) PublishedJWK(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents) (*selfhosted.JWK, error) {
+	if s.sharedJWKS {
+		return s.publishedSharedJWK(ctx, o)
+	}
+	key := s.key(o.JWKsFileName())
+	body, err := s.s3Client.GetObject(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get object %s, %w", key, err)
+	}
+	if body == nil {
+		return nil, nil
+	}
+	jwk := &selfhosted.JWK{}
+	if err := json.Unmarshal(body, jwk); err != nil {
+		return nil, fmt.Errorf("unable to parse object %s, %w", key, err)
+	}
+	return jwk, nil
+}
+
+// Delete deletes the objects, and the S3 bucket unless other objects such as the ones under other prefixes are left in it.
+// With a shared JWKS, only the keys of this cluster are removed while other clusters are left.
 func (s *S3IdPDiscovery) Delete(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents) error {
+	if s.sharedJWKS {
+		remaining, err := s.deleteShared(ctx)
+		if err != nil || remaining {
+			return err
+		}
+	}
 	err := s.s3Client.DeleteObjects(ctx, []string{
-		CONFIGURATION_PATH,
-		o.JWKsFileName(),
+		s.key(CONFIGURATION_PATH),
+		s.key(o.JWKsFileName()),
 	})
 	if err != nil {
 		return err
 	}
-	err = s.s3Client.DeleteBucket(ctx)
+	if s.viaCloudFront && s.prefix != "" && s.cloudFront != nil {
+		if err := s.s3Client.RemoveCloudFrontReadPolicy(ctx, s.cloudFront.DistributionArn); err != nil {
+			return fmt.Errorf("unable to update the bucket policy, %w", err)
+		}
+	}
+	err = s.s3Client.DeleteBucketIfEmpty(ctx)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// usesBucketPolicy reports whether the objects are uploaded without ACLs.
+func (s *S3IdPDiscovery) usesBucketPolicy() bool {
+	return s.viaCloudFront || s.access == irsav1alpha1.S3AccessBucketPolicy
+}
+
+// key returns the key of the object with the given name under the prefix.
+func (s *S3IdPDiscovery) key(name string) string {
+	return path.Join(s.prefix, name)
+}
+
+// policyKeys returns the keys of the objects that the bucket policy grants read access to.
+// With a prefix, the bucket is supposed to be shared, so the discovery information under every prefix is granted
+// and all clusters sharing the bucket put the same policy.
+// With CloudFront, every cluster has a statement of its own granting its distribution the objects under its prefix only.
+func (s *S3IdPDiscovery) policyKeys() []string {
+	if s.prefix == "" || s.viaCloudFront {
+		return []string{s.key(CONFIGURATION_PATH), s.key(s.jwksFileName)}
+	}
+	return []string{path.Join("*", CONFIGURATION_PATH), path.Join("*", s.jwksFileName)}
+}
@@ -35,7 +35,7 @@ func (p *IdPDiscoveryContents) Discovery() ([]byte, error) {
 		AuthorizationEndpoint:            "urn:kubernetes:programmatic_authorization",
 		ResponseTypesSupported:           []string{"id_token"},
 		SubjectTypesSupported:            []string{"public"},
-		IDTokenSigningAlgValuesSupported: []string{"RS256"},
+		IDTokenSigningAlgValuesSupported: p.jwk.SigningAlgorithms(),
 		ClaimsSupported:                  []string{"sub", "iss"},
 	}
 	jsonData, err := json.MarshalIndent(oidcConfig, "", "  ")
@@ -45,6 +45,17 @@ func (p *IdPDiscoveryContents) Discovery() ([]byte, error) {
 	return jsonData, nil
 }
 
+// withSigningAlgorithms returns the discovery document advertising the given signing algorithms,
+// e.g. the ones of a JWKS shared with other clusters.
+func withSigningAlgorithms(discovery []byte, algorithms []string) ([]byte, error) {
+	oidcConfig := oidcDiscoveryConfiguration{}
+	if err := json.Unmarshal(discovery, &oidcConfig); err != nil {
+		return nil, fmt.Errorf("unable to parse the discovery document, %w", err)
+	}
+	oidcConfig.IDTokenSigningAlgValuesSupported = algorithms
+	return json.MarshalIndent(oidcConfig, "", "  ")
+}
+
 func (p *IdPDiscoveryContents) JWK() ([]byte, error) {
 	jsonData, err := json.MarshalIndent(p.jwk, "", "  ")
 	if err != nil {
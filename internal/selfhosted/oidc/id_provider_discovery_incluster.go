@@ -0,0 +1,154 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/kkb0318/irsa-manager/internal/handler"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+)
+
+// InClusterIdPDiscovery serves the OIDC provider's discovery information from a server in the cluster.
+// The storage is a ConfigMap mounted into an nginx Deployment, which is exposed through a Service and an Ingress.
+type InClusterIdPDiscovery struct {
+	kubeClient   handler.KubernetesClient
+	manifests    *inClusterManifestFactory
+	jwksFileName string
+}
+
+func NewInClusterIdPDiscovery(kubeClient handler.KubernetesClient, inCluster irsav1alpha1.InClusterDiscovery, jwksFileName string) *InClusterIdPDiscovery {
+	return &InClusterIdPDiscovery{
+		kubeClient:   kubeClient,
+		manifests:    newInClusterManifestFactory(inCluster),
+		jwksFileName: jwksFileName,
+	}
+}
+
+// CreateStorage applies the Deployment, its PodDisruptionBudget, the Service and the Ingress serving the discovery documents.
+// They are applied every time, so that changes of the spec and drifted resources are reconciled.
+func (s *InClusterIdPDiscovery) CreateStorage(ctx context.Context) error {
+	kubeHandler := handler.NewKubernetesHandler(s.kubeClient)
+	for _, obj := range s.serverResources() {
+		kubeHandler.Append(obj)
+	}
+	if _, err := kubeHandler.ApplyAll(ctx); err != nil {
+		return fmt.Errorf("unable to apply the in-cluster discovery server, %w", err)
+	}
+	return nil
+}
+
+// Upload stores the discovery document and the JWKS in the ConfigMap.
+// Unless forceUpdate is set, an existing ConfigMap is left as it is.
+func (s *InClusterIdPDiscovery) Upload(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents, forceUpdate bool) error {
+	discovery, err := o.Discovery()
+	if err != nil {
+		return err
+	}
+	jwk, err := o.JWK()
+	if err != nil {
+		return err
+	}
+	configMap := s.manifests.configMap(map[string]string{
+		configurationKey: string(discovery),
+		o.JWKsFileName(): string(jwk),
+		nginxConfigKey:   nginxConfig,
+	})
+	kubeHandler := handler.NewKubernetesHandler(s.kubeClient)
+	kubeHandler.Append(configMap)
+	if forceUpdate {
+		_, err = kubeHandler.ApplyAll(ctx)
+	} else {
+		err = kubeHandler.CreateAll(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to store the discovery documents, %w", err)
+	}
+	return nil
+}
+
+// IsUpdate reports whether the discovery document or the JWKS in the ConfigMap is missing or differs from the expected contents.
+func (s *InClusterIdPDiscovery) IsUpdate(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents) (bool, error) {
+	discovery, err := o.Discovery()
+	if err != nil {
+		return false, err
+	}
+	jwk, err := o.JWK()
+	if err != nil {
+		return false, err
+	}
+	configMap, err := s.getConfigMap(ctx)
+	if err != nil {
+		return false, err
+	}
+	if configMap == nil {
+		return true, nil
+	}
+	return configMap.Data[configurationKey] != string(discovery) ||
+		configMap.Data[o.JWKsFileName()] != string(jwk) ||
+		configMap.Data[nginxConfigKey] != nginxConfig, nil
+}
+
+// PublishedJWK returns the JWKS in the ConfigMap, or nil if it does not exist.
+func (s *InClusterIdPDiscovery) PublishedJWK(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents) (*selfhosted.JWK, error) {
+	configMap, err := s.getConfigMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if configMap == nil {
+		return nil, nil
+	}
+	body, ok := configMap.Data[o.JWKsFileName()]
+	if !ok {
+		return nil, nil
+	}
+	jwk := &selfhosted.JWK{}
+	if err := json.Unmarshal([]byte(body), jwk); err != nil {
+		return nil, fmt.Errorf("unable to parse %s in the ConfigMap, %w", o.JWKsFileName(), err)
+	}
+	return jwk, nil
+}
+
+// Delete deletes the in-cluster discovery server and the ConfigMap.
+func (s *InClusterIdPDiscovery) Delete(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents) error {
+	kubeHandler := handler.NewKubernetesHandler(s.kubeClient)
+	for _, obj := range s.serverResources() {
+		kubeHandler.Append(obj)
+	}
+	kubeHandler.Append(s.manifests.configMap(nil))
+	if _, err := kubeHandler.DeleteAll(ctx); err != nil {
+		return fmt.Errorf("unable to delete the in-cluster discovery server, %w", err)
+	}
+	return nil
+}
+
+func (s *InClusterIdPDiscovery) serverResources() []client.Object {
+	return []client.Object{
+		s.manifests.deployment(s.jwksFileName),
+		s.manifests.podDisruptionBudget(),
+		s.manifests.service(),
+		s.manifests.ingress(),
+	}
+}
+
+// getConfigMap returns the ConfigMap holding the discovery documents, or nil if it does not exist.
+func (s *InClusterIdPDiscovery) getConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	u, err := s.kubeClient.Get(ctx, s.manifests.configMap(nil))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to get the ConfigMap of the discovery documents, %w", err)
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, configMap); err != nil {
+		return nil, fmt.Errorf("error converting to ConfigMap for %s: %v", u.GetName(), err)
+	}
+	return configMap, nil
+}
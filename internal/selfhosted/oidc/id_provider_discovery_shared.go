@@ -0,0 +1,268 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strings"
+
+	awsclient "github.com/kkb0318/irsa-manager/internal/aws"
+	"github.com/kkb0318/irsa-manager/internal/selfhosted"
+)
+
+// SHARED_JWKS_PATH is the path under which each cluster sharing the JWKS stores its own keys.
+const SHARED_JWKS_PATH = "jwks.d"
+
+// maxSharedJWKSAttempts is the number of times the shared JWKS is rebuilt when it has been changed concurrently.
+const maxSharedJWKSAttempts = 5
+
+// uploadShared uploads the keys of this cluster, and merges them into the shared JWKS and the discovery document.
+// The keys of this cluster are stored privately, only the merged JWKS is readable by anyone.
+func (s *S3IdPDiscovery) uploadShared(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents) error {
+	if s.memberID == "" {
+		return fmt.Errorf("unable to share the JWKS, the cluster is not identified")
+	}
+	discovery, err := o.Discovery()
+	if err != nil {
+		return err
+	}
+	jwk, err := o.JWK()
+	if err != nil {
+		return err
+	}
+	if err := s.s3Client.PutObject(ctx, awsclient.ObjectInput{Key: s.memberKey(), Body: jwk}); err != nil {
+		return fmt.Errorf("unable to upload object %s, %w", s.memberKey(), err)
+	}
+	return s.publishShared(ctx, discovery, o.JWKsFileName())
+}
+
+// publishShared rebuilds the shared JWKS from the keys of all clusters sharing it,
+// and the discovery document advertising the signing algorithms of all these keys from the given one.
+// When discovery is nil, the published discovery document is updated, if there is one.
+// The JWKS is only replaced if it has not been changed since it was read, otherwise both are rebuilt again,
+// so that the keys uploaded by other clusters at the same time are not lost.
+func (s *S3IdPDiscovery) publishShared(ctx context.Context, discovery []byte, jwksFileName string) error {
+	key := s.key(jwksFileName)
+	discoveryKey := s.key(CONFIGURATION_PATH)
+	for attempt := 0; attempt < maxSharedJWKSAttempts; attempt++ {
+		current, etag, err := s.s3Client.GetObjectWithETag(ctx, key)
+		if err != nil {
+			return fmt.Errorf("unable to get object %s, %w", key, err)
+		}
+		jwks, err := s.memberJWKs(ctx)
+		if err != nil {
+			return err
+		}
+		mergedJWK := selfhosted.MergeJWKs(jwks...)
+		merged, err := json.MarshalIndent(mergedJWK, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := s.publishSharedDiscovery(ctx, discoveryKey, discovery, mergedJWK.SigningAlgorithms()); err != nil {
+			return err
+		}
+		if bytes.Equal(current, merged) {
+			return nil
+		}
+		input := awsclient.ObjectInput{Key: key, Body: merged}
+		if s.usesBucketPolicy() {
+			err = s.s3Client.PutObject(ctx, input, awsclient.IfMatch(etag))
+		} else {
+			err = s.s3Client.PutObjectPublic(ctx, input, awsclient.IfMatch(etag))
+		}
+		if awsclient.IsPreconditionFailed(err) {
+			log.Printf("the shared JWKS %s has been changed concurrently, retrying... \n", key)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to upload object %s, %w", key, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unable to upload object %s, it has been changed concurrently %d times", key, maxSharedJWKSAttempts)
+}
+
+// publishSharedDiscovery uploads the discovery document with the signing algorithms of the shared JWKS, unless it is already published.
+func (s *S3IdPDiscovery) publishSharedDiscovery(ctx context.Context, key string, discovery []byte, algorithms []string) error {
+	current, err := s.s3Client.GetObject(ctx, key)
+	if err != nil {
+		return fmt.Errorf("unable to get object %s, %w", key, err)
+	}
+	if discovery == nil {
+		if current == nil {
+			return nil
+		}
+		discovery = current
+	}
+	expected, err := withSigningAlgorithms(discovery, algorithms)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(current, expected) {
+		return nil
+	}
+	input := awsclient.ObjectInput{Key: key, Body: expected}
+	if s.usesBucketPolicy() {
+		err = s.s3Client.PutObject(ctx, input)
+	} else {
+		err = s.s3Client.PutObjectPublic(ctx, input)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to upload object %s, %w", key, err)
+	}
+	return nil
+}
+
+// isUpdateShared reports whether the discovery document or the keys of this cluster are missing or differ from the expected contents,
+// or whether the shared JWKS is missing some of the keys of this cluster.
+// The discovery document is expected to advertise the signing algorithms of the shared JWKS.
+func (s *S3IdPDiscovery) isUpdateShared(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents) (bool, error) {
+	discovery, err := o.Discovery()
+	if err != nil {
+		return false, err
+	}
+	jwk, err := o.JWK()
+	if err != nil {
+		return false, err
+	}
+	own := &selfhosted.JWK{}
+	if err := json.Unmarshal(jwk, own); err != nil {
+		return false, err
+	}
+	shared, err := s.getJWK(ctx, s.key(o.JWKsFileName()))
+	if err != nil {
+		return false, err
+	}
+	if shared == nil {
+		return true, nil
+	}
+	discovery, err = withSigningAlgorithms(discovery, selfhosted.MergeJWKs(shared, own).SigningAlgorithms())
+	if err != nil {
+		return false, err
+	}
+	expected := map[string][]byte{
+		s.key(CONFIGURATION_PATH): discovery,
+		s.memberKey():             jwk,
+	}
+	for key, body := range expected {
+		actual, err := s.s3Client.GetObject(ctx, key)
+		if err != nil {
+			return false, fmt.Errorf("unable to get object %s, %w", key, err)
+		}
+		if !bytes.Equal(actual, body) {
+			return true, nil
+		}
+	}
+	published, err := s.publishedSharedJWK(ctx, o)
+	if err != nil {
+		return false, err
+	}
+	return published == nil || len(published.KeyIDs()) != len(own.KeyIDs()), nil
+}
+
+// publishedSharedJWK returns the keys of this cluster that are published in the shared JWKS, or nil if it does not exist.
+func (s *S3IdPDiscovery) publishedSharedJWK(ctx context.Context, o selfhosted.OIDCIdPDiscoveryContents) (*selfhosted.JWK, error) {
+	key := s.key(o.JWKsFileName())
+	body, err := s.s3Client.GetObject(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get object %s, %w", key, err)
+	}
+	if body == nil {
+		return nil, nil
+	}
+	shared := &selfhosted.JWK{}
+	if err := json.Unmarshal(body, shared); err != nil {
+		return nil, fmt.Errorf("unable to parse object %s, %w", key, err)
+	}
+	own, err := s.getJWK(ctx, s.memberKey())
+	if err != nil {
+		return nil, err
+	}
+	published := &selfhosted.JWK{}
+	if own == nil {
+		return published, nil
+	}
+	ownKeyIDs := own.KeyIDs()
+	for _, k := range shared.Keys {
+		if slices.Contains(ownKeyIDs, k.KeyID) {
+			published.Keys = append(published.Keys, k)
+		}
+	}
+	return published, nil
+}
+
+// deleteShared removes the keys of this cluster from the shared JWKS,
+// and reports whether other clusters are still sharing it.
+func (s *S3IdPDiscovery) deleteShared(ctx context.Context) (bool, error) {
+	if err := s.s3Client.DeleteObjects(ctx, []string{s.memberKey()}); err != nil {
+		return false, err
+	}
+	members, err := s.members(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(members) == 0 {
+		return false, nil
+	}
+	log.Printf("the JWKS is still shared by %d clusters, only the keys of this cluster are removed \n", len(members))
+	return true, s.publishShared(ctx, nil, s.jwksFileName)
+}
+
+// memberJWKs returns the keys of all clusters sharing the JWKS.
+func (s *S3IdPDiscovery) memberJWKs(ctx context.Context) ([]*selfhosted.JWK, error) {
+	keys, err := s.members(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jwks := []*selfhosted.JWK{}
+	for _, key := range keys {
+		jwk, err := s.getJWK(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if jwk != nil {
+			jwks = append(jwks, jwk)
+		}
+	}
+	return jwks, nil
+}
+
+// members returns the keys of the objects in which the clusters sharing the JWKS store their keys, sorted.
+func (s *S3IdPDiscovery) members(ctx context.Context) ([]string, error) {
+	keys, err := s.s3Client.ListObjectKeys(ctx, s.key(SHARED_JWKS_PATH)+"/")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the keys of the clusters sharing the JWKS, %w", err)
+	}
+	members := []string{}
+	for _, key := range keys {
+		if strings.HasSuffix(key, ".json") {
+			members = append(members, key)
+		}
+	}
+	slices.Sort(members)
+	return members, nil
+}
+
+func (s *S3IdPDiscovery) getJWK(ctx context.Context, key string) (*selfhosted.JWK, error) {
+	body, err := s.s3Client.GetObject(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get object %s, %w", key, err)
+	}
+	if body == nil {
+		return nil, nil
+	}
+	jwk := &selfhosted.JWK{}
+	if err := json.Unmarshal(body, jwk); err != nil {
+		return nil, fmt.Errorf("unable to parse object %s, %w", key, err)
+	}
+	return jwk, nil
+}
+
+// memberKey returns the key of the object in which this cluster stores its keys.
+func (s *S3IdPDiscovery) memberKey() string {
+	return s.key(path.Join(SHARED_JWKS_PATH, s.memberID+".json"))
+}
@@ -0,0 +1,240 @@
+package oidc
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+)
+
+const (
+	IN_CLUSTER_NAMESPACE = "kube-system"
+	IN_CLUSTER_NAME      = "irsa-manager-oidc-discovery"
+
+	// configMap keys must not contain slashes, so the discovery document is mapped to its path by the volume.
+	configurationKey = "openid-configuration"
+	nginxConfigKey   = "default.conf"
+	serverPort       = 8080
+)
+
+// nginxConfig serves the discovery documents as JSON and nothing else.
+const nginxConfig = `server {
+    listen 8080;
+    root /usr/share/nginx/html;
+    default_type application/json;
+    location / {
+        try_files $uri =404;
+    }
+}
+`
+
+type inClusterManifestFactory struct {
+	meta      types.NamespacedName
+	podLabel  map[string]string
+	inCluster irsav1alpha1.InClusterDiscovery
+}
+
+func newInClusterManifestFactory(inCluster irsav1alpha1.InClusterDiscovery) *inClusterManifestFactory {
+	return &inClusterManifestFactory{
+		meta: types.NamespacedName{
+			Name:      IN_CLUSTER_NAME,
+			Namespace: IN_CLUSTER_NAMESPACE,
+		},
+		podLabel:  map[string]string{"app": IN_CLUSTER_NAME},
+		inCluster: inCluster,
+	}
+}
+
+func (f *inClusterManifestFactory) objectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      f.meta.Name,
+		Namespace: f.meta.Namespace,
+	}
+}
+
+// configMap holds the discovery document and the JWKS. A configMap without data only references it, e.g. to get or delete it.
+func (f *inClusterManifestFactory) configMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: f.objectMeta(),
+		Data:       data,
+	}
+}
+
+func (f *inClusterManifestFactory) deployment(jwksFileName string) *appsv1.Deployment {
+	replicas := int32(2)
+	// the config map may not exist yet when the storage is created
+	optional := true
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "Deployment",
+		},
+		ObjectMeta: f.objectMeta(),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: f.podLabel,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: f.podLabel,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "server",
+							Image: f.inCluster.GetImage(),
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "http",
+									ContainerPort: serverPort,
+								},
+							},
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									HTTPGet: &corev1.HTTPGetAction{
+										Path: "/" + CONFIGURATION_PATH,
+										Port: intstr.FromString("http"),
+									},
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "discovery",
+									MountPath: "/usr/share/nginx/html",
+									ReadOnly:  true,
+								},
+								{
+									Name:      "config",
+									MountPath: "/etc/nginx/conf.d",
+									ReadOnly:  true,
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "discovery",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: f.meta.Name},
+									Items: []corev1.KeyToPath{
+										{Key: configurationKey, Path: CONFIGURATION_PATH},
+										{Key: jwksFileName, Path: jwksFileName},
+									},
+									Optional: &optional,
+								},
+							},
+						},
+						{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: f.meta.Name},
+									Items: []corev1.KeyToPath{
+										{Key: nginxConfigKey, Path: nginxConfigKey},
+									},
+									Optional: &optional,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// podDisruptionBudget keeps one of the two server Pods available, e.g. while the nodes are drained,
+// since AWS IAM fails to validate the tokens while the discovery documents cannot be fetched.
+func (f *inClusterManifestFactory) podDisruptionBudget() *policyv1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	return &policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: policyv1.SchemeGroupVersion.String(),
+			Kind:       "PodDisruptionBudget",
+		},
+		ObjectMeta: f.objectMeta(),
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: f.podLabel,
+			},
+		},
+	}
+}
+
+func (f *inClusterManifestFactory) service() *corev1.Service {
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Service",
+		},
+		ObjectMeta: f.objectMeta(),
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Port:       80,
+					TargetPort: intstr.FromString("http"),
+				},
+			},
+			Selector: f.podLabel,
+		},
+	}
+}
+
+func (f *inClusterManifestFactory) ingress() *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	meta := f.objectMeta()
+	meta.Annotations = f.inCluster.Annotations
+	ingress := &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: networkingv1.SchemeGroupVersion.String(),
+			Kind:       "Ingress",
+		},
+		ObjectMeta: meta,
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: f.inCluster.IngressClassName,
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: f.inCluster.Host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: f.meta.Name,
+											Port: networkingv1.ServiceBackendPort{Name: "http"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if f.inCluster.TLSSecretName != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{
+			{
+				Hosts:      []string{f.inCluster.Host},
+				SecretName: f.inCluster.TLSSecretName,
+			},
+		}
+	}
+	return ingress
+}
@@ -17,6 +17,13 @@ func Execute(ctx context.Context, idpComponentsFactory OIDCIdPFactory, issuerMet
 	if err != nil {
 		return err
 	}
+	if !forceUpdate {
+		// the contents are derived from the stored signing keys, so outdated contents are replaced
+		forceUpdate, err = discovery.IsUpdate(ctx, discoveryContents)
+		if err != nil {
+			return err
+		}
+	}
 	err = discovery.Upload(ctx, discoveryContents, forceUpdate)
 	if err != nil {
 		return err
@@ -45,3 +52,10 @@ func Delete(ctx context.Context, factory OIDCIdPFactory, issuerMeta issuer.OIDCI
 	}
 	return nil
 }
+
+// Publish uploads the discovery contents, overwriting the ones that are already published.
+func Publish(ctx context.Context, factory OIDCIdPFactory, issuerMeta issuer.OIDCIssuerMeta) error {
+	discovery := factory.IdPDiscovery()
+	discoveryContents := factory.IdPDiscoveryContents(issuerMeta)
+	return discovery.Upload(ctx, discoveryContents, true)
+}
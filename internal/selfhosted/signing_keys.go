@@ -0,0 +1,200 @@
+package selfhosted
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"k8s.io/client-go/util/keyutil"
+)
+
+// SigningKeys holds the key pair that is currently used to sign service account tokens,
+// the key pair that is published in the JWKS ahead of replacing it, if any,
+// and the public keys of the replaced key pairs that are still published in the JWKS.
+type SigningKeys struct {
+	active   KeyPair
+	pending  *KeyPair
+	retiring [][]byte
+}
+
+func NewSigningKeys(active KeyPair) *SigningKeys {
+	return &SigningKeys{active: active}
+}
+
+// LoadSigningKeys restores the signing keys from a PEM encoded private key, the PEM encoded private key of the pending key,
+// which may be empty, and a PEM encoded public key bundle.
+// The public keys matching neither the private key nor the pending one are treated as retiring keys.
+func LoadSigningKeys(privateKey, pendingPrivateKey, publicKeys []byte) (*SigningKeys, error) {
+	active, activeKeyID, err := loadKeyPair(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	s := &SigningKeys{active: active}
+	var pendingKeyID string
+	if len(pendingPrivateKey) > 0 {
+		pending, kid, err := loadKeyPair(pendingPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("pending key: %w", err)
+		}
+		s.pending = &pending
+		pendingKeyID = kid
+	}
+	for _, pub := range splitPEM(publicKeys) {
+		kid, err := KeyID(pub)
+		if err != nil {
+			return nil, err
+		}
+		if kid != activeKeyID && kid != pendingKeyID {
+			s.retiring = append(s.retiring, pub)
+		}
+	}
+	return s, nil
+}
+
+// loadKeyPair restores a key pair and its key ID from a PEM encoded private key.
+func loadKeyPair(privateKey []byte) (KeyPair, string, error) {
+	key, err := keyutil.ParsePrivateKeyPEM(privateKey)
+	if err != nil {
+		return KeyPair{}, "", fmt.Errorf("failed to parse the signing key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return KeyPair{}, "", errors.New("the signing key does not provide a public key")
+	}
+	publicKey, err := encodePublicKey(signer.Public())
+	if err != nil {
+		return KeyPair{}, "", err
+	}
+	kid, err := KeyID(publicKey)
+	if err != nil {
+		return KeyPair{}, "", err
+	}
+	return KeyPair{publicKey, privateKey}, kid, nil
+}
+
+func (s *SigningKeys) Active() *KeyPair {
+	return &s.active
+}
+
+// Pending returns the key pair that replaces the active one once it has been propagated, or nil if there is none.
+func (s *SigningKeys) Pending() *KeyPair {
+	return s.pending
+}
+
+// PendingKeyID returns the key ID of the pending key, or an empty string if there is none.
+func (s *SigningKeys) PendingKeyID() (string, error) {
+	if s.pending == nil {
+		return "", nil
+	}
+	return KeyID(s.pending.PublicKey())
+}
+
+// PublicKeys returns the PEM encoded public keys of all published keys, starting with the active key
+// followed by the pending key.
+func (s *SigningKeys) PublicKeys() []byte {
+	pubs := [][]byte{s.active.PublicKey()}
+	if s.pending != nil {
+		pubs = append(pubs, s.pending.PublicKey())
+	}
+	pubs = append(pubs, s.retiring...)
+	return bytes.Join(pubs, nil)
+}
+
+// KeyIDs returns the key IDs of all published keys, starting with the active key.
+func (s *SigningKeys) KeyIDs() ([]string, error) {
+	return PublicKeyIDs(s.PublicKeys())
+}
+
+// PublicKeyIDs returns the key IDs of all public keys in the PEM encoded data.
+func PublicKeyIDs(pubs []byte) ([]string, error) {
+	var kids []string
+	for _, pub := range splitPEM(pubs) {
+		kid, err := KeyID(pub)
+		if err != nil {
+			return nil, err
+		}
+		kids = append(kids, kid)
+	}
+	return kids, nil
+}
+
+// PublicKeyAlgorithms returns the algorithms of all public keys in the PEM encoded data.
+func PublicKeyAlgorithms(pubs []byte) ([]irsav1alpha1.SigningKeyAlgorithm, error) {
+	var algorithms []irsav1alpha1.SigningKeyAlgorithm
+	for _, pub := range splitPEM(pubs) {
+		algorithm, err := KeyAlgorithm(pub)
+		if err != nil {
+			return nil, err
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+	return algorithms, nil
+}
+
+// Prepare publishes next as the pending key pair, without using it for signing yet.
+func (s *SigningKeys) Prepare(next KeyPair) {
+	s.pending = &next
+}
+
+// Activate makes the pending key pair the active one. The previous active key is kept as a retiring key.
+func (s *SigningKeys) Activate() error {
+	if s.pending == nil {
+		return errors.New("there is no pending signing key to activate")
+	}
+	s.retiring = append([][]byte{s.active.PublicKey()}, s.retiring...)
+	s.active = *s.pending
+	s.pending = nil
+	return nil
+}
+
+// Retire removes the retiring key with the given key ID. The active and the pending keys cannot be retired.
+func (s *SigningKeys) Retire(keyID string) error {
+	for i, pub := range s.retiring {
+		kid, err := KeyID(pub)
+		if err != nil {
+			return err
+		}
+		if kid == keyID {
+			s.retiring = append(s.retiring[:i], s.retiring[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// KeyID returns the key ID of the first public key in the PEM encoded data.
+func KeyID(pub []byte) (string, error) {
+	pubKeys, err := keyutil.ParsePublicKeysPEM(pub)
+	if err != nil {
+		return "", err
+	}
+	return keyIDFromPublicKey(pubKeys[0])
+}
+
+func encodePublicKey(pub crypto.PublicKey) ([]byte, error) {
+	pubASN1, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: pubASN1,
+	}), nil
+}
+
+// splitPEM splits PEM encoded data into its blocks.
+func splitPEM(data []byte) [][]byte {
+	var blocks [][]byte
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			return blocks
+		}
+		blocks = append(blocks, pem.EncodeToMemory(block))
+		data = rest
+	}
+}
@@ -0,0 +1,73 @@
+package selfhosted
+
+import (
+	"testing"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningKeys(t *testing.T) {
+	first, err := CreateKeyPair(irsav1alpha1.SigningKeyRSA2048)
+	assert.NoError(t, err)
+	second, err := CreateKeyPair(irsav1alpha1.SigningKeyRSA2048)
+	assert.NoError(t, err)
+	firstKid, err := KeyID(first.PublicKey())
+	assert.NoError(t, err)
+	secondKid, err := KeyID(second.PublicKey())
+	assert.NoError(t, err)
+
+	keys := NewSigningKeys(*first)
+	assert.Error(t, keys.Activate(), "there is no pending key")
+	keys.Prepare(*second)
+	kids, err := keys.KeyIDs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{firstKid, secondKid}, kids)
+	assert.Equal(t, first.PrivateKey(), keys.Active().PrivateKey(), "the pending key is not used for signing")
+
+	t.Run("load the pending key from the stored keys", func(t *testing.T) {
+		loaded, err := LoadSigningKeys(keys.Active().PrivateKey(), keys.Pending().PrivateKey(), keys.PublicKeys())
+		assert.NoError(t, err)
+		assert.Equal(t, keys.PublicKeys(), loaded.PublicKeys())
+		assert.Equal(t, second.PrivateKey(), loaded.Pending().PrivateKey())
+	})
+
+	assert.NoError(t, keys.Activate())
+	assert.Nil(t, keys.Pending())
+	kids, err = keys.KeyIDs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{secondKid, firstKid}, kids)
+
+	t.Run("load from the stored keys", func(t *testing.T) {
+		loaded, err := LoadSigningKeys(keys.Active().PrivateKey(), nil, keys.PublicKeys())
+		assert.NoError(t, err)
+		assert.Equal(t, keys.PublicKeys(), loaded.PublicKeys())
+		assert.Equal(t, second.PrivateKey(), loaded.Active().PrivateKey())
+		assert.Nil(t, loaded.Pending())
+	})
+
+	t.Run("jwks contains all the keys", func(t *testing.T) {
+		jwk, err := NewJWK(keys.PublicKeys())
+		assert.NoError(t, err)
+		var jwkKids []string
+		for _, k := range jwk.Keys {
+			jwkKids = append(jwkKids, k.KeyID)
+		}
+		assert.Equal(t, []string{secondKid, "", firstKid}, jwkKids)
+	})
+
+	t.Run("retire", func(t *testing.T) {
+		assert.NoError(t, keys.Retire(firstKid))
+		kids, err := keys.KeyIDs()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{secondKid}, kids)
+	})
+
+	t.Run("load with a mismatched private key", func(t *testing.T) {
+		loaded, err := LoadSigningKeys(first.PrivateKey(), nil, second.PublicKey())
+		assert.NoError(t, err)
+		kids, err := loaded.KeyIDs()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{firstKid, secondKid}, kids)
+	})
+}
@@ -0,0 +1,75 @@
+package selfhosted
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// TokenVerificationFailure tells why a service account token cannot be validated against the published discovery documents.
+type TokenVerificationFailure string
+
+const (
+	// TokenMalformed is reported when the token is not a signed JWT.
+	TokenMalformed = TokenVerificationFailure("Malformed")
+	// TokenIssuerMismatch is reported when the kube-apiserver issues the token for another issuer.
+	TokenIssuerMismatch = TokenVerificationFailure("IssuerMismatch")
+	// TokenUnknownKeyID is reported when the key that signed the token is not published in the JWKS.
+	TokenUnknownKeyID = TokenVerificationFailure("UnknownKeyID")
+	// TokenBadSignature is reported when the signature does not match the published key.
+	TokenBadSignature = TokenVerificationFailure("BadSignature")
+)
+
+// TokenVerificationError is returned when a token cannot be validated against the published discovery documents.
+type TokenVerificationError struct {
+	Failure TokenVerificationFailure
+	Message string
+}
+
+func (e *TokenVerificationError) Error() string {
+	return e.Message
+}
+
+// tokenSignatureAlgorithms are the algorithms the kube-apiserver signs the service account tokens with.
+var tokenSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256, jose.ES256, jose.ES384, jose.ES512}
+
+// VerifyToken checks that a service account token is issued for issuerUrl and signed with a key of the JWKS,
+// as an AWS STS would do when the token is exchanged.
+// It returns a *TokenVerificationError when the token cannot be validated.
+func VerifyToken(token string, issuerUrl string, jwk *JWK) error {
+	jws, err := jose.ParseSigned(token, tokenSignatureAlgorithms)
+	if err != nil {
+		return &TokenVerificationError{TokenMalformed, fmt.Sprintf("failed to parse the token: %s", err)}
+	}
+	if len(jws.Signatures) != 1 {
+		return &TokenVerificationError{TokenMalformed, fmt.Sprintf("the token has %d signatures", len(jws.Signatures))}
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+		return &TokenVerificationError{TokenMalformed, fmt.Sprintf("failed to parse the claims of the token: %s", err)}
+	}
+	if claims.Issuer != issuerUrl {
+		return &TokenVerificationError{TokenIssuerMismatch, fmt.Sprintf("the token is issued by %q, not by %q", claims.Issuer, issuerUrl)}
+	}
+	kid := jws.Signatures[0].Header.KeyID
+	var keys []jose.JSONWebKey
+	if jwk != nil {
+		for _, key := range jwk.Keys {
+			if key.KeyID == kid {
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return &TokenVerificationError{TokenUnknownKeyID, fmt.Sprintf("the key ID %q of the token is not published in the JWKS", kid)}
+	}
+	for _, key := range keys {
+		if _, err := jws.Verify(key.Key); err == nil {
+			return nil
+		}
+	}
+	return &TokenVerificationError{TokenBadSignature, fmt.Sprintf("the signature of the token does not match the published key %q", kid)}
+}
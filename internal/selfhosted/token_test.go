@@ -0,0 +1,99 @@
+package selfhosted
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/keyutil"
+)
+
+func TestVerifyToken(t *testing.T) {
+	const issuerUrl = "https://s3-ap-northeast-1.amazonaws.com/bucket"
+	published, err := CreateKeyPair(irsav1alpha1.SigningKeyRSA2048)
+	assert.NoError(t, err)
+	other, err := CreateKeyPair(irsav1alpha1.SigningKeyECDSAP256)
+	assert.NoError(t, err)
+	jwk, err := NewJWK(published.PublicKey())
+	assert.NoError(t, err)
+	publishedKeyID, err := KeyID(published.PublicKey())
+	assert.NoError(t, err)
+	otherKeyID, err := KeyID(other.PublicKey())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		token    func() string
+		expected TokenVerificationFailure
+	}{
+		{
+			name: "valid token",
+			token: func() string {
+				return signToken(t, published, publishedKeyID, issuerUrl)
+			},
+		},
+		{
+			name: "issuer mismatch",
+			token: func() string {
+				return signToken(t, published, publishedKeyID, DefaultServiceAccountIssuer)
+			},
+			expected: TokenIssuerMismatch,
+		},
+		{
+			name: "unknown key ID",
+			token: func() string {
+				return signToken(t, other, otherKeyID, issuerUrl)
+			},
+			expected: TokenUnknownKeyID,
+		},
+		{
+			name: "bad signature",
+			token: func() string {
+				return signToken(t, other, publishedKeyID, issuerUrl)
+			},
+			expected: TokenBadSignature,
+		},
+		{
+			name: "malformed token",
+			token: func() string {
+				return "not-a-token"
+			},
+			expected: TokenMalformed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyToken(tt.token(), issuerUrl, jwk)
+			if tt.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var verr *TokenVerificationError
+			assert.True(t, errors.As(err, &verr), err)
+			assert.Equal(t, tt.expected, verr.Failure)
+		})
+	}
+}
+
+func signToken(t *testing.T, keyPair *KeyPair, kid string, issuerUrl string) string {
+	key, err := keyutil.ParsePrivateKeyPEM(keyPair.PrivateKey())
+	assert.NoError(t, err)
+	alg, err := signatureAlgorithm(key.(crypto.Signer).Public())
+	assert.NoError(t, err)
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: alg, Key: key},
+		(&jose.SignerOptions{}).WithHeader(jose.HeaderKey("kid"), kid),
+	)
+	assert.NoError(t, err)
+	payload, err := json.Marshal(map[string]string{"iss": issuerUrl, "sub": "system:serviceaccount:default:probe"})
+	assert.NoError(t, err)
+	jws, err := signer.Sign(payload)
+	assert.NoError(t, err)
+	token, err := jws.CompactSerialize()
+	assert.NoError(t, err)
+	return token
+}
@@ -1,10 +1,12 @@
 package webhook
 
 import (
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
 	"github.com/kkb0318/irsa-manager/internal/manifests"
 	regv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -17,33 +19,71 @@ type baseManifestFactory struct {
 	serviceAccountMeta               types.NamespacedName
 	mutatingWebhookConfigurationMeta types.NamespacedName
 	podLabel                         map[string]string
+	options                          Options
 }
 
-func serviceNamespacedName() types.NamespacedName {
-	return types.NamespacedName{
-		Name:      "pod-identity-webhook",
-		Namespace: WEBHOOK_NAMESPACE,
+// Options configures the webhook Deployment.
+// The zero value deploys a single replica of the default image in the default namespace,
+// as defaulted by the Webhook of the IRSASetup.
+type Options struct {
+	Namespace         string
+	Image             string
+	ImagePullPolicy   corev1.PullPolicy
+	ImagePullSecrets  []corev1.LocalObjectReference
+	Replicas          int32
+	Resources         corev1.ResourceRequirements
+	NodeSelector      map[string]string
+	Tolerations       []corev1.Toleration
+	Affinity          *corev1.Affinity
+	PriorityClassName string
+}
+
+func (o Options) namespace() string {
+	if o.Namespace == "" {
+		return irsav1alpha1.DefaultWebhookNamespace
+	}
+	return o.Namespace
+}
+
+func (o Options) image() string {
+	if o.Image == "" {
+		return irsav1alpha1.DefaultWebhookImage
+	}
+	return o.Image
+}
+
+func (o Options) imagePullPolicy() corev1.PullPolicy {
+	if o.ImagePullPolicy == "" {
+		return irsav1alpha1.DefaultWebhookImagePullPolicy
 	}
+	return o.ImagePullPolicy
 }
 
-const WEBHOOK_NAMESPACE = "kube-system"
+func (o Options) replicas() int32 {
+	if o.Replicas < 1 {
+		return 1
+	}
+	return o.Replicas
+}
 
-func newBaseManifestFactory() *baseManifestFactory {
+func newBaseManifestFactory(options Options) *baseManifestFactory {
+	namespace := options.namespace()
 	return &baseManifestFactory{
 		deploymentMeta: types.NamespacedName{
 			Name:      "pod-identity-webhook",
-			Namespace: WEBHOOK_NAMESPACE,
+			Namespace: namespace,
 		},
-		serviceMeta: serviceNamespacedName(),
+		serviceMeta: ServiceNamespacedName(namespace),
 		serviceAccountMeta: types.NamespacedName{
 			Name:      "pod-identity-webhook",
-			Namespace: WEBHOOK_NAMESPACE,
+			Namespace: namespace,
 		},
 		mutatingWebhookConfigurationMeta: types.NamespacedName{
 			Name:      "pod-identity-webhook",
-			Namespace: WEBHOOK_NAMESPACE,
+			Namespace: namespace,
 		},
 		podLabel: map[string]string{"app": "pod-identity-webhook"},
+		options:  options,
 	}
 }
 
@@ -89,7 +129,7 @@ func (b *baseManifestFactory) mutatingWebhookConfiguration() *regv1.MutatingWebh
 }
 
 func (b *baseManifestFactory) deployment() *appsv1.Deployment {
-	replicas := int32(1)
+	replicas := b.options.replicas()
 	return &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: appsv1.SchemeGroupVersion.String(),
@@ -110,11 +150,17 @@ func (b *baseManifestFactory) deployment() *appsv1.Deployment {
 				},
 				Spec: corev1.PodSpec{
 					ServiceAccountName: b.serviceAccountMeta.Name,
+					ImagePullSecrets:   b.options.ImagePullSecrets,
+					NodeSelector:       b.options.NodeSelector,
+					Tolerations:        b.options.Tolerations,
+					Affinity:           b.options.Affinity,
+					PriorityClassName:  b.options.PriorityClassName,
 					Containers: []corev1.Container{
 						{
 							Name:            "pod-identity-webhook",
-							Image:           "amazon/amazon-eks-pod-identity-webhook:latest",
-							ImagePullPolicy: corev1.PullAlways,
+							Image:           b.options.image(),
+							ImagePullPolicy: b.options.imagePullPolicy(),
+							Resources:       b.options.Resources,
 							// Command:         []string{}, // Command must be patched
 							VolumeMounts: []corev1.VolumeMount{
 								{
@@ -219,3 +265,25 @@ func (b *baseManifestFactory) service() *corev1.Service {
 		},
 	}
 }
+
+// podDisruptionBudget keeps one webhook Pod available during voluntary disruptions.
+// It is only deployed with more than one replica, since it would otherwise block the drain of the node.
+func (b *baseManifestFactory) podDisruptionBudget() *policyv1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	return &policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: policyv1.SchemeGroupVersion.String(),
+			Kind:       "PodDisruptionBudget",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.deploymentMeta.Name,
+			Namespace: b.deploymentMeta.Namespace,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: b.podLabel,
+			},
+		},
+	}
+}
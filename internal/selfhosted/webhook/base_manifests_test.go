@@ -9,12 +9,13 @@ import (
 	regv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func TestBaseManifests(t *testing.T) {
-	b := newBaseManifestFactory()
+	b := newBaseManifestFactory(Options{})
 	tests := []struct {
 		name         string
 		runFunc      func() client.Object
@@ -69,6 +70,14 @@ func TestBaseManifests(t *testing.T) {
 			expected:     "testdata/clusterrolebinding.yaml",
 			expectedFunc: testClusterRoleBinding,
 		},
+		{
+			name: "poddisruptionbudget",
+			runFunc: func() client.Object {
+				return b.podDisruptionBudget()
+			},
+			expected:     "testdata/poddisruptionbudget.yaml",
+			expectedFunc: testPodDisruptionBudget,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -103,6 +112,10 @@ func testClusterRole() client.Object {
 	return &rbacv1.ClusterRole{}
 }
 
+func testPodDisruptionBudget() client.Object {
+	return &policyv1.PodDisruptionBudget{}
+}
+
 func testClusterRoleBinding() client.Object {
 	return &rbacv1.ClusterRoleBinding{}
 }
@@ -3,9 +3,11 @@ package webhook
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"math/big"
 	"time"
 
@@ -15,6 +17,8 @@ import (
 type TlsCredential struct {
 	privateKey  []byte
 	certificate []byte
+	notBefore   time.Time
+	notAfter    time.Time
 }
 
 func (t TlsCredential) Certificate() []byte {
@@ -25,6 +29,54 @@ func (t TlsCredential) PrivateKey() []byte {
 	return t.privateKey
 }
 
+// NotBefore returns the start of the validity of the certificate.
+func (t TlsCredential) NotBefore() time.Time {
+	return t.notBefore
+}
+
+// NotAfter returns the expiry of the certificate.
+func (t TlsCredential) NotAfter() time.Time {
+	return t.notAfter
+}
+
+// ParseTlsCredential loads the PEM encoded certificate and private key of a TLS Secret.
+// It fails when the private key does not match the certificate.
+func ParseTlsCredential(certificate, privateKey []byte) (TlsCredential, error) {
+	if _, err := tls.X509KeyPair(certificate, privateKey); err != nil {
+		return TlsCredential{}, err
+	}
+	cert, err := parseCertificate(certificate)
+	if err != nil {
+		return TlsCredential{}, err
+	}
+	return TlsCredential{privateKey: privateKey, certificate: certificate, notBefore: cert.NotBefore, notAfter: cert.NotAfter}, nil
+}
+
+// CertificateNotAfter returns the expiry of the first certificate of a PEM bundle.
+func CertificateNotAfter(certificate []byte) (time.Time, error) {
+	cert, err := parseCertificate(certificate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+func parseCertificate(certificate []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certificate)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("failed to decode PEM block containing the certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// DNSNames returns the DNS names of the Service the serving certificate is issued for.
+func DNSNames(serviceNamespacedName types.NamespacedName) []string {
+	return []string{
+		serviceNamespacedName.Name + "." + serviceNamespacedName.Namespace + ".svc",
+		serviceNamespacedName.Name + "." + serviceNamespacedName.Namespace + ".svc.cluster.local",
+	}
+}
+
 func CreateTlsCredential(serviceNamespacedName types.NamespacedName) (TlsCredential, error) {
 	certificatePeriod := 365 // days
 
@@ -34,14 +86,21 @@ func CreateTlsCredential(serviceNamespacedName types.NamespacedName) (TlsCredent
 		return TlsCredential{}, err
 	}
 
+	// a random serial number, as the certificate is renewed before it expires
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return TlsCredential{}, err
+	}
+
 	// Define certificate template
+	now := time.Now().UTC().Truncate(time.Second)
 	template := x509.Certificate{
-		SerialNumber: big.NewInt(1),
+		SerialNumber: serialNumber,
 		Subject: pkix.Name{
 			CommonName: serviceNamespacedName.Name + "." + serviceNamespacedName.Namespace + ".svc",
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(0, 0, certificatePeriod),
+		NotBefore:             now,
+		NotAfter:              now.AddDate(0, 0, certificatePeriod),
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
@@ -49,10 +108,7 @@ func CreateTlsCredential(serviceNamespacedName types.NamespacedName) (TlsCredent
 	}
 
 	// Add SANs to the certificate template
-	template.DNSNames = []string{
-		serviceNamespacedName.Name + "." + serviceNamespacedName.Namespace + ".svc",
-		serviceNamespacedName.Name + "." + serviceNamespacedName.Namespace + ".svc.cluster.local",
-	}
+	template.DNSNames = DNSNames(serviceNamespacedName)
 
 	// Create the certificate
 	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
@@ -72,5 +128,5 @@ func CreateTlsCredential(serviceNamespacedName types.NamespacedName) (TlsCredent
 		Bytes: certBytes,
 	})
 
-	return TlsCredential{privateKey: privPemBytes, certificate: certPemBytes}, nil
+	return TlsCredential{privateKey: privPemBytes, certificate: certPemBytes, notBefore: template.NotBefore, notAfter: template.NotAfter}, nil
 }
@@ -6,6 +6,7 @@ import (
 	"encoding/pem"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -56,3 +57,50 @@ func publicKeysEqual(pub1, pub2 interface{}) bool {
 	}
 	return rsaPub1.N.Cmp(rsaPub2.N) == 0 && rsaPub1.E == rsaPub2.E
 }
+
+func TestParseTlsCredential(t *testing.T) {
+	serviceNamespacedName := types.NamespacedName{
+		Name:      "pod-identity-webhook",
+		Namespace: "kube-system",
+	}
+	creds, err := CreateTlsCredential(serviceNamespacedName)
+	assert.NoError(t, err)
+	other, err := CreateTlsCredential(serviceNamespacedName)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		certificate []byte
+		privateKey  []byte
+		expectedErr bool
+	}{
+		{
+			name:        "matching private key",
+			certificate: creds.Certificate(),
+			privateKey:  creds.PrivateKey(),
+		},
+		{
+			name:        "private key of another certificate",
+			certificate: creds.Certificate(),
+			privateKey:  other.PrivateKey(),
+			expectedErr: true,
+		},
+		{
+			name:        "no certificate",
+			privateKey:  creds.PrivateKey(),
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseTlsCredential(tt.certificate, tt.privateKey)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, creds.NotAfter(), parsed.NotAfter())
+			assert.Equal(t, creds.Certificate(), parsed.Certificate())
+		})
+	}
+}
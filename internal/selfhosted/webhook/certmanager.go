@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CertManagerInjectCAAnnotation makes the cainjector of cert-manager set the CA of a Certificate
+// in the CA bundle of the MutatingWebhookConfiguration.
+const CertManagerInjectCAAnnotation = "cert-manager.io/inject-ca-from"
+
+// CertificateGVK is the kind of the cert-manager Certificate.
+var CertificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// IssuerGVK is the kind of the cert-manager Issuer.
+var IssuerGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Issuer"}
+
+// CertManagerIssuer references the cert-manager issuer of the serving certificate.
+// When Name is empty, a self-signed Issuer is created next to the webhook.
+type CertManagerIssuer struct {
+	Name  string
+	Kind  string
+	Group string
+}
+
+// CertManagerResources returns the cert-manager Certificate issuing the serving certificate into the webhook Secret
+// in the namespace, and the self-signed Issuer when no issuer is referenced.
+func CertManagerResources(namespace string, issuer CertManagerIssuer) []client.Object {
+	certificateNamespacedName := SecretNamespacedName(namespace)
+	issuerRef := map[string]interface{}{
+		"name":  issuer.Name,
+		"kind":  issuer.Kind,
+		"group": issuer.Group,
+	}
+	resources := []client.Object{}
+	if issuer.Name == "" {
+		selfSigned := &unstructured.Unstructured{}
+		selfSigned.SetGroupVersionKind(IssuerGVK)
+		selfSigned.SetName(certificateNamespacedName.Name)
+		selfSigned.SetNamespace(certificateNamespacedName.Namespace)
+		selfSigned.Object["spec"] = map[string]interface{}{
+			"selfSigned": map[string]interface{}{},
+		}
+		resources = append(resources, selfSigned)
+		issuerRef["name"] = certificateNamespacedName.Name
+	}
+	if issuer.Kind == "" {
+		issuerRef["kind"] = IssuerGVK.Kind
+	}
+	if issuer.Group == "" {
+		issuerRef["group"] = IssuerGVK.Group
+	}
+	serviceNamespacedName := ServiceNamespacedName(namespace)
+	dnsNames := []interface{}{}
+	for _, name := range DNSNames(serviceNamespacedName) {
+		dnsNames = append(dnsNames, name)
+	}
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(CertificateGVK)
+	certificate.SetName(certificateNamespacedName.Name)
+	certificate.SetNamespace(certificateNamespacedName.Namespace)
+	certificate.Object["spec"] = map[string]interface{}{
+		"secretName":  certificateNamespacedName.Name,
+		"commonName":  serviceNamespacedName.Name + "." + serviceNamespacedName.Namespace + ".svc",
+		"dnsNames":    dnsNames,
+		"duration":    "8760h",
+		"renewBefore": "720h",
+		"privateKey": map[string]interface{}{
+			"algorithm":      "RSA",
+			"size":           int64(2048),
+			"rotationPolicy": "Always",
+		},
+		"usages":    []interface{}{"digital signature", "key encipherment", "server auth"},
+		"issuerRef": issuerRef,
+	}
+	return append(resources, certificate)
+}
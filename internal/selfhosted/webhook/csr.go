@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PendingPrivateKeyKey is the key of the webhook Secret holding the private key of a CertificateSigningRequest
+// that has not been signed yet.
+const PendingPrivateKeyKey = "pending.key"
+
+// CreatePrivateKey returns a PEM encoded private key for a CertificateSigningRequest.
+func CreatePrivateKey() ([]byte, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}), nil
+}
+
+// NewCertificateSigningRequest returns the CertificateSigningRequest of the serving certificate for the PEM encoded private key.
+// Its name is derived from the public key, so that the same request is found again until it is signed.
+func NewCertificateSigningRequest(serviceNamespacedName types.NamespacedName, privateKey []byte, signerName string, expirationSeconds *int32) (*certificatesv1.CertificateSigningRequest, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block containing the private key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	request, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName: serviceNamespacedName.Name + "." + serviceNamespacedName.Namespace + ".svc",
+		},
+		DNSNames: DNSNames(serviceNamespacedName),
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(publicKey)
+	return &certificatesv1.CertificateSigningRequest{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: certificatesv1.SchemeGroupVersion.String(),
+			Kind:       "CertificateSigningRequest",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: serviceNamespacedName.Name + "-" + hex.EncodeToString(hash[:])[:16],
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: request,
+			}),
+			SignerName:        signerName,
+			ExpirationSeconds: expirationSeconds,
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageServerAuth,
+			},
+		},
+	}, nil
+}
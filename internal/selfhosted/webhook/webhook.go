@@ -1,6 +1,8 @@
 package webhook
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 
 	"github.com/kkb0318/irsa-manager/internal/manifests"
@@ -13,10 +15,27 @@ type WebhookSetup struct {
 	resources []client.Object
 }
 
-func secretNamespacedName() types.NamespacedName {
+// PreviousCertificateKey is the key of the webhook Secret holding the certificate replaced by the last renewal.
+// It stays in the CA bundle until it expires, so that the webhook can still be called while it serves the previous certificate.
+const PreviousCertificateKey = "previous.crt"
+
+// CertificateHashAnnotation is set in the Pod template of the webhook to the hash of the certificate,
+// so that the webhook is restarted with the renewed certificate.
+const CertificateHashAnnotation = "irsa-manager.kkb0318.github.io/certificate-hash"
+
+// SecretNamespacedName returns the Secret holding the serving certificate of the webhook deployed in the namespace.
+func SecretNamespacedName(namespace string) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      "pod-identity-webhook",
+		Namespace: namespace,
+	}
+}
+
+// ServiceNamespacedName returns the Service of the webhook deployed in the namespace, for which the serving certificate is issued.
+func ServiceNamespacedName(namespace string) types.NamespacedName {
 	return types.NamespacedName{
 		Name:      "pod-identity-webhook",
-		Namespace: WEBHOOK_NAMESPACE,
+		Namespace: namespace,
 	}
 }
 
@@ -24,40 +43,114 @@ func (w *WebhookSetup) Resources() []client.Object {
 	return w.resources
 }
 
-func NewWebHookSetup() (*WebhookSetup, error) {
-	factory := newBaseManifestFactory()
-	resources, err := myCertificate(factory)
+// ServingCertificate is the serving certificate of the webhook and how the kube-apiserver trusts it.
+type ServingCertificate struct {
+	// Credential is stored in the webhook Secret, unless CertManager is set.
+	// The hash of its certificate is set in the Pod template, so that the webhook is restarted with a renewed certificate.
+	Credential TlsCredential
+
+	// SecretData is stored in the webhook Secret next to the credential.
+	SecretData map[string][]byte
+
+	// CABundle is set in the MutatingWebhookConfiguration, unless CertManager is set.
+	CABundle []byte
+
+	// CertManager makes cert-manager issue the certificate into the webhook Secret
+	// and inject its CA into the MutatingWebhookConfiguration.
+	CertManager *CertManagerIssuer
+}
+
+// SelfSignedCertificate returns the self-signed serving certificate.
+// The previous certificate, if any, is added to the CA bundle next to the certificate.
+func SelfSignedCertificate(tlsCredential TlsCredential, previousCertificate []byte) ServingCertificate {
+	cert := ServingCertificate{
+		Credential: tlsCredential,
+		CABundle:   append(append([]byte{}, tlsCredential.Certificate()...), previousCertificate...),
+	}
+	if len(previousCertificate) > 0 {
+		cert.SecretData = map[string][]byte{PreviousCertificateKey: previousCertificate}
+	}
+	return cert
+}
+
+// CertificateHash returns the hash of the certificate set in the Pod template of the webhook.
+func (c ServingCertificate) CertificateHash() string {
+	certificateHash := sha256.Sum256(c.Credential.Certificate())
+	return hex.EncodeToString(certificateHash[:])
+}
+
+// Secret returns the webhook Secret in the namespace holding the credential and the additional data.
+func (c ServingCertificate) Secret(namespace string) (*corev1.Secret, error) {
+	secret, err := manifests.NewSecretBuilder().
+		WithCertificate(c.Credential).
+		Build(SecretNamespacedName(namespace))
 	if err != nil {
 		return nil, err
 	}
-	return &WebhookSetup{resources}, nil
+	// the keys are required in a TLS Secret, even before the certificate is issued
+	for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+		if secret.Data[key] == nil {
+			secret.Data[key] = []byte{}
+		}
+	}
+	for k, v := range c.SecretData {
+		secret.Data[k] = v
+	}
+	return secret, nil
 }
 
-func myCertificate(base *baseManifestFactory) ([]client.Object, error) {
-	tlsCredential, err := CreateTlsCredential(serviceNamespacedName())
+// PodDisruptionBudget returns the PodDisruptionBudget of the webhook deployed in the namespace.
+// It is removed when the webhook is scaled back to a single replica.
+func PodDisruptionBudget(namespace string) client.Object {
+	return newBaseManifestFactory(Options{Namespace: namespace}).podDisruptionBudget()
+}
+
+// NamespacedResources returns the webhook resources living in the namespace, without the cert-manager resources.
+// They are left behind when the webhook is moved to another namespace, while the cluster-scoped resources are shared.
+func NamespacedResources(namespace string) ([]client.Object, error) {
+	base := newBaseManifestFactory(Options{Namespace: namespace})
+	secret, err := ServingCertificate{}.Secret(namespace)
 	if err != nil {
 		return nil, err
 	}
-	resources := []client.Object{}
-	secretNamespacedName := secretNamespacedName()
-	secret, err := manifests.NewSecretBuilder().
-		WithCertificate(tlsCredential).
-		Build(secretNamespacedName)
+	return []client.Object{
+		secret,
+		base.deployment(),
+		base.serviceAccount(),
+		base.service(),
+		base.podDisruptionBudget(),
+	}, nil
+}
+
+// NewWebHookSetup returns the webhook resources deployed with the options and serving the given certificate.
+// The PodDisruptionBudget is only part of them with more than one replica.
+func NewWebHookSetup(cert ServingCertificate, options Options) (*WebhookSetup, error) {
+	factory := newBaseManifestFactory(options)
+	resources, err := myCertificate(factory, cert)
 	if err != nil {
 		return nil, err
 	}
+	return &WebhookSetup{resources}, nil
+}
+
+func myCertificate(base *baseManifestFactory, cert ServingCertificate) ([]client.Object, error) {
+	resources := []client.Object{}
+	secretNamespacedName := SecretNamespacedName(base.options.namespace())
 
 	deploy := base.deployment()
 	deploy.Spec.Template.Spec.Containers[0].Command = []string{
 		"/webhook",
 		"--in-cluster=false",
-		fmt.Sprintf("--namespace=%s", WEBHOOK_NAMESPACE),
+		fmt.Sprintf("--namespace=%s", base.deploymentMeta.Namespace),
 		fmt.Sprintf("--service-name=%s", base.serviceMeta.Name),
 		fmt.Sprintf("--tls-secret=%s", secretNamespacedName.Name),
 		"--annotation-prefix=eks.amazonaws.com",
 		"--token-audience=sts.amazonaws.com",
 		"--logtostderr",
 	}
+	deploy.Spec.Template.Annotations = map[string]string{
+		CertificateHashAnnotation: cert.CertificateHash(),
+	}
 	deploy.Spec.Template.Spec.Volumes = []corev1.Volume{
 		{
 			Name: "cert",
@@ -69,9 +162,21 @@ func myCertificate(base *baseManifestFactory) ([]client.Object, error) {
 		},
 	}
 	mutate := base.mutatingWebhookConfiguration()
-	mutate.Webhooks[0].ClientConfig.CABundle = tlsCredential.Certificate()
+	if cert.CertManager != nil {
+		// the Secret and the CA bundle are written by cert-manager
+		mutate.Annotations = map[string]string{
+			CertManagerInjectCAAnnotation: secretNamespacedName.String(),
+		}
+		resources = append(resources, CertManagerResources(base.options.namespace(), *cert.CertManager)...)
+	} else {
+		secret, err := cert.Secret(base.options.namespace())
+		if err != nil {
+			return nil, err
+		}
+		mutate.Webhooks[0].ClientConfig.CABundle = cert.CABundle
+		resources = append(resources, secret)
+	}
 	resources = append(resources,
-		secret,
 		deploy,
 		mutate,
 		base.clusterRole(),
@@ -79,5 +184,8 @@ func myCertificate(base *baseManifestFactory) ([]client.Object, error) {
 		base.serviceAccount(),
 		base.service(),
 	)
+	if base.options.replicas() > 1 {
+		resources = append(resources, base.podDisruptionBudget())
+	}
 	return resources, nil
 }
@@ -0,0 +1,238 @@
+package webhook
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	irsav1alpha1 "github.com/kkb0318/irsa-manager/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	regv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNewWebHookSetup(t *testing.T) {
+	creds, err := CreateTlsCredential(ServiceNamespacedName(irsav1alpha1.DefaultWebhookNamespace))
+	assert.NoError(t, err)
+	previous, err := CreateTlsCredential(ServiceNamespacedName(irsav1alpha1.DefaultWebhookNamespace))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name                string
+		cert                ServingCertificate
+		expectedSecretData  map[string][]byte
+		expectedCABundle    []byte
+		expectedCertManager []string
+	}{
+		{
+			name: "self-signed without previous certificate",
+			cert: SelfSignedCertificate(creds, nil),
+			expectedSecretData: map[string][]byte{
+				corev1.TLSCertKey:       creds.Certificate(),
+				corev1.TLSPrivateKeyKey: creds.PrivateKey(),
+			},
+			expectedCABundle: creds.Certificate(),
+		},
+		{
+			name: "self-signed with previous certificate",
+			cert: SelfSignedCertificate(creds, previous.Certificate()),
+			expectedSecretData: map[string][]byte{
+				corev1.TLSCertKey:       creds.Certificate(),
+				corev1.TLSPrivateKeyKey: creds.PrivateKey(),
+				PreviousCertificateKey:  previous.Certificate(),
+			},
+			expectedCABundle: append(append([]byte{}, creds.Certificate()...), previous.Certificate()...),
+		},
+		{
+			name: "certificate not issued yet",
+			cert: ServingCertificate{
+				SecretData: map[string][]byte{PendingPrivateKeyKey: []byte("key")},
+				CABundle:   []byte("ca"),
+			},
+			expectedSecretData: map[string][]byte{
+				corev1.TLSCertKey:       {},
+				corev1.TLSPrivateKeyKey: {},
+				PendingPrivateKeyKey:    []byte("key"),
+			},
+			expectedCABundle: []byte("ca"),
+		},
+		{
+			name:                "cert-manager with self-signed Issuer",
+			cert:                ServingCertificate{CertManager: &CertManagerIssuer{}},
+			expectedCertManager: []string{"Issuer", "Certificate"},
+		},
+		{
+			name:                "cert-manager with ClusterIssuer",
+			cert:                ServingCertificate{CertManager: &CertManagerIssuer{Name: "ca", Kind: "ClusterIssuer"}},
+			expectedCertManager: []string{"Certificate"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup, err := NewWebHookSetup(tt.cert, Options{})
+			assert.NoError(t, err)
+			var secret *corev1.Secret
+			var deploy *appsv1.Deployment
+			var mutate *regv1.MutatingWebhookConfiguration
+			certManager := []string{}
+			for _, r := range setup.Resources() {
+				switch o := r.(type) {
+				case *corev1.Secret:
+					secret = o
+				case *appsv1.Deployment:
+					deploy = o
+				case *regv1.MutatingWebhookConfiguration:
+					mutate = o
+				case *unstructured.Unstructured:
+					certManager = append(certManager, o.GetKind())
+				}
+			}
+			assert.Equal(t, tt.cert.CertificateHash(), deploy.Spec.Template.Annotations[CertificateHashAnnotation])
+			if tt.cert.CertManager != nil {
+				assert.Nil(t, secret)
+				assert.Nil(t, mutate.Webhooks[0].ClientConfig.CABundle)
+				assert.Equal(t, "kube-system/pod-identity-webhook", mutate.Annotations[CertManagerInjectCAAnnotation])
+				assert.Equal(t, tt.expectedCertManager, certManager)
+				return
+			}
+			assert.Equal(t, tt.expectedSecretData, secret.Data)
+			assert.Equal(t, tt.expectedCABundle, mutate.Webhooks[0].ClientConfig.CABundle)
+			assert.Empty(t, mutate.Annotations)
+			assert.Empty(t, certManager)
+		})
+	}
+}
+
+func TestNewWebHookSetupOptions(t *testing.T) {
+	creds, err := CreateTlsCredential(ServiceNamespacedName("identity"))
+	assert.NoError(t, err)
+	tests := []struct {
+		name        string
+		options     Options
+		expectedPDB bool
+	}{
+		{
+			name: "single replica",
+			options: Options{
+				Namespace: "identity",
+				Image:     "registry.example.com/pod-identity-webhook:v0.5.5",
+			},
+			expectedPDB: false,
+		},
+		{
+			name: "multiple replicas",
+			options: Options{
+				Namespace:         "identity",
+				Image:             "registry.example.com/pod-identity-webhook:v0.5.5",
+				ImagePullPolicy:   corev1.PullIfNotPresent,
+				ImagePullSecrets:  []corev1.LocalObjectReference{{Name: "registry"}},
+				Replicas:          3,
+				NodeSelector:      map[string]string{"node-role.kubernetes.io/control-plane": ""},
+				Tolerations:       []corev1.Toleration{{Key: "node-role.kubernetes.io/control-plane", Effect: corev1.TaintEffectNoSchedule}},
+				PriorityClassName: "system-cluster-critical",
+			},
+			expectedPDB: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup, err := NewWebHookSetup(SelfSignedCertificate(creds, nil), tt.options)
+			assert.NoError(t, err)
+			var deploy *appsv1.Deployment
+			var mutate *regv1.MutatingWebhookConfiguration
+			var pdb *policyv1.PodDisruptionBudget
+			for _, r := range setup.Resources() {
+				if _, ok := r.(*regv1.MutatingWebhookConfiguration); !ok && r.GetNamespace() != "" {
+					assert.Equal(t, "identity", r.GetNamespace())
+				}
+				switch o := r.(type) {
+				case *appsv1.Deployment:
+					deploy = o
+				case *regv1.MutatingWebhookConfiguration:
+					mutate = o
+				case *policyv1.PodDisruptionBudget:
+					pdb = o
+				}
+			}
+			podSpec := deploy.Spec.Template.Spec
+			assert.Equal(t, tt.options.replicas(), *deploy.Spec.Replicas)
+			assert.Equal(t, tt.options.Image, podSpec.Containers[0].Image)
+			assert.Equal(t, tt.options.imagePullPolicy(), podSpec.Containers[0].ImagePullPolicy)
+			assert.Equal(t, tt.options.ImagePullSecrets, podSpec.ImagePullSecrets)
+			assert.Equal(t, tt.options.NodeSelector, podSpec.NodeSelector)
+			assert.Equal(t, tt.options.Tolerations, podSpec.Tolerations)
+			assert.Equal(t, tt.options.PriorityClassName, podSpec.PriorityClassName)
+			assert.Contains(t, podSpec.Containers[0].Command, "--namespace=identity")
+			assert.Equal(t, "identity", mutate.Webhooks[0].ClientConfig.Service.Namespace)
+			assert.Equal(t, tt.expectedPDB, pdb != nil)
+		})
+	}
+}
+
+func TestCertManagerResources(t *testing.T) {
+	tests := []struct {
+		name              string
+		issuer            CertManagerIssuer
+		expectedIssuerRef map[string]interface{}
+	}{
+		{
+			name:   "self-signed Issuer",
+			issuer: CertManagerIssuer{},
+			expectedIssuerRef: map[string]interface{}{
+				"name":  "pod-identity-webhook",
+				"kind":  "Issuer",
+				"group": "cert-manager.io",
+			},
+		},
+		{
+			name:   "referenced ClusterIssuer",
+			issuer: CertManagerIssuer{Name: "ca", Kind: "ClusterIssuer"},
+			expectedIssuerRef: map[string]interface{}{
+				"name":  "ca",
+				"kind":  "ClusterIssuer",
+				"group": "cert-manager.io",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources := CertManagerResources(irsav1alpha1.DefaultWebhookNamespace, tt.issuer)
+			certificate := resources[len(resources)-1].(*unstructured.Unstructured)
+			assert.Equal(t, CertificateGVK, certificate.GroupVersionKind())
+			issuerRef, _, err := unstructured.NestedMap(certificate.Object, "spec", "issuerRef")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedIssuerRef, issuerRef)
+			secretName, _, err := unstructured.NestedString(certificate.Object, "spec", "secretName")
+			assert.NoError(t, err)
+			assert.Equal(t, "pod-identity-webhook", secretName)
+		})
+	}
+}
+
+func TestNewCertificateSigningRequest(t *testing.T) {
+	key, err := CreatePrivateKey()
+	assert.NoError(t, err)
+	other, err := CreatePrivateKey()
+	assert.NoError(t, err)
+	expirationSeconds := int32(86400)
+
+	csr, err := NewCertificateSigningRequest(ServiceNamespacedName(irsav1alpha1.DefaultWebhookNamespace), key, "example.com/webhook-serving", &expirationSeconds)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com/webhook-serving", csr.Spec.SignerName)
+	assert.Equal(t, &expirationSeconds, csr.Spec.ExpirationSeconds)
+	block, _ := pem.Decode(csr.Spec.Request)
+	assert.NotNil(t, block)
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, DNSNames(ServiceNamespacedName(irsav1alpha1.DefaultWebhookNamespace)), request.DNSNames)
+
+	again, err := NewCertificateSigningRequest(ServiceNamespacedName(irsav1alpha1.DefaultWebhookNamespace), key, "example.com/webhook-serving", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, csr.Name, again.Name)
+	another, err := NewCertificateSigningRequest(ServiceNamespacedName(irsav1alpha1.DefaultWebhookNamespace), other, "example.com/webhook-serving", nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, csr.Name, another.Name)
+}